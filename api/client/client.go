@@ -0,0 +1,264 @@
+// Package client is a Go SDK for the FiatRails API. It signs every request
+// with the v2 HMAC scheme, manages idempotency keys and retries transient
+// failures with jittered exponential backoff.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatrails/internal/hmacauth"
+)
+
+const (
+	pathMintIntents   = "/api/v1/mint-intents"
+	pathMpesaCallback = "/api/v1/callbacks/mpesa"
+	pathHealth        = "/api/v1/health"
+
+	defaultMaxRetries  = 3
+	defaultBaseBackoff = 200 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+)
+
+type Config struct {
+	// BaseURL is the server root, e.g. http://localhost:3000.
+	BaseURL string
+	// Secret and KeyID sign mint intent requests.
+	Secret string
+	KeyID  string
+	// MpesaSecret signs callback requests; only needed to simulate the provider.
+	MpesaSecret string
+
+	HTTPClient  *http.Client
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+type Client struct {
+	baseURL     string
+	http        *http.Client
+	signer      *hmacauth.Signer
+	mpesaSigner *hmacauth.Signer
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	sleep       func(context.Context, time.Duration) error
+}
+
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("base url is required")
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("secret is required")
+	}
+
+	c := &Client{
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		http:        cfg.HTTPClient,
+		signer:      &hmacauth.Signer{KeyID: cfg.KeyID, Secret: cfg.Secret},
+		maxRetries:  cfg.MaxRetries,
+		baseBackoff: cfg.BaseBackoff,
+		maxBackoff:  cfg.MaxBackoff,
+		sleep:       sleepCtx,
+	}
+	if cfg.MpesaSecret != "" {
+		c.mpesaSigner = &hmacauth.Signer{Secret: cfg.MpesaSecret, SignatureHeader: "X-Mpesa-Signature"}
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: 30 * time.Second}
+	}
+	if c.maxRetries <= 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.baseBackoff <= 0 {
+		c.baseBackoff = defaultBaseBackoff
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = defaultMaxBackoff
+	}
+	return c, nil
+}
+
+// SubmitMintIntent posts a mint intent. Retries reuse the same idempotency key
+// so the server returns the original outcome instead of submitting twice.
+func (c *Client) SubmitMintIntent(ctx context.Context, req MintIntentRequest) (*MintIntentResponse, error) {
+	key := req.IdempotencyKey
+	if key == "" {
+		var err error
+		if key, err = NewIdempotencyKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	var out MintIntentResponse
+	headers := http.Header{"X-Idempotency-Key": []string{key}}
+	if err := c.do(ctx, http.MethodPost, pathMintIntents, c.signer, headers, req, &out); err != nil {
+		return nil, err
+	}
+	out.IdempotencyKey = key
+	return &out, nil
+}
+
+// SendMpesaCallback posts a simulated M-PESA webhook. The server dedups callbacks by txRef.
+func (c *Client) SendMpesaCallback(ctx context.Context, req MpesaCallbackRequest) (*MpesaCallbackResponse, error) {
+	if c.mpesaSigner == nil {
+		return nil, errors.New("mpesa secret not configured")
+	}
+	var out MpesaCallbackResponse
+	if err := c.do(ctx, http.MethodPost, pathMpesaCallback, c.mpesaSigner, nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Health returns the service health report. A degraded service answers 503
+// with a body, which is decoded and returned alongside the APIError.
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+pathHealth, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode health: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &out, &APIError{StatusCode: resp.StatusCode, Message: out.Status}
+	}
+	return &out, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, signer *hmacauth.Signer, headers http.Header, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(ctx, c.backoff(attempt, lastErr)); err != nil {
+				return err
+			}
+		}
+
+		var retry bool
+		retry, lastErr = c.attempt(ctx, method, path, signer, headers, body, out)
+		if lastErr == nil || !retry {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, signer *hmacauth.Signer, headers http.Header, body []byte, out interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	// Sign every attempt: each signature carries a fresh nonce and is accepted once.
+	if err := signer.Sign(req, body); err != nil {
+		return false, fmt.Errorf("sign request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(payload, out); err != nil {
+			return false, fmt.Errorf("decode response: %w", err)
+		}
+		return false, nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(payload))}
+	return retryable(resp.StatusCode), &retryAfterError{APIError: apiErr, after: parseRetryAfter(resp.Header.Get("Retry-After"))}
+}
+
+// backoff returns full-jitter exponential backoff, honouring Retry-After when the server sent one.
+func (c *Client) backoff(attempt int, lastErr error) time.Duration {
+	var ra *retryAfterError
+	if errors.As(lastErr, &ra) && ra.after > 0 {
+		return ra.after
+	}
+	ceiling := c.baseBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.maxBackoff {
+		ceiling = c.maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfterError carries the server's Retry-After hint; it unwraps to *APIError.
+type retryAfterError struct {
+	*APIError
+	after time.Duration
+}
+
+func (e *retryAfterError) Unwrap() error { return e.APIError }
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// NewIdempotencyKey returns a random 32 character key.
+func NewIdempotencyKey() (string, error) {
+	return hmacauth.NewNonce()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
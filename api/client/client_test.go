@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fiatrails/internal/config"
+	"fiatrails/internal/escrow"
+	"fiatrails/internal/idempotency"
+	"fiatrails/internal/server"
+)
+
+func newTestAPI(t *testing.T) (*httptest.Server, *config.AppConfig) {
+	t.Helper()
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.HMACSalt = "client-secret"
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Retry.MaxAttempts = 1
+
+	srv := server.NewServer(cfg, escrow.FakeClient{}, idempotency.NewMemoryStore())
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts, cfg
+}
+
+func TestClientAgainstServer(t *testing.T) {
+	ts, cfg := newTestAPI(t)
+	c, err := New(Config{
+		BaseURL:     ts.URL,
+		Secret:      cfg.Seed.Secrets.HMACSalt,
+		MpesaSecret: cfg.Seed.Secrets.MpesaWebhookSecret,
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+
+	req := MintIntentRequest{
+		UserAddress: "0x00000000000000000000000000000000000000a1",
+		Amount:      "1000000000000000000",
+		CountryCode: "KES",
+		TxRef:       "client-tx-1",
+	}
+	first, err := c.SubmitMintIntent(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if first.IntentID == "" || first.Status != "submitted" || len(first.IdempotencyKey) < 16 {
+		t.Fatalf("unexpected response: %+v", first)
+	}
+
+	req.IdempotencyKey = first.IdempotencyKey
+	again, err := c.SubmitMintIntent(ctx, req)
+	if err != nil {
+		t.Fatalf("resubmit: %v", err)
+	}
+	if again.IntentID != first.IntentID {
+		t.Fatalf("expected idempotent intent id, got %s vs %s", again.IntentID, first.IntentID)
+	}
+
+	cb, err := c.SendMpesaCallback(ctx, MpesaCallbackRequest{
+		IntentID:    first.IntentID,
+		TxRef:       req.TxRef,
+		UserAddress: req.UserAddress,
+		Amount:      req.Amount,
+	})
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if cb.Status != "processed" || cb.TxHash == "" {
+		t.Fatalf("unexpected callback response: %+v", cb)
+	}
+
+	health, err := c.Health(ctx)
+	if err != nil {
+		t.Fatalf("health: %v", err)
+	}
+	if health.Status != "healthy" {
+		t.Fatalf("expected healthy, got %s", health.Status)
+	}
+}
+
+func TestClientRejectsWrongSecret(t *testing.T) {
+	ts, _ := newTestAPI(t)
+	c, _ := New(Config{BaseURL: ts.URL, Secret: "wrong"})
+
+	_, err := c.SubmitMintIntent(context.Background(), MintIntentRequest{
+		UserAddress: "0x00000000000000000000000000000000000000a1",
+		Amount:      "1",
+		CountryCode: "KES",
+		TxRef:       "tx",
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 APIError, got %v", err)
+	}
+}
+
+func TestClientRetriesWithSameIdempotencyKey(t *testing.T) {
+	var calls int32
+	keys := make(chan string, 3)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get("X-Idempotency-Key")
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			http.Error(w, "boom", http.StatusBadGateway)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"intentId":"0x1","status":"submitted"}`))
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := New(Config{BaseURL: ts.URL, Secret: "s", MaxRetries: 3})
+	var slept []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	resp, err := c.SubmitMintIntent(context.Background(), MintIntentRequest{TxRef: "tx"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if resp.IntentID != "0x1" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected success on third call, got %+v after %d calls", resp, calls)
+	}
+	if len(slept) != 2 || slept[0] != time.Second {
+		t.Fatalf("expected Retry-After to be honoured, slept %v", slept)
+	}
+
+	close(keys)
+	var first string
+	for k := range keys {
+		if first == "" {
+			first = k
+		}
+		if k != first {
+			t.Fatalf("idempotency key changed between retries: %s vs %s", first, k)
+		}
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	c, _ := New(Config{BaseURL: ts.URL, Secret: "s"})
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := c.SubmitMintIntent(context.Background(), MintIntentRequest{}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+// TestOpenAPIPathsInSync fails when the SDK drifts from api/openapi.yaml.
+func TestOpenAPIPathsInSync(t *testing.T) {
+	raw, err := os.ReadFile("../openapi.yaml")
+	if err != nil {
+		t.Fatalf("read openapi: %v", err)
+	}
+	spec := string(raw)
+
+	for _, path := range []string{pathMintIntents, pathMpesaCallback, pathHealth} {
+		rel := strings.TrimPrefix(path, "/api/v1")
+		if !strings.Contains(spec, "\n  "+rel+":\n") {
+			t.Errorf("path %s missing from openapi.yaml", rel)
+		}
+	}
+
+	schemas := map[string]interface{}{
+		"MintIntentRequest":  MintIntentRequest{},
+		"MintIntentResponse": MintIntentResponse{},
+		"MpesaCallback":      MpesaCallbackRequest{},
+	}
+	for name, v := range schemas {
+		block := schemaBlock(spec, name)
+		if block == "" {
+			t.Errorf("schema %s missing from openapi.yaml", name)
+			continue
+		}
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			if !strings.Contains(block, "\n        "+tag+":") {
+				t.Errorf("schema %s has no property %s", name, tag)
+			}
+		}
+	}
+}
+
+func schemaBlock(spec, name string) string {
+	start := strings.Index(spec, "\n    "+name+":\n")
+	if start < 0 {
+		return ""
+	}
+	rest := spec[start+1:]
+	lines := strings.SplitAfter(rest, "\n")
+	var b strings.Builder
+	b.WriteString("\n" + lines[0])
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "     ") {
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
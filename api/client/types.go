@@ -0,0 +1,61 @@
+package client
+
+import "fmt"
+
+// Request and response types mirror the JSON bodies in api/openapi.yaml and
+// internal/server. TestOpenAPIPathsInSync guards the endpoint list.
+
+type MintIntentRequest struct {
+	UserAddress string `json:"userAddress"`
+	Amount      string `json:"amount"` // decimal string in wei
+	CountryCode string `json:"countryCode"`
+	TxRef       string `json:"txRef"`
+	// IdempotencyKey is sent as X-Idempotency-Key. One is generated when empty;
+	// it is reused for every retry of the same call.
+	IdempotencyKey string `json:"-"`
+}
+
+type MintIntentResponse struct {
+	IntentID string `json:"intentId"`
+	Status   string `json:"status"`
+	TxHash   string `json:"txHash,omitempty"`
+	// IdempotencyKey is the key the request was sent with.
+	IdempotencyKey string `json:"-"`
+}
+
+type MpesaCallbackRequest struct {
+	IntentID    string `json:"intentId"`
+	TxRef       string `json:"txRef"`
+	UserAddress string `json:"userAddress"`
+	Amount      string `json:"amount"`
+}
+
+type MpesaCallbackResponse struct {
+	Status   string `json:"status"`
+	IntentID string `json:"intentId"`
+	TxHash   string `json:"txHash,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	RPC    struct {
+		Connected bool    `json:"connected"`
+		LatencyMs float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	} `json:"rpc"`
+	Database struct {
+		Connected bool   `json:"connected"`
+		Error     string `json:"error,omitempty"`
+	} `json:"database"`
+	QueueDepth int `json:"queue_depth"`
+}
+
+// APIError is returned for any non-2xx response.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("fiatrails api: %d %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,176 @@
+// Command loadgen sends HMAC-signed mint intents and M-PESA callbacks to a
+// running API at a fixed rate, mixing in duplicate idempotency keys, txRefs
+// and callbacks. It reports latency percentiles and outcomes per operation
+// and exits non-zero if any duplicate produced a second intent or mint.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"fiatrails/internal/config"
+)
+
+type options struct {
+	BaseURL     string
+	Secret      string
+	KeyID       string
+	MpesaSecret string
+
+	// Rate is operations started per second; a fresh mint is followed by
+	// its callback within the same operation.
+	Rate        float64
+	Duration    time.Duration
+	Concurrency int
+	Timeout     time.Duration
+	Seed        uint64
+
+	DupKeyRatio      float64
+	DupTxRefRatio    float64
+	DupCallbackRatio float64
+	SkipCallbacks    bool
+
+	Users   []string
+	Amount  string
+	Country string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run returns 0 on success, 1 when an idempotency invariant was broken and
+// 2 for bad flags.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseFlags(args, stderr)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(stderr, "loadgen:", err)
+		}
+		return 2
+	}
+
+	g := newGenerator(opts)
+	elapsed := g.run(ctx)
+	violations := g.ledger.violations()
+	writeReport(stdout, opts, elapsed, g.stats, violations)
+	if len(violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseFlags(args []string, stderr io.Writer) (options, error) {
+	var opts options
+	var users, seedPath string
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.BaseURL, "url", envOr("LOADGEN_URL", "http://localhost:3000"), "API base URL")
+	fs.StringVar(&seedPath, "seed-file", envOr("SEED_PATH", "../seed.json"), "seed.json to read secrets, amount and country from")
+	fs.StringVar(&opts.Secret, "secret", os.Getenv("LOADGEN_SECRET"), "HMAC secret for mint intents (default: seed secrets.hmacSalt)")
+	fs.StringVar(&opts.KeyID, "key-id", os.Getenv("LOADGEN_KEY_ID"), "API client key ID, when client credentials are required")
+	fs.StringVar(&opts.MpesaSecret, "mpesa-secret", os.Getenv("LOADGEN_MPESA_SECRET"), "M-PESA webhook secret (default: seed secrets.mpesaWebhookSecret)")
+	fs.Float64Var(&opts.Rate, "rate", 10, "operations started per second")
+	fs.DurationVar(&opts.Duration, "duration", 30*time.Second, "how long to generate load")
+	fs.IntVar(&opts.Concurrency, "concurrency", 32, "maximum operations in flight")
+	fs.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "per-request timeout")
+	fs.Uint64Var(&opts.Seed, "rand-seed", 1, "seed for the operation mix and generated users")
+	fs.Float64Var(&opts.DupKeyRatio, "dup-key", 0.05, "share of operations replaying an earlier mint with the same idempotency key")
+	fs.Float64Var(&opts.DupTxRefRatio, "dup-txref", 0.02, "share of operations resubmitting an earlier txRef under a new idempotency key")
+	fs.Float64Var(&opts.DupCallbackRatio, "dup-callback", 0.05, "share of operations redelivering an earlier callback")
+	fs.BoolVar(&opts.SkipCallbacks, "no-callbacks", false, "only submit mint intents")
+	fs.StringVar(&users, "users", "", "comma-separated user addresses (default: a random address per mint)")
+	fs.StringVar(&opts.Amount, "amount", "", "mint amount in wei (default: seed limits.minMintAmount)")
+	fs.StringVar(&opts.Country, "country", "", "country code (default: seed tokens.country.countryCode)")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	for _, u := range strings.Split(users, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			opts.Users = append(opts.Users, u)
+		}
+	}
+
+	if opts.Secret == "" || opts.MpesaSecret == "" || opts.Amount == "" || opts.Country == "" {
+		seed, err := loadSeed(seedPath)
+		if err != nil {
+			return opts, fmt.Errorf("read %s for defaults: %w", seedPath, err)
+		}
+		fill(&opts.Secret, seed.Secrets.HMACSalt)
+		fill(&opts.MpesaSecret, seed.Secrets.MpesaWebhookSecret)
+		fill(&opts.Amount, seed.Limits.MinMintAmount)
+		fill(&opts.Country, seed.Tokens.Country.CountryCode)
+	}
+	return opts, opts.validate()
+}
+
+// interval is the gap between operations. It rounds to zero, which
+// time.NewTicker rejects, when the rate is above one per nanosecond.
+func (o options) interval() time.Duration {
+	return time.Duration(float64(time.Second) / o.Rate)
+}
+
+func (o options) validate() error {
+	var problems []string
+	if o.Secret == "" || (o.MpesaSecret == "" && !o.SkipCallbacks) {
+		problems = append(problems, "mint and M-PESA secrets are required")
+	}
+	if o.Amount == "" || o.Country == "" {
+		problems = append(problems, "amount and country are required")
+	}
+	if !(o.Rate > 0) || o.Duration <= 0 || o.Concurrency < 1 {
+		problems = append(problems, "rate, duration and concurrency must be positive")
+	} else if o.interval() <= 0 {
+		problems = append(problems, "rate must be at most 1e9 per second")
+	}
+	for _, r := range []float64{o.DupKeyRatio, o.DupTxRefRatio, o.DupCallbackRatio} {
+		if r < 0 || r > 1 {
+			problems = append(problems, "duplicate ratios must be between 0 and 1")
+			break
+		}
+	}
+	if o.DupKeyRatio+o.DupTxRefRatio+o.DupCallbackRatio > 1 {
+		problems = append(problems, "duplicate ratios add up to more than 1")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func loadSeed(path string) (*config.SeedConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var seed config.SeedConfig
+	if err := json.Unmarshal(raw, &seed); err != nil {
+		return nil, err
+	}
+	return &seed, nil
+}
+
+func fill(dst *string, fallback string) {
+	if *dst == "" {
+		*dst = fallback
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
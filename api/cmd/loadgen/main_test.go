@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fiatrails/internal/config"
+	"fiatrails/internal/escrow"
+	"fiatrails/internal/idempotency"
+	"fiatrails/internal/intents"
+	"fiatrails/internal/server"
+)
+
+func testOptions(url string) []string {
+	return []string{
+		"-url", url,
+		"-secret", "mint-secret",
+		"-mpesa-secret", "mpesa-secret",
+		"-amount", "1000",
+		"-country", "KES",
+		"-rate", "200",
+		"-duration", "400ms",
+		"-concurrency", "8",
+		"-dup-key", "0.2",
+		"-dup-txref", "0.1",
+		"-dup-callback", "0.2",
+	}
+}
+
+func TestLoadAgainstServerKeepsInvariants(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.HMACSalt = "mint-secret"
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}
+
+	srv := server.NewServer(cfg, escrow.NewFaultClient(1), idempotency.NewMemoryStore(),
+		server.WithIntentRepository(intents.NewMemoryRepository()))
+	api := httptest.NewServer(srv.Handler())
+	t.Cleanup(api.Close)
+
+	var out bytes.Buffer
+	if code := run(context.Background(), testOptions(api.URL), &out, &out); code != 0 {
+		t.Fatalf("exit %d:\n%s", code, out.String())
+	}
+	report := out.String()
+	for _, want := range []string{"mint ", "callback ", "mint_dup_key", "mint_dup_txref", "idempotency invariants: ok"} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("report missing %q:\n%s", want, report)
+		}
+	}
+	// A txRef resubmitted under a new key is refused before it reaches the chain.
+	if !strings.Contains(report, "409=") {
+		t.Fatalf("expected duplicate txRefs to be rejected:\n%s", report)
+	}
+}
+
+func TestLoadReportsDoubleSubmission(t *testing.T) {
+	// An API that forgot idempotency: every mint is a new intent.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := make([]byte, 32)
+		_, _ = rand.Read(id)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"intentId":"0x` + hex.EncodeToString(id) + `","status":"submitted","txHash":"0x` + hex.EncodeToString(id) + `"}`))
+	}))
+	t.Cleanup(api.Close)
+
+	var out bytes.Buffer
+	if code := run(context.Background(), testOptions(api.URL), &out, &out); code != 1 {
+		t.Fatalf("expected exit 1, got %d:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "returned 2 different intents") {
+		t.Fatalf("expected a violation report:\n%s", out.String())
+	}
+}
+
+func TestParseFlagsRejectsBadRatios(t *testing.T) {
+	var out bytes.Buffer
+	args := append(testOptions("http://localhost"), "-dup-key", "0.9")
+	if code := run(context.Background(), args, &out, &out); code != 2 || !strings.Contains(out.String(), "more than 1") {
+		t.Fatalf("expected usage error, got %d: %s", code, out.String())
+	}
+}
+
+func TestParseFlagsRejectsUnreachableRate(t *testing.T) {
+	for _, rate := range []string{"2e9", "+Inf", "NaN"} {
+		var out bytes.Buffer
+		args := append(testOptions("http://localhost"), "-rate", rate)
+		if code := run(context.Background(), args, &out, &out); code != 2 {
+			t.Fatalf("rate %s: expected usage error, got %d: %s", rate, code, out.String())
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var lat []time.Duration
+	for i := 1; i <= 100; i++ {
+		lat = append(lat, time.Duration(i)*time.Millisecond)
+	}
+	if p := percentile(lat, 0.5); p != 50*time.Millisecond {
+		t.Fatalf("p50 = %v", p)
+	}
+	if p := percentile(lat, 0.99); p != 99*time.Millisecond {
+		t.Fatalf("p99 = %v", p)
+	}
+	if p := percentile(nil, 0.5); p != 0 {
+		t.Fatalf("empty p50 = %v", p)
+	}
+}
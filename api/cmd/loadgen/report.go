@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// stats collects latencies and outcome classes per operation.
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	outcomes  map[string]map[string]int
+	dropped   int
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make(map[string][]time.Duration),
+		outcomes:  make(map[string]map[string]int),
+	}
+}
+
+// record adds one finished request. class is the HTTP status, or timeout or
+// transport when no response arrived.
+func (s *stats) record(op string, latency time.Duration, class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if latency > 0 {
+		s.latencies[op] = append(s.latencies[op], latency)
+	}
+	if s.outcomes[op] == nil {
+		s.outcomes[op] = make(map[string]int)
+	}
+	s.outcomes[op][class]++
+}
+
+func (s *stats) drop() {
+	s.mu.Lock()
+	s.dropped++
+	s.mu.Unlock()
+}
+
+func (s *stats) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, classes := range s.outcomes {
+		for _, c := range classes {
+			n += c
+		}
+	}
+	return n
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+var reportOps = []string{opMint, opMintDupKey, opMintDupTxRef, opCallback, opCallbackDupTx}
+
+func writeReport(w io.Writer, opts options, elapsed time.Duration, s *stats, violations []string) {
+	total := s.total()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "%d requests in %s: target %.1f ops/s, achieved %.1f req/s, %d ticks dropped with all %d workers busy\n\n",
+		total, elapsed.Round(time.Millisecond), opts.Rate, float64(total)/elapsed.Seconds(), s.dropped, opts.Concurrency)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "operation\tcount\tp50\tp90\tp99\tmax\toutcomes")
+	for _, op := range reportOps {
+		classes := s.outcomes[op]
+		if len(classes) == 0 {
+			continue
+		}
+		lat := append([]time.Duration(nil), s.latencies[op]...)
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		count := 0
+		for _, c := range classes {
+			count += c
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", op, count,
+			ms(percentile(lat, 0.50)), ms(percentile(lat, 0.90)), ms(percentile(lat, 0.99)), ms(percentile(lat, 1)),
+			formatOutcomes(classes))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if len(violations) == 0 {
+		fmt.Fprintln(w, "idempotency invariants: ok")
+		return
+	}
+	sort.Strings(violations)
+	fmt.Fprintf(w, "idempotency invariants: %d violations\n", len(violations))
+	for _, v := range violations {
+		fmt.Fprintf(w, "  - %s\n", v)
+	}
+}
+
+func formatOutcomes(classes map[string]int) string {
+	names := make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := ""
+	for i, name := range names {
+		if i > 0 {
+			out += " "
+		}
+		out += fmt.Sprintf("%s=%d", name, classes[name])
+	}
+	return out
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
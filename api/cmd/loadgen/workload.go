@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatrails/internal/hmacauth"
+)
+
+// Operation names, used as report rows.
+const (
+	opMint          = "mint"
+	opMintDupKey    = "mint_dup_key"
+	opMintDupTxRef  = "mint_dup_txref"
+	opCallback      = "callback"
+	opCallbackDupTx = "callback_dup"
+)
+
+type mintRequest struct {
+	UserAddress string `json:"userAddress"`
+	Amount      string `json:"amount"`
+	CountryCode string `json:"countryCode"`
+	TxRef       string `json:"txRef"`
+}
+
+type callbackRequest struct {
+	IntentID    string `json:"intentId"`
+	TxRef       string `json:"txRef"`
+	UserAddress string `json:"userAddress"`
+	Amount      string `json:"amount"`
+}
+
+// response is what the API returns for both mints and callbacks.
+type response struct {
+	IntentID string `json:"intentId"`
+	Status   string `json:"status"`
+	TxHash   string `json:"txHash"`
+}
+
+// issuedMint is a mint the API accepted, kept for duplicate replays.
+type issuedMint struct {
+	key string
+	req mintRequest
+}
+
+// generator sends operations and records what the API answered.
+type generator struct {
+	opts   options
+	http   *http.Client
+	signer *hmacauth.Signer
+	mpesa  *hmacauth.Signer
+	stats  *stats
+	ledger *ledger
+	runID  string
+}
+
+func newGenerator(opts options) *generator {
+	return &generator{
+		opts: opts,
+		http: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        opts.Concurrency,
+				MaxIdleConnsPerHost: opts.Concurrency,
+			},
+		},
+		signer: &hmacauth.Signer{KeyID: opts.KeyID, Secret: opts.Secret},
+		mpesa:  &hmacauth.Signer{Secret: opts.MpesaSecret, SignatureHeader: "X-Mpesa-Signature"},
+		stats:  newStats(),
+		ledger: newLedger(),
+		runID:  fmt.Sprintf("%x", time.Now().UnixNano()),
+	}
+}
+
+// run starts one operation per tick until ctx is done or the duration is up.
+// Ticks that find every worker busy are counted as dropped rather than
+// queued, so a slow server shows up as missed rate, not hidden backlog.
+func (g *generator) run(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, g.opts.Duration)
+	defer cancel()
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < g.opts.Concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(g.opts.Seed, uint64(worker)))
+			for seq := range work {
+				g.step(rng, worker, seq)
+			}
+		}(w)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(g.opts.interval())
+	defer ticker.Stop()
+	for seq := 0; ; seq++ {
+		select {
+		case <-ctx.Done():
+			close(work)
+			wg.Wait()
+			return time.Since(start)
+		case <-ticker.C:
+			select {
+			case work <- seq:
+			default:
+				g.stats.drop()
+			}
+		}
+	}
+}
+
+// step picks one operation. Duplicates need something to duplicate, so
+// until the first mint succeeds every step is a fresh mint.
+func (g *generator) step(rng *rand.Rand, worker, seq int) {
+	r := rng.Float64()
+	o := g.opts
+	switch {
+	case r < o.DupKeyRatio:
+		if prev, ok := g.ledger.randomMint(rng); ok {
+			g.mint(opMintDupKey, prev.key, prev.req)
+			return
+		}
+	case r < o.DupKeyRatio+o.DupTxRefRatio:
+		if prev, ok := g.ledger.randomMint(rng); ok {
+			g.mint(opMintDupTxRef, g.newKey(worker, seq), prev.req)
+			return
+		}
+	case r < o.DupKeyRatio+o.DupTxRefRatio+o.DupCallbackRatio:
+		if prev, ok := g.ledger.randomCallback(rng); ok {
+			g.callback(opCallbackDupTx, prev)
+			return
+		}
+	}
+
+	req := mintRequest{
+		UserAddress: g.user(rng),
+		Amount:      o.Amount,
+		CountryCode: o.Country,
+		TxRef:       fmt.Sprintf("LG%s-%d-%d", g.runID, worker, seq),
+	}
+	key := g.newKey(worker, seq)
+	resp, ok := g.mint(opMint, key, req)
+	if !ok || o.SkipCallbacks {
+		return
+	}
+	g.ledger.addMint(issuedMint{key: key, req: req})
+	cb := callbackRequest{IntentID: resp.IntentID, TxRef: req.TxRef, UserAddress: req.UserAddress, Amount: req.Amount}
+	if _, ok := g.callback(opCallback, cb); ok {
+		g.ledger.addCallback(cb)
+	}
+}
+
+func (g *generator) mint(op, key string, req mintRequest) (response, bool) {
+	resp, ok := g.post(op, "/api/v1/mint-intents", g.signer, key, req)
+	if ok {
+		g.ledger.mintAccepted(key, req.TxRef, resp.IntentID)
+	}
+	return resp, ok
+}
+
+func (g *generator) callback(op string, req callbackRequest) (response, bool) {
+	resp, ok := g.post(op, "/api/v1/callbacks/mpesa", g.mpesa, "", req)
+	if ok {
+		g.ledger.callbackAccepted(req.TxRef, resp.TxHash)
+	}
+	return resp, ok
+}
+
+// post sends one signed request without retrying, records its latency and
+// outcome, and reports whether the API answered 2xx.
+func (g *generator) post(op, path string, signer *hmacauth.Signer, idempotencyKey string, in any) (response, bool) {
+	var out response
+	body, err := json.Marshal(in)
+	if err != nil {
+		g.stats.record(op, 0, "encode")
+		return out, false
+	}
+	req, err := http.NewRequest(http.MethodPost, g.opts.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		g.stats.record(op, 0, "encode")
+		return out, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("X-Idempotency-Key", idempotencyKey)
+	}
+	if err := signer.Sign(req, body); err != nil {
+		g.stats.record(op, 0, "sign")
+		return out, false
+	}
+
+	start := time.Now()
+	res, err := g.http.Do(req)
+	if err != nil {
+		g.stats.record(op, time.Since(start), transportClass(err))
+		return out, false
+	}
+	payload, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	elapsed := time.Since(start)
+	if err != nil {
+		g.stats.record(op, elapsed, transportClass(err))
+		return out, false
+	}
+	g.stats.record(op, elapsed, fmt.Sprint(res.StatusCode))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return out, false
+	}
+	return out, json.Unmarshal(payload, &out) == nil
+}
+
+func transportClass(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "transport"
+}
+
+func (g *generator) newKey(worker, seq int) string {
+	return fmt.Sprintf("lg-%s-%d-%d", g.runID, worker, seq)
+}
+
+// user returns one of the configured users, or a fresh random address so
+// the per-user rate limit does not cap the run.
+func (g *generator) user(rng *rand.Rand) string {
+	if len(g.opts.Users) > 0 {
+		return g.opts.Users[rng.IntN(len(g.opts.Users))]
+	}
+	var b [20]byte
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// ledger tracks what every idempotency key and txRef resolved to, so a
+// second submission shows up as a second intent or transaction.
+type ledger struct {
+	mu        sync.Mutex
+	mints     []issuedMint
+	callbacks []callbackRequest
+	byKey     map[string]map[string]bool
+	byTxRef   map[string]map[string]bool
+	executed  map[string]map[string]bool
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		byKey:    make(map[string]map[string]bool),
+		byTxRef:  make(map[string]map[string]bool),
+		executed: make(map[string]map[string]bool),
+	}
+}
+
+func (l *ledger) mintAccepted(key, txRef, intentID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	addTo(l.byKey, key, strings.ToLower(intentID))
+	addTo(l.byTxRef, txRef, strings.ToLower(intentID))
+}
+
+// callbackAccepted records the mint transaction for txRef. A queued
+// callback has no transaction yet and is not recorded.
+func (l *ledger) callbackAccepted(txRef, txHash string) {
+	if txHash == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	addTo(l.executed, txRef, strings.ToLower(txHash))
+}
+
+func (l *ledger) addMint(m issuedMint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mints = append(l.mints, m)
+}
+
+func (l *ledger) addCallback(cb callbackRequest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.callbacks = append(l.callbacks, cb)
+}
+
+func (l *ledger) randomMint(rng *rand.Rand) (issuedMint, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.mints) == 0 {
+		return issuedMint{}, false
+	}
+	return l.mints[rng.IntN(len(l.mints))], true
+}
+
+func (l *ledger) randomCallback(rng *rand.Rand) (callbackRequest, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.callbacks) == 0 {
+		return callbackRequest{}, false
+	}
+	return l.callbacks[rng.IntN(len(l.callbacks))], true
+}
+
+// violations lists every key or txRef that resolved to more than one
+// intent, and every txRef minted by more than one transaction.
+func (l *ledger) violations() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	check := func(what string, seen map[string]map[string]bool, noun string) {
+		for k, values := range seen {
+			if len(values) > 1 {
+				out = append(out, fmt.Sprintf("%s %s returned %d different %s", what, k, len(values), noun))
+			}
+		}
+	}
+	check("idempotency key", l.byKey, "intents")
+	check("txRef", l.byTxRef, "intents")
+	check("callback txRef", l.executed, "mint transactions")
+	return out
+}
+
+func addTo(m map[string]map[string]bool, key, value string) {
+	if value == "" {
+		return
+	}
+	if m[key] == nil {
+		m[key] = make(map[string]bool)
+	}
+	m[key][value] = true
+}
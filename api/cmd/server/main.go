@@ -2,70 +2,220 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"fiatrails/internal/apiclients"
 	"fiatrails/internal/config"
 	"fiatrails/internal/escrow"
+	"fiatrails/internal/hmacauth"
 	"fiatrails/internal/idempotency"
+	"fiatrails/internal/intents"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/ratelimit"
+	"fiatrails/internal/retry"
 	"fiatrails/internal/server"
+	"fiatrails/internal/tracing"
 )
 
+// configChecks validates the settings config leaves to the packages that own
+// them.
+var configChecks = config.Checks{
+	Keyring: func(path string) (int, error) {
+		keys, err := hmacauth.LoadKeyring(path)
+		if err != nil {
+			return 0, err
+		}
+		return len(keys.Active(time.Now())), nil
+	},
+	Jitter: func(mode string) error {
+		_, err := retry.ParseJitter(mode)
+		return err
+	},
+	Faults: func(spec string) error {
+		_, err := escrow.ParseFaultRules(spec)
+		return err
+	},
+}
+
 func main() {
-	cfg, err := config.Load()
+	logger := logging.NewFromEnv()
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 && os.Args[1] == "verify-deployment" {
+		os.Exit(runVerifyDeployment(logger, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(logger, os.Args[2:], os.Stdout))
+	}
+
+	cfg, err := config.Load(configChecks)
 	if err != nil {
-		log.Fatalf("config error: %v", err)
+		fatal(logger, "config error", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal(logger, "tracing setup error", err)
 	}
 
 	var store idempotency.Store
-	var storeCloser func()
+	var closers []func()
+	var opts []server.Option
+	backends := server.Backends{Mode: string(cfg.Mode), Clients: "none", Idempotency: cfg.Service.IdempotencyBackend}
 
 	if cfg.Database.URL != "" {
 		pgStore, err := idempotency.NewPostgresStore(context.Background(), cfg.Database.URL)
 		if err != nil {
-			log.Fatalf("postgres store error: %v", err)
+			fatal(logger, "postgres store error", err)
+		}
+		if err := migrateAtStartup(logger, pgStore.Pool(), cfg.Database.MigrateOnStart); err != nil {
+			fatal(logger, "schema migration error", err)
+		}
+		closers = append(closers, pgStore.Close)
+		if cfg.Service.IdempotencyBackend == "postgres" {
+			store = pgStore
 		}
-		store = pgStore
-		storeCloser = pgStore.Close
+
+		opts = append(opts,
+			server.WithClientRegistry(apiclients.NewPostgresRegistry(pgStore.Pool())),
+			server.WithIntentRepository(intents.NewPostgresRepository(pgStore.Pool())),
+			server.WithRateLimiter(ratelimit.NewPostgresLimiter(pgStore.Pool())),
+		)
+		backends.Clients = "postgres"
+		backends.RateLimit = "postgres"
 	} else {
+		backends.RateLimit = "memory"
+
+		if cfg.Service.APIClientsPath != "" {
+			registry, err := apiclients.LoadFile(cfg.Service.APIClientsPath)
+			if err != nil {
+				fatal(logger, "api client registry error", err)
+			}
+			opts = append(opts, server.WithClientRegistry(registry))
+			backends.Clients = "file"
+		}
+	}
+
+	switch cfg.Service.IdempotencyBackend {
+	case "redis":
+		redisStore, err := idempotency.NewRedisStore(context.Background(), cfg.Redis.URL, cfg.Redis.KeyPrefix)
+		if err != nil {
+			fatal(logger, "idempotency store error", err)
+		}
+		store = redisStore
+		closers = append(closers, redisStore.Close)
+	case "file":
 		fsStore, err := idempotency.NewFileStore(cfg.Service.IdempotencyStorePath)
 		if err != nil {
-			log.Fatalf("idempotency store error: %v", err)
+			fatal(logger, "idempotency store error", err)
 		}
 		store = fsStore
+		closers = append(closers, fsStore.Close)
 	}
 
-	var escClient escrow.Client = escrow.FakeClient{}
-	if cfg.Chain.PrivateKey != "" {
-		ethClient, err := escrow.NewEthClient(context.Background(), escrow.EthClientConfig{
-			RPCURL:             cfg.Chain.RPCURL,
+	rpcMetrics := escrow.NewRPCMetrics()
+	opts = append(opts, server.WithCollectors(rpcMetrics))
+
+	var escClient escrow.Client
+	switch {
+	case cfg.Chain.PrivateKey != "":
+		var endpoints []escrow.Endpoint
+		for _, ep := range cfg.Chain.RPCEndpoints {
+			endpoints = append(endpoints, escrow.Endpoint{URL: ep.URL, Priority: ep.Priority})
+		}
+		ethClient, err := escrow.NewEthClient(logging.NewContext(context.Background(), logger), escrow.EthClientConfig{
+			RPCURL:    cfg.Chain.RPCURL,
+			Endpoints: endpoints,
+			Health: escrow.HealthSettings{
+				CheckInterval:    cfg.Chain.RPCHealth.CheckInterval,
+				MaxBlockLag:      cfg.Chain.RPCHealth.MaxBlockLag,
+				MaxLatency:       cfg.Chain.RPCHealth.MaxLatency,
+				FailureThreshold: cfg.Chain.RPCHealth.FailureThreshold,
+			},
+			CallTimeout:        cfg.Chain.RPCTimeout,
 			PrivateKeyHex:      cfg.Chain.PrivateKey,
 			ContractMintEscrow: cfg.Deployment.Contracts.MintEscrow,
+			Metrics:            rpcMetrics,
 		})
 		if err != nil {
-			log.Fatalf("escrow client error: %v", err)
+			fatal(logger, "escrow client error", err)
 		}
+		verifyAtStartup(logger, cfg, ethClient)
 		escClient = ethClient
+		backends.Chain = "ethereum"
+	case cfg.Mode.AllowsFakes() && cfg.Chain.Fake.Enabled():
+		fake := cfg.Chain.Fake
+		rules, err := escrow.ParseFaultRules(fake.Faults)
+		if err != nil {
+			fatal(logger, "escrow client error", err)
+		}
+		faults := escrow.NewFaultClient(fake.Seed)
+		faults.Latency, faults.Jitter = fake.Latency, fake.Jitter
+		faults.SetRules(rules...)
+		logger.Warn("CHAIN_PRIVATE_KEY not set; using the fault-injecting fake chain client, nothing will be minted",
+			"faults", fake.Faults, "latency", fake.Latency.String(), "jitter", fake.Jitter.String(), "seed", fake.Seed)
+		escClient = faults
+		backends.Chain = "fake-faults"
+	case cfg.Mode.AllowsFakes():
+		logger.Warn("CHAIN_PRIVATE_KEY not set; using the fake chain client, nothing will be minted")
+		escClient = escrow.FakeClient{}
+		backends.Chain = "fake"
+	default:
+		// config.Load rejects this already; never fall back to fakes silently.
+		fatal(logger, "escrow client error", fmt.Errorf("CHAIN_PRIVATE_KEY is required in %s mode", cfg.Mode))
 	}
 
-	apiServer := server.NewServer(cfg, escClient, store)
+	opts = append(opts, server.WithLogger(logger), server.WithBackends(backends))
+	apiServer := server.NewServer(cfg, escClient, store, opts...)
 
 	go func() {
-		if err := apiServer.Start(); err != nil {
-			log.Printf("server stopped: %v", err)
+		if err := apiServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "server error", err)
 		}
 	}()
 
+	reloadConfig := func(trigger string) {
+		logger.Info("reloading config", "trigger", trigger)
+		next, err := config.Load(configChecks)
+		if err != nil {
+			apiServer.RejectConfig(err)
+			return
+		}
+		_, _ = apiServer.ApplyConfig(next)
+	}
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go config.Watch(watchCtx, cfg.Service.ConfigReloadInterval, cfg.Source.Files(), func() { reloadConfig("file change") })
+
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	<-ch
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range ch {
+		if sig == syscall.SIGHUP {
+			reloadConfig("sighup")
+			continue
+		}
+		break
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Service.HMACClockSkew)
 	defer cancel()
 	_ = apiServer.Shutdown(ctx)
-	if storeCloser != nil {
-		storeCloser()
+	for _, closeStore := range closers {
+		closeStore()
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("trace flush failed", "error", err)
 	}
 }
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
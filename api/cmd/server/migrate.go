@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"fiatrails/internal/logging"
+	"fiatrails/internal/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateTimeout = 5 * time.Minute
+
+// migrateAtStartup brings the schema up to date before any Postgres-backed
+// component is built. With DB_MIGRATE_ON_START=false it only checks, so
+// operators can run migrations as a separate deploy step.
+func migrateAtStartup(logger *slog.Logger, pool *pgxpool.Pool, apply bool) error {
+	ctx, cancel := context.WithTimeout(logging.NewContext(context.Background(), logger), migrateTimeout)
+	defer cancel()
+	m, err := migrate.New(pool)
+	if err != nil {
+		return err
+	}
+	if !apply {
+		if err := m.Check(ctx); err != nil {
+			return fmt.Errorf("%w; run `fiatrails migrate up`", err)
+		}
+		return nil
+	}
+	_, err = m.Up(ctx)
+	return err
+}
+
+// runMigrate implements `fiatrails migrate [status|up|down N]`. It only needs
+// DATABASE_URL, so it runs without the seed, deployments or chain key.
+func runMigrate(logger *slog.Logger, args []string, stdout io.Writer) int {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	steps := 1
+	switch {
+	case cmd == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			logger.Error("migrate down takes a positive number of steps", "steps", args[1])
+			return 2
+		}
+		steps = n
+	case (cmd == "status" || cmd == "up" || cmd == "down") && len(args) <= 1:
+	default:
+		logger.Error("usage: migrate [status|up|down N]")
+		return 2
+	}
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		logger.Error("DATABASE_URL is not set")
+		return 2
+	}
+	ctx, cancel := context.WithTimeout(logging.NewContext(context.Background(), logger), migrateTimeout)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		logger.Error("connect database", "error", err)
+		return 2
+	}
+	defer pool.Close()
+	m, err := migrate.New(pool)
+	if err != nil {
+		logger.Error("load migrations", "error", err)
+		return 2
+	}
+
+	switch cmd {
+	case "up":
+		_, err = m.Up(ctx)
+	case "down":
+		_, err = m.Down(ctx, steps)
+	}
+	if err != nil {
+		logger.Error("migrate "+cmd+" failed", "error", err)
+		return 1
+	}
+
+	states, err := m.Status(ctx)
+	if err != nil {
+		logger.Error("read migration status", "error", err)
+		return 1
+	}
+	printMigrationStatus(stdout, states)
+	return 0
+}
+
+func printMigrationStatus(w io.Writer, states []migrate.State) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+	pending := 0
+	for _, st := range states {
+		status := "pending"
+		switch {
+		case st.Unknown:
+			status = "applied " + st.AppliedAt.UTC().Format(time.RFC3339) + " (not in this binary)"
+		case st.Applied():
+			status = "applied " + st.AppliedAt.UTC().Format(time.RFC3339)
+		default:
+			pending++
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, status)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "%d pending\n", pending)
+}
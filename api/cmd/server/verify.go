@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"fiatrails/internal/config"
+	"fiatrails/internal/escrow"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const verifyTimeout = 30 * time.Second
+
+// deploymentSpec describes the deployment cfg expects to find on chain.
+func deploymentSpec(cfg *config.AppConfig) escrow.DeploymentSpec {
+	d := cfg.Deployment
+	spec := escrow.DeploymentSpec{
+		ChainID:              d.ChainID,
+		USDStablecoin:        d.Contracts.USDStablecoin,
+		CountryToken:         d.Contracts.CountryToken,
+		UserRegistry:         d.Contracts.UserRegistry,
+		ComplianceManager:    d.Contracts.ComplianceManager,
+		MintEscrow:           d.Contracts.MintEscrow,
+		CountryCode:          cfg.Seed.Tokens.Country.CountryCode,
+		StablecoinDecimals:   uint8(cfg.Seed.Tokens.Stablecoin.Decimals),
+		CountryTokenDecimals: uint8(cfg.Seed.Tokens.Country.Decimals),
+	}
+	if spec.ChainID == 0 {
+		spec.ChainID = cfg.Seed.Chain.ChainID
+	}
+	if d.Executor != "" {
+		spec.Executors = []string{d.Executor}
+	}
+	return spec
+}
+
+// verifyAtStartup checks the deployment before any traffic is accepted.
+// Outside dev a failed check stops the process.
+func verifyAtStartup(logger *slog.Logger, cfg *config.AppConfig, client *escrow.EthClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	err := client.VerifyDeployment(ctx, deploymentSpec(cfg)).Err()
+	switch {
+	case err == nil:
+		logger.Info("deployment verified", "chain_id", cfg.Deployment.ChainID, "mint_escrow", cfg.Deployment.Contracts.MintEscrow)
+	case cfg.Mode.AllowsFakes():
+		logger.Warn("deployment verification failed", "error", err)
+	default:
+		fatal(logger, "deployment verification failed", err)
+	}
+}
+
+// runVerifyDeployment implements `fiatrails verify-deployment [-executor
+// addr,...]`: it prints every check against the first configured RPC
+// endpoint and returns a non-zero exit code if any failed. It only reads the
+// chain, so CHAIN_PRIVATE_KEY is optional; without it EXECUTOR_ROLE is
+// checked for the deployments.json executor and any -executor addresses.
+func runVerifyDeployment(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("verify-deployment", flag.ContinueOnError)
+	executors := fs.String("executor", "", "comma-separated addresses that must hold EXECUTOR_ROLE")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var extra []string
+	for _, addr := range strings.Split(*executors, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if !common.IsHexAddress(addr) {
+			logger.Error("invalid -executor address", "address", addr)
+			return 2
+		}
+		extra = append(extra, addr)
+	}
+
+	cfg, err := config.LoadWithoutSigner(configChecks)
+	if err != nil {
+		logger.Error("config error", "error", err)
+		return 2
+	}
+	if len(cfg.Chain.RPCEndpoints) == 0 {
+		logger.Error("no RPC endpoint configured")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	client, err := ethclient.DialContext(ctx, cfg.Chain.RPCEndpoints[0].URL)
+	if err != nil {
+		logger.Error("dial rpc", "error", err)
+		return 2
+	}
+	defer client.Close()
+
+	spec := deploymentSpec(cfg)
+	spec.Executors = append(spec.Executors, extra...)
+	if cfg.Chain.PrivateKey != "" {
+		if addr, err := escrow.AddressFromKey(cfg.Chain.PrivateKey); err == nil {
+			spec.Executors = append(spec.Executors, addr)
+		}
+	}
+
+	report := escrow.VerifyDeployment(ctx, client, spec)
+	for _, c := range report.Checks {
+		status := "ok  "
+		if !c.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(os.Stdout, "%s  %-40s %s\n", status, c.Name, c.Detail)
+	}
+	if err := report.Err(); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package apiclients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultCacheTTL    = 30 * time.Second
+	defaultNotFoundTTL = 5 * time.Second
+	// cacheSweepEvery bounds the cache: unknown IDs come from callers, so
+	// expired misses are dropped every so many inserts.
+	cacheSweepEvery = 1024
+)
+
+// PostgresRegistry stores clients in the api_clients table. Lookups are cached
+// briefly because every signed request resolves its client; misses are cached
+// for less time so a client added on another replica is seen soon.
+type PostgresRegistry struct {
+	pool        *pgxpool.Pool
+	cacheTTL    time.Duration
+	notFoundTTL time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]cachedClient
+	inserts int
+}
+
+type cachedClient struct {
+	client  *Client // nil caches ErrNotFound
+	expires time.Time
+}
+
+// NewPostgresRegistry expects the schema from package migrate.
+func NewPostgresRegistry(pool *pgxpool.Pool) *PostgresRegistry {
+	return &PostgresRegistry{
+		pool:        pool,
+		cacheTTL:    defaultCacheTTL,
+		notFoundTTL: defaultNotFoundTTL,
+		cache:       make(map[string]cachedClient),
+	}
+}
+
+func (p *PostgresRegistry) Get(ctx context.Context, id string) (*Client, error) {
+	p.mu.Lock()
+	if hit, ok := p.cache[id]; ok && time.Now().Before(hit.expires) {
+		p.mu.Unlock()
+		if hit.client == nil {
+			return nil, ErrNotFound
+		}
+		return hit.client, nil
+	}
+	p.mu.Unlock()
+
+	row := p.pool.QueryRow(ctx, `
+SELECT id, secret, allowed_endpoints, allowed_countries, rate_limit_per_minute, rate_limit_burst, disabled
+FROM api_clients
+WHERE id = $1
+`, id)
+
+	var c Client
+	if err := row.Scan(&c.ID, &c.Secret, &c.AllowedEndpoints, &c.AllowedCountries,
+		&c.RateLimit.RequestsPerMinute, &c.RateLimit.Burst, &c.Disabled); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.remember(id, nil, p.notFoundTTL)
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	p.remember(id, &c, p.cacheTTL)
+	return &c, nil
+}
+
+func (p *PostgresRegistry) remember(id string, c *Client, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.inserts++
+	if p.inserts%cacheSweepEvery == 0 {
+		for k, hit := range p.cache {
+			if !now.Before(hit.expires) {
+				delete(p.cache, k)
+			}
+		}
+	}
+	p.cache[id] = cachedClient{client: c, expires: now.Add(ttl)}
+}
+
+func (p *PostgresRegistry) Put(ctx context.Context, c Client) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	_, err := p.pool.Exec(ctx, `
+INSERT INTO api_clients (id, secret, allowed_endpoints, allowed_countries, rate_limit_per_minute, rate_limit_burst, disabled)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    allowed_endpoints = EXCLUDED.allowed_endpoints,
+    allowed_countries = EXCLUDED.allowed_countries,
+    rate_limit_per_minute = EXCLUDED.rate_limit_per_minute,
+    rate_limit_burst = EXCLUDED.rate_limit_burst,
+    disabled = EXCLUDED.disabled,
+    updated_at = now()
+`, c.ID, c.Secret, nonNil(c.AllowedEndpoints), nonNil(c.AllowedCountries),
+		c.RateLimit.RequestsPerMinute, c.RateLimit.Burst, c.Disabled)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.cache, c.ID)
+	p.mu.Unlock()
+	return nil
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
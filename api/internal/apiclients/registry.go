@@ -0,0 +1,133 @@
+package apiclients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"fiatrails/internal/hmacauth"
+)
+
+var ErrNotFound = errors.New("api client not found")
+
+// Client is a partner allowed to call the API with its own HMAC credentials.
+type Client struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	// AllowedEndpoints lists request paths the client may call, matched
+	// exactly. Routes with wildcards are granted by their pattern, e.g.
+	// /api/v1/mint-intents/{intentId}. Empty allows all.
+	AllowedEndpoints []string `json:"allowedEndpoints"`
+	// AllowedCountries lists country codes the client may mint; empty allows all.
+	AllowedCountries []string  `json:"allowedCountries"`
+	RateLimit        RateLimit `json:"rateLimit"`
+	Disabled         bool      `json:"disabled"`
+}
+
+type RateLimit struct {
+	RequestsPerMinute int `json:"requestsPerMinute"`
+	Burst             int `json:"burst"`
+}
+
+func (c *Client) AllowsEndpoint(path string) bool {
+	return len(c.AllowedEndpoints) == 0 || containsFold(c.AllowedEndpoints, path)
+}
+
+func (c *Client) AllowsCountry(code string) bool {
+	return len(c.AllowedCountries) == 0 || containsFold(c.AllowedCountries, code)
+}
+
+// Registry looks up API clients by ID.
+type Registry interface {
+	Get(ctx context.Context, id string) (*Client, error)
+}
+
+// MemoryRegistry serves a fixed set of clients, e.g. loaded from a JSON file in dev.
+type MemoryRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]Client
+}
+
+func NewMemoryRegistry(clients ...Client) *MemoryRegistry {
+	m := &MemoryRegistry{clients: make(map[string]Client, len(clients))}
+	for _, c := range clients {
+		m.clients[c.ID] = c
+	}
+	return m
+}
+
+// LoadFile reads a JSON array of clients.
+func LoadFile(path string) (*MemoryRegistry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var clients []Client
+	if err := json.Unmarshal(raw, &clients); err != nil {
+		return nil, fmt.Errorf("parse clients: %w", err)
+	}
+	for _, c := range clients {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return NewMemoryRegistry(clients...), nil
+}
+
+func (m *MemoryRegistry) Get(_ context.Context, id string) (*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.clients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+func (m *MemoryRegistry) Put(c Client) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[c.ID] = c
+	return nil
+}
+
+func (c Client) validate() error {
+	if strings.TrimSpace(c.ID) == "" {
+		return errors.New("client id is required")
+	}
+	if c.ID == hmacauth.LegacyKeyID {
+		return fmt.Errorf("client id %q is reserved for the shared secret", c.ID)
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("client %q has no secret", c.ID)
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the client attached to ctx by the server's auth middleware.
+func FromContext(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(contextKey{}).(*Client)
+	return c, ok
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
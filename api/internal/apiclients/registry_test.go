@@ -0,0 +1,75 @@
+package apiclients
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	contents := `[{"id":"partner-a","secret":"s","allowedCountries":["KES"],"rateLimit":{"requestsPerMinute":60,"burst":10}}]`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	reg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	c, err := reg.Get(context.Background(), "partner-a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !c.AllowsCountry("kes") || c.AllowsCountry("UGX") || !c.AllowsEndpoint("/anything") {
+		t.Fatalf("unexpected permissions: %+v", c)
+	}
+	if c.RateLimit.RequestsPerMinute != 60 {
+		t.Fatalf("rate limit not loaded: %+v", c.RateLimit)
+	}
+	if _, err := reg.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLoadFileRejectsInvalidClients(t *testing.T) {
+	for _, contents := range []string{
+		`[{"id":"partner-a"}]`,
+		`[{"id":"default","secret":"s"}]`, // the shared secret's key ID
+	} {
+		path := filepath.Join(t.TempDir(), "clients.json")
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := LoadFile(path); err == nil {
+			t.Fatalf("expected validation error for %s", contents)
+		}
+	}
+}
+
+func TestPostgresRegistryCachesMisses(t *testing.T) {
+	// No pool: any query would panic, so the answer must come from the cache.
+	p := NewPostgresRegistry(nil)
+	p.remember("ghost", nil, time.Minute)
+	if _, err := p.Get(context.Background(), "ghost"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected cached ErrNotFound, got %v", err)
+	}
+}
+
+func TestAllowsEndpointMatchesExactly(t *testing.T) {
+	c := Client{AllowedEndpoints: []string{"/api/v1/mint-intents"}}
+	for path, want := range map[string]bool{
+		"/api/v1/mint-intents":            true,
+		"/API/v1/mint-intents":            true,
+		"/api/v1/mint-intents/0xabc":      false,
+		"/api/v1/mint-intents/{intentId}": false,
+		"/api/v1/callbacks/mpesa":         false,
+	} {
+		if got := c.AllowsEndpoint(path); got != want {
+			t.Errorf("AllowsEndpoint(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
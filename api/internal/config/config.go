@@ -3,8 +3,11 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -45,10 +48,10 @@ type SeedConfig struct {
 		DailyMintLimit string `json:"dailyMintLimit"`
 	} `json:"limits"`
 	Retry struct {
-		MaxAttempts       int `json:"maxAttempts"`
-		InitialBackoffMs  int `json:"initialBackoffMs"`
-		MaxBackoffMs      int `json:"maxBackoffMs"`
-		BackoffMultiplier int `json:"backoffMultiplier"`
+		MaxAttempts       int     `json:"maxAttempts"`
+		InitialBackoffMs  int     `json:"initialBackoffMs"`
+		MaxBackoffMs      int     `json:"maxBackoffMs"`
+		BackoffMultiplier float64 `json:"backoffMultiplier"`
 	} `json:"retry"`
 	Timeouts struct {
 		RPCTimeoutMs          int `json:"rpcTimeoutMs"`
@@ -72,50 +75,233 @@ type DeploymentConfig struct {
 	} `json:"contracts"`
 }
 
+// Mode says what kind of environment the service runs in. Only dev may run
+// against fake backends.
+type Mode string
+
+const (
+	ModeProduction Mode = "production"
+	ModeStaging    Mode = "staging"
+	ModeDev        Mode = "dev"
+)
+
+// AllowsFakes reports whether fake backends may stand in for real ones.
+func (m Mode) AllowsFakes() bool {
+	return m == ModeDev
+}
+
+func (m Mode) valid() bool {
+	switch m {
+	case ModeProduction, ModeStaging, ModeDev:
+		return true
+	}
+	return false
+}
+
 // AppConfig ties together seed + deployment info and derived values.
 type AppConfig struct {
+	// Mode is production unless RUN_MODE says otherwise.
+	Mode       Mode
 	Seed       SeedConfig
 	Deployment DeploymentConfig
 	Service    ServiceConfig
 	Retry      RetryConfig
 	Chain      ChainConfig
 	Database   DatabaseConfig
+	Redis      RedisConfig
+	RateLimit  RateLimitConfig
+	Breaker    BreakerConfig
+	// Limits are the seed mint limits, parsed.
+	Limits MintLimits
+	// Source records where this config was loaded from.
+	Source Source
+}
+
+// MintLimits bound a single mint amount in token base units; nil disables a bound.
+type MintLimits struct {
+	Min *big.Int
+	Max *big.Int
+}
+
+// Source describes the files and environment a config was built from.
+type Source struct {
+	SeedPath        string
+	DeploymentsPath string
+	// EnvOverrides names the environment variables that were set.
+	EnvOverrides []string
+	LoadedAt     time.Time
 }
 
 type ServiceConfig struct {
-	HTTPPort             int
-	HMACClockSkew        time.Duration
-	IdempotencyWindow    time.Duration
+	HTTPPort int
+	// HTTP server timeouts; WriteTimeout must outlast WebhookTimeout.
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	// WebhookTimeout is how long the payment provider waits for a callback
+	// response before giving up (seed timeouts.webhookTimeoutMs).
+	WebhookTimeout    time.Duration
+	HMACClockSkew     time.Duration
+	IdempotencyWindow time.Duration
+	// IdempotencyBackend is postgres, redis or file. It defaults to postgres
+	// when DATABASE_URL is set and file otherwise.
+	IdempotencyBackend   string
 	IdempotencyStorePath string
-	DLQPath              string
+	// Expired idempotency records are deleted every IdempotencySweepInterval,
+	// IdempotencySweepBatch at a time.
+	IdempotencySweepInterval time.Duration
+	IdempotencySweepBatch    int
+	DLQPath                  string
+	// RetryQueuePath holds callbacks parked while the chain circuit is open.
+	RetryQueuePath        string
+	RetryQueueInterval    time.Duration
+	RetryQueueMaxAttempts int
+	// Keyring files take precedence over the seed secrets when set.
+	HMACKeyringPath       string
+	MpesaKeyringPath      string
+	KeyringReloadInterval time.Duration
+	// HMACDisableV1 rejects legacy timestamp||body signatures on the mint endpoint.
+	HMACDisableV1 bool
+	// APIClientsPath seeds the client registry from JSON when no database is configured.
+	APIClientsPath           string
+	RequireClientCredentials bool
+	// AdminToken guards /api/v1/admin endpoints as a bearer token; empty disables them.
+	AdminToken string
+	// ConfigReloadInterval is how often seed.json and deployments.json are
+	// checked for changes; zero leaves reloads to SIGHUP.
+	ConfigReloadInterval time.Duration
 }
 
 type RetryConfig struct {
 	MaxAttempts       int
 	InitialBackoff    time.Duration
 	MaxBackoff        time.Duration
-	BackoffMultiplier int
+	BackoffMultiplier float64
+	// Jitter is "full", "decorrelated" or "none".
+	Jitter string
+	// SubmitMaxAttempts bounds retries of SubmitIntent, which unlike
+	// ExecuteMint is not idempotent on-chain. Each retry first looks the
+	// intent up, and clients that cannot do so get one attempt.
+	SubmitMaxAttempts int
+	// BudgetRatio caps retries at this fraction of calls across the process;
+	// zero disables the budget. BudgetBurst is the retries allowed up front.
+	BudgetRatio float64
+	BudgetBurst int
 }
 
 type ChainConfig struct {
-	RPCURL     string
-	PrivateKey string
+	RPCURL string
+	// RPCTimeout bounds each JSON-RPC call (seed timeouts.rpcTimeoutMs).
+	RPCTimeout time.Duration
+	// RPCEndpoints lists every node the executor may use. It always holds at
+	// least RPCURL when that is set.
+	RPCEndpoints []RPCEndpoint
+	RPCHealth    RPCHealthConfig
+	PrivateKey   string
+	Balance      BalanceConfig
+	// Fake shapes the in-memory chain client used in dev mode without a key.
+	Fake FakeChainConfig
+}
+
+// FakeChainConfig makes the dev chain client slow or unreliable on purpose,
+// to demo retries, the circuit breaker and the DLQ without a node.
+type FakeChainConfig struct {
+	// Faults is a list of [method:]fault=rate rules, see escrow.ParseFaultRules.
+	Faults  string
+	Latency time.Duration
+	Jitter  time.Duration
+	Seed    uint64
+}
+
+// Enabled reports whether any fault or latency was asked for.
+func (f FakeChainConfig) Enabled() bool {
+	return f.Faults != "" || f.Latency > 0 || f.Jitter > 0
+}
+
+// RPCEndpoint is one chain node; lower Priority is preferred.
+type RPCEndpoint struct {
+	URL      string
+	Priority int
+}
+
+// RPCHealthConfig controls when a node is taken out of rotation.
+type RPCHealthConfig struct {
+	CheckInterval    time.Duration
+	MaxBlockLag      uint64
+	MaxLatency       time.Duration
+	FailureThreshold int
+}
+
+// BalanceConfig controls executor gas balance monitoring.
+type BalanceConfig struct {
+	CheckInterval time.Duration
+	MinBalanceWei *big.Int
+	GasPerTx      uint64
+}
+
+// RateLimitConfig sets token bucket sizes per minute; zero disables a scope.
+// API clients with their own rate limit override the Client values.
+type RateLimitConfig struct {
+	IPPerMinute     int
+	IPBurst         int
+	ClientPerMinute int
+	ClientBurst     int
+	UserPerMinute   int
+	UserBurst       int
+	// TrustedProxies is how many proxies in front of the API append to
+	// X-Forwarded-For. IP limits key on the address the outermost of them
+	// saw, counted from the right; zero ignores the header.
+	TrustedProxies int
+}
+
+// BreakerConfig controls the circuit breaker around the chain client; a zero
+// FailureThreshold disables it.
+type BreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenProbes   int
 }
 
 type DatabaseConfig struct {
 	URL string
+	// MigrateOnStart applies pending schema migrations at boot. When false the
+	// API refuses to start until `migrate up` has been run.
+	MigrateOnStart bool
+}
+
+// RedisConfig locates the Redis used by IDEMPOTENCY_BACKEND=redis.
+type RedisConfig struct {
+	URL string
+	// KeyPrefix namespaces keys when several deployments share one Redis.
+	KeyPrefix string
 }
 
 const (
 	defaultSeedPath        = "../seed.json"
 	defaultDeploymentsPath = "../deployments.json"
 	defaultDLQPath         = "../dlq"
+	defaultRetryQueuePath  = "../retry-queue"
+
+	defaultMinExecutorBalanceWei = "100000000000000000" // 0.1 ETH
+	defaultGasPerTx              = 150000
 )
 
-// Load aggregates configuration from disk and environment.
-func Load() (*AppConfig, error) {
-	seedPath := envOr("SEED_PATH", defaultSeedPath)
-	deploymentsPath := envOr("DEPLOYMENTS_PATH", defaultDeploymentsPath)
+// Load aggregates configuration from disk and environment and validates it,
+// running checks for the settings other packages own.
+func Load(checks Checks) (*AppConfig, error) {
+	return load(true, checks)
+}
+
+// LoadWithoutSigner is Load for tools that only read the chain, such as
+// verify-deployment: CHAIN_PRIVATE_KEY is optional in every run mode.
+func LoadWithoutSigner(checks Checks) (*AppConfig, error) {
+	return load(false, checks)
+}
+
+func load(requireSigner bool, checks Checks) (*AppConfig, error) {
+	env := &envReader{}
+	seedPath := env.or("SEED_PATH", defaultSeedPath)
+	deploymentsPath := env.or("DEPLOYMENTS_PATH", defaultDeploymentsPath)
 
 	seedCfg, err := loadSeed(seedPath)
 	if err != nil {
@@ -128,11 +314,29 @@ func Load() (*AppConfig, error) {
 	}
 
 	serviceCfg := ServiceConfig{
-		HTTPPort:             envOrInt("API_HTTP_PORT", 3000),
-		HMACClockSkew:        time.Duration(envOrInt("HMAC_CLOCK_SKEW_SECONDS", 60)) * time.Second,
-		IdempotencyWindow:    time.Duration(seedCfg.Timeouts.IdempotencyWindowSecs) * time.Second,
-		IdempotencyStorePath: envOr("IDEMPOTENCY_STORE_PATH", filepath.Join(os.TempDir(), "fiatrails-idem.json")),
-		DLQPath:              envOr("DLQ_PATH", defaultDLQPath),
+		HTTPPort:                 env.int("API_HTTP_PORT", 3000),
+		HTTPReadTimeout:          time.Duration(env.int("HTTP_READ_TIMEOUT_SECONDS", 15)) * time.Second,
+		HTTPWriteTimeout:         time.Duration(env.int("HTTP_WRITE_TIMEOUT_SECONDS", 60)) * time.Second,
+		HTTPIdleTimeout:          time.Duration(env.int("HTTP_IDLE_TIMEOUT_SECONDS", 120)) * time.Second,
+		WebhookTimeout:           time.Duration(env.int("WEBHOOK_TIMEOUT_MS", seedCfg.Timeouts.WebhookTimeoutMs)) * time.Millisecond,
+		HMACClockSkew:            time.Duration(env.int("HMAC_CLOCK_SKEW_SECONDS", 60)) * time.Second,
+		IdempotencyWindow:        time.Duration(seedCfg.Timeouts.IdempotencyWindowSecs) * time.Second,
+		IdempotencyBackend:       strings.ToLower(env.or("IDEMPOTENCY_BACKEND", "")),
+		IdempotencyStorePath:     env.or("IDEMPOTENCY_STORE_PATH", filepath.Join(os.TempDir(), "fiatrails-idem.json")),
+		IdempotencySweepInterval: time.Duration(env.int("IDEMPOTENCY_SWEEP_INTERVAL_SECONDS", 60)) * time.Second,
+		IdempotencySweepBatch:    env.int("IDEMPOTENCY_SWEEP_BATCH", 1000),
+		DLQPath:                  env.or("DLQ_PATH", defaultDLQPath),
+		RetryQueuePath:           env.or("RETRY_QUEUE_PATH", defaultRetryQueuePath),
+		RetryQueueInterval:       time.Duration(env.int("RETRY_QUEUE_INTERVAL_SECONDS", 15)) * time.Second,
+		RetryQueueMaxAttempts:    env.int("RETRY_QUEUE_MAX_ATTEMPTS", 20),
+		HMACKeyringPath:          env.or("HMAC_KEYRING_PATH", ""),
+		MpesaKeyringPath:         env.or("MPESA_KEYRING_PATH", ""),
+		KeyringReloadInterval:    time.Duration(env.int("KEYRING_RELOAD_SECONDS", 30)) * time.Second,
+		HMACDisableV1:            env.or("HMAC_DISABLE_V1", "") == "true",
+		APIClientsPath:           env.or("API_CLIENTS_PATH", ""),
+		RequireClientCredentials: env.or("REQUIRE_CLIENT_CREDENTIALS", "") == "true",
+		AdminToken:               env.or("ADMIN_TOKEN", ""),
+		ConfigReloadInterval:     time.Duration(env.int("CONFIG_RELOAD_SECONDS", 30)) * time.Second,
 	}
 
 	retryCfg := RetryConfig{
@@ -140,25 +344,140 @@ func Load() (*AppConfig, error) {
 		InitialBackoff:    time.Duration(seedCfg.Retry.InitialBackoffMs) * time.Millisecond,
 		MaxBackoff:        time.Duration(seedCfg.Retry.MaxBackoffMs) * time.Millisecond,
 		BackoffMultiplier: seedCfg.Retry.BackoffMultiplier,
+		Jitter:            env.or("RETRY_JITTER", "full"),
+		SubmitMaxAttempts: env.int("RETRY_SUBMIT_MAX_ATTEMPTS", 3),
+		BudgetRatio:       env.float("RETRY_BUDGET_RATIO", 0.2),
+		BudgetBurst:       env.int("RETRY_BUDGET_BURST", 20),
+	}
+
+	minBalance, ok := new(big.Int).SetString(env.or("EXECUTOR_MIN_BALANCE_WEI", defaultMinExecutorBalanceWei), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid EXECUTOR_MIN_BALANCE_WEI")
+	}
+
+	rpcURL := env.or("CHAIN_RPC_URL", seedCfg.Chain.RPCURL)
+	endpoints, err := parseRPCEndpoints(env.or("CHAIN_RPC_URLS", ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 && rpcURL != "" {
+		endpoints = []RPCEndpoint{{URL: rpcURL}}
+	}
+	if rpcURL == "" && len(endpoints) > 0 {
+		rpcURL = endpoints[0].URL
 	}
 
 	chainCfg := ChainConfig{
-		RPCURL:     envOr("CHAIN_RPC_URL", seedCfg.Chain.RPCURL),
-		PrivateKey: envOr("CHAIN_PRIVATE_KEY", ""),
+		RPCURL:       rpcURL,
+		RPCTimeout:   time.Duration(env.int("CHAIN_RPC_TIMEOUT_MS", seedCfg.Timeouts.RPCTimeoutMs)) * time.Millisecond,
+		RPCEndpoints: endpoints,
+		RPCHealth: RPCHealthConfig{
+			CheckInterval:    time.Duration(env.int("CHAIN_RPC_HEALTH_CHECK_SECONDS", 10)) * time.Second,
+			MaxBlockLag:      uint64(env.int("CHAIN_RPC_MAX_BLOCK_LAG", 5)),
+			MaxLatency:       time.Duration(env.int("CHAIN_RPC_MAX_LATENCY_MS", 2000)) * time.Millisecond,
+			FailureThreshold: env.int("CHAIN_RPC_FAILURE_THRESHOLD", 3),
+		},
+		PrivateKey: env.or("CHAIN_PRIVATE_KEY", ""),
+		Balance: BalanceConfig{
+			CheckInterval: time.Duration(env.int("EXECUTOR_BALANCE_CHECK_SECONDS", 60)) * time.Second,
+			MinBalanceWei: minBalance,
+			GasPerTx:      uint64(env.int("EXECUTOR_GAS_PER_TX", defaultGasPerTx)),
+		},
+		Fake: FakeChainConfig{
+			Faults:  env.or("FAKE_CHAIN_FAULTS", ""),
+			Latency: time.Duration(env.int("FAKE_CHAIN_LATENCY_MS", 0)) * time.Millisecond,
+			Jitter:  time.Duration(env.int("FAKE_CHAIN_LATENCY_JITTER_MS", 0)) * time.Millisecond,
+			Seed:    uint64(env.int("FAKE_CHAIN_SEED", 1)),
+		},
 	}
 
 	dbCfg := DatabaseConfig{
-		URL: envOr("DATABASE_URL", ""),
+		URL:            env.or("DATABASE_URL", ""),
+		MigrateOnStart: env.or("DB_MIGRATE_ON_START", "true") != "false",
 	}
 
-	return &AppConfig{
+	if serviceCfg.IdempotencyBackend == "" {
+		serviceCfg.IdempotencyBackend = "file"
+		if dbCfg.URL != "" {
+			serviceCfg.IdempotencyBackend = "postgres"
+		}
+	}
+
+	rateCfg := RateLimitConfig{
+		IPPerMinute:     env.int("RATE_LIMIT_IP_PER_MINUTE", 600),
+		IPBurst:         env.int("RATE_LIMIT_IP_BURST", 100),
+		ClientPerMinute: env.int("RATE_LIMIT_CLIENT_PER_MINUTE", 300),
+		ClientBurst:     env.int("RATE_LIMIT_CLIENT_BURST", 50),
+		UserPerMinute:   env.int("RATE_LIMIT_USER_PER_MINUTE", 30),
+		UserBurst:       env.int("RATE_LIMIT_USER_BURST", 10),
+	}
+	// RATE_LIMIT_TRUST_FORWARDED_FOR=true predates the hop count and meant one proxy.
+	legacyProxies := 0
+	if env.or("RATE_LIMIT_TRUST_FORWARDED_FOR", "") == "true" {
+		legacyProxies = 1
+	}
+	rateCfg.TrustedProxies = env.int("RATE_LIMIT_TRUSTED_PROXIES", legacyProxies)
+
+	cfg := &AppConfig{
+		Mode:       Mode(strings.ToLower(env.or("RUN_MODE", string(ModeProduction)))),
 		Seed:       *seedCfg,
 		Deployment: *deployCfg,
 		Service:    serviceCfg,
 		Retry:      retryCfg,
 		Chain:      chainCfg,
 		Database:   dbCfg,
-	}, nil
+		Redis: RedisConfig{
+			URL:       env.or("REDIS_URL", ""),
+			KeyPrefix: env.or("REDIS_KEY_PREFIX", "fiatrails:"),
+		},
+		RateLimit: rateCfg,
+		Breaker: BreakerConfig{
+			FailureThreshold: env.int("CIRCUIT_BREAKER_FAILURES", 5),
+			OpenTimeout:      time.Duration(env.int("CIRCUIT_BREAKER_OPEN_SECONDS", 30)) * time.Second,
+			HalfOpenProbes:   env.int("CIRCUIT_BREAKER_HALF_OPEN_PROBES", 1),
+		},
+		Source: Source{
+			SeedPath:        seedPath,
+			DeploymentsPath: deploymentsPath,
+			EnvOverrides:    env.set,
+			LoadedAt:        time.Now().UTC(),
+		},
+	}
+	problems := env.bad
+	var limitProblems []string
+	cfg.Limits, limitProblems = parseMintLimits(seedCfg)
+	if err := cfg.validate(append(problems, limitProblems...), requireSigner, checks); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseRPCEndpoints reads a comma-separated list of URLs in preference order.
+// A URL may carry an explicit priority as "url;priority=N"; otherwise its
+// position in the list is used.
+func parseRPCEndpoints(raw string) ([]RPCEndpoint, error) {
+	var out []RPCEndpoint
+	for i, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ep := RPCEndpoint{URL: item, Priority: i}
+		if url, opt, ok := strings.Cut(item, ";"); ok {
+			ep.URL = strings.TrimSpace(url)
+			p, found := strings.CutPrefix(strings.TrimSpace(opt), "priority=")
+			if !found {
+				return nil, fmt.Errorf("invalid CHAIN_RPC_URLS entry %q", item)
+			}
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority in CHAIN_RPC_URLS entry %q", item)
+			}
+			ep.Priority = n
+		}
+		out = append(out, ep)
+	}
+	return out, nil
 }
 
 func loadSeed(path string) (*SeedConfig, error) {
@@ -185,19 +504,45 @@ func loadDeployments(path string) (*DeploymentConfig, error) {
 	return &cfg, nil
 }
 
-func envOr(key, fallback string) string {
+// envReader reads settings from the environment and records which were set,
+// so the effective config can report its source.
+type envReader struct {
+	set []string
+	bad []string
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
 	if val, ok := os.LookupEnv(key); ok && val != "" {
+		e.set = append(e.set, key)
+		return val, true
+	}
+	return "", false
+}
+
+func (e *envReader) or(key, fallback string) string {
+	if val, ok := e.lookup(key); ok {
 		return val
 	}
 	return fallback
 }
 
-func envOrInt(key string, fallback int) int {
-	if val, ok := os.LookupEnv(key); ok && val != "" {
+func (e *envReader) float(key string, fallback float64) float64 {
+	if val, ok := e.lookup(key); ok {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+			return parsed
+		}
+		e.bad = append(e.bad, fmt.Sprintf("%s: %q is not a number", key, val))
+	}
+	return fallback
+}
+
+func (e *envReader) int(key string, fallback int) int {
+	if val, ok := e.lookup(key); ok {
 		var parsed int
 		if _, err := fmt.Sscanf(val, "%d", &parsed); err == nil {
 			return parsed
 		}
+		e.bad = append(e.bad, fmt.Sprintf("%s: %q is not an integer", key, val))
 	}
 	return fallback
 }
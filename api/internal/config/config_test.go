@@ -0,0 +1,308 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFiles(t *testing.T, seed, deployments string) {
+	t.Helper()
+	dir := t.TempDir()
+	seedPath := filepath.Join(dir, "seed.json")
+	deployPath := filepath.Join(dir, "deployments.json")
+	if err := os.WriteFile(seedPath, []byte(seed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(deployPath, []byte(deployments), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SEED_PATH", seedPath)
+	t.Setenv("DEPLOYMENTS_PATH", deployPath)
+	t.Setenv("RUN_MODE", "dev")
+}
+
+const validSeed = `{
+  "chain": {"chainId": 31337, "rpcUrl": "http://localhost:8545"},
+  "secrets": {"hmacSalt": "hmac-salt-value", "idempotencyKeySalt": "idem-salt-value", "mpesaWebhookSecret": "mpesa-secret-value"},
+  "limits": {"minMintAmount": "1", "maxMintAmount": "100"},
+  "retry": {"maxAttempts": 3, "initialBackoffMs": 100, "maxBackoffMs": 1000, "backoffMultiplier": 1.5},
+  "timeouts": {"rpcTimeoutMs": 5000, "webhookTimeoutMs": 3000, "idempotencyWindowSeconds": 60}
+}`
+
+const validDeployments = `{"chainId": 31337, "contracts": {"MintEscrow": "0x0165878A594ca255338adfa4d48449f69242Eb8F"}}`
+
+func TestLoadValidConfig(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	t.Setenv("RATE_LIMIT_IP_BURST", "7")
+
+	cfg, err := Load(Checks{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Retry.BackoffMultiplier != 1.5 {
+		t.Fatalf("expected fractional multiplier, got %v", cfg.Retry.BackoffMultiplier)
+	}
+	if cfg.Limits.Allows(big.NewInt(101)) || !cfg.Limits.Allows(big.NewInt(50)) {
+		t.Fatalf("limits not applied: %+v", cfg.Limits)
+	}
+	found := false
+	for _, k := range cfg.Source.EnvOverrides {
+		found = found || k == "RATE_LIMIT_IP_BURST"
+	}
+	if !found {
+		t.Fatalf("expected RATE_LIMIT_IP_BURST in env overrides, got %v", cfg.Source.EnvOverrides)
+	}
+}
+
+func TestLoadAggregatesProblems(t *testing.T) {
+	writeConfigFiles(t, `{
+  "chain": {"chainId": 1},
+  "secrets": {},
+  "limits": {"minMintAmount": "10", "maxMintAmount": "5"},
+  "retry": {"maxAttempts": 0, "initialBackoffMs": 0, "maxBackoffMs": 0, "backoffMultiplier": 0},
+  "timeouts": {"idempotencyWindowSeconds": 60}
+}`, `{"chainId": 2, "contracts": {"MintEscrow": ""}}`)
+	t.Setenv("CHAIN_PRIVATE_KEY", "0x"+strings.Repeat("ab", 32))
+	t.Setenv("RATE_LIMIT_USER_BURST", "ten")
+
+	_, err := Load(Checks{})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"RATE_LIMIT_USER_BURST",
+		"limits.minMintAmount is above",
+		"secrets.hmacSalt",
+		"secrets.mpesaWebhookSecret",
+		"retry.maxAttempts",
+		"retry.initialBackoffMs",
+		"retry.backoffMultiplier",
+		"contracts.MintEscrow is empty",
+		"no RPC endpoint",
+		"does not match seed chain.chainId",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadRefusesFakeChainOutsideDev(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	for _, mode := range []string{"production", "staging"} {
+		t.Setenv("RUN_MODE", mode)
+		if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), "CHAIN_PRIVATE_KEY is required in "+mode) {
+			t.Fatalf("%s: expected missing key to be rejected, got %v", mode, err)
+		}
+		if _, err := LoadWithoutSigner(Checks{}); err != nil {
+			t.Fatalf("%s: read-only tools must load without a key, got %v", mode, err)
+		}
+	}
+
+	t.Setenv("RUN_MODE", "")
+	t.Setenv("CHAIN_PRIVATE_KEY", "0x"+strings.Repeat("ab", 32))
+	cfg, err := Load(Checks{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Mode != ModeProduction {
+		t.Fatalf("expected production by default, got %q", cfg.Mode)
+	}
+
+	t.Setenv("RUN_MODE", "prod")
+	if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), `RUN_MODE "prod"`) {
+		t.Fatalf("expected unknown mode to be rejected, got %v", err)
+	}
+}
+
+func TestLoadFakeChainFaults(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	t.Setenv("FAKE_CHAIN_FAULTS", "transient=0.2,executeMint:not_compliant=0.05")
+	t.Setenv("FAKE_CHAIN_LATENCY_MS", "150")
+	cfg, err := Load(Checks{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Chain.Fake.Enabled() || cfg.Chain.Fake.Latency != 150*time.Millisecond {
+		t.Fatalf("unexpected fake chain config %+v", cfg.Chain.Fake)
+	}
+
+	t.Setenv("FAKE_CHAIN_FAULTS", "explode=0.2")
+	rejectFaults := Checks{Faults: func(spec string) error {
+		if spec != "explode=0.2" {
+			t.Fatalf("fault check got %q", spec)
+		}
+		return errors.New("unknown fault")
+	}}
+	if _, err := Load(rejectFaults); err == nil || !strings.Contains(err.Error(), "FAKE_CHAIN_FAULTS: unknown fault") {
+		t.Fatalf("expected bad fault spec to be rejected, got %v", err)
+	}
+
+	t.Setenv("FAKE_CHAIN_FAULTS", "transient=0.2")
+	t.Setenv("RUN_MODE", "staging")
+	t.Setenv("CHAIN_PRIVATE_KEY", "0x"+strings.Repeat("ab", 32))
+	if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), "FAKE_CHAIN_* settings only apply") {
+		t.Fatalf("expected fake chain settings outside dev to be rejected, got %v", err)
+	}
+}
+
+func TestLoadIdempotencyBackend(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	cfg, err := Load(Checks{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Service.IdempotencyBackend != "file" {
+		t.Fatalf("backend without a database = %q, want file", cfg.Service.IdempotencyBackend)
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://db:5432/fiatrails")
+	if cfg, err = Load(Checks{}); err != nil || cfg.Service.IdempotencyBackend != "postgres" {
+		t.Fatalf("backend with a database = %q (%v), want postgres", cfg.Service.IdempotencyBackend, err)
+	}
+
+	t.Setenv("IDEMPOTENCY_BACKEND", "Redis")
+	if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), "needs REDIS_URL") {
+		t.Fatalf("expected redis without REDIS_URL to be rejected, got %v", err)
+	}
+	t.Setenv("REDIS_URL", "redis://cache:6379/0")
+	if cfg, err = Load(Checks{}); err != nil || cfg.Service.IdempotencyBackend != "redis" || cfg.Redis.KeyPrefix != "fiatrails:" {
+		t.Fatalf("unexpected redis config %+v %+v (%v)", cfg.Service, cfg.Redis, err)
+	}
+
+	t.Setenv("IDEMPOTENCY_BACKEND", "memcached")
+	if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), "must be postgres, redis or file") {
+		t.Fatalf("expected unknown backend to be rejected, got %v", err)
+	}
+}
+
+func TestLoadChecksKeyrings(t *testing.T) {
+	writeConfigFiles(t, strings.Replace(validSeed, `"hmacSalt": "hmac-salt-value"`, `"hmacSalt": ""`, 1), validDeployments)
+	t.Setenv("HMAC_KEYRING_PATH", "/etc/fiatrails/keyring.json")
+
+	for _, tc := range []struct {
+		name   string
+		active int
+		err    error
+		want   string
+	}{
+		{"unloadable", 0, errors.New("no such file"), "HMAC_KEYRING_PATH: no such file"},
+		{"expired", 0, nil, "has no key valid now"},
+	} {
+		checks := Checks{Keyring: func(path string) (int, error) {
+			if path != "/etc/fiatrails/keyring.json" {
+				t.Fatalf("keyring check got %q", path)
+			}
+			return tc.active, tc.err
+		}}
+		if _, err := Load(checks); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s keyring: expected %q error, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	checks := Checks{Keyring: func(string) (int, error) { return 1, nil }}
+	if _, err := Load(checks); err != nil {
+		t.Fatalf("load with a valid keyring: %v", err)
+	}
+}
+
+func TestLoadChecksJitter(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	t.Setenv("RETRY_JITTER", "sometimes")
+	checks := Checks{Jitter: func(mode string) error {
+		if mode != "sometimes" {
+			t.Fatalf("jitter check got %q", mode)
+		}
+		return errors.New("unknown jitter mode")
+	}}
+	if _, err := Load(checks); err == nil || !strings.Contains(err.Error(), "RETRY_JITTER: unknown jitter mode") {
+		t.Fatalf("expected bad jitter to be rejected, got %v", err)
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	t.Setenv("RATE_LIMIT_TRUST_FORWARDED_FOR", "true")
+	if cfg, err := Load(Checks{}); err != nil || cfg.RateLimit.TrustedProxies != 1 {
+		t.Fatalf("legacy flag should mean one proxy, got %+v (%v)", cfg.RateLimit, err)
+	}
+	t.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "2")
+	if cfg, err := Load(Checks{}); err != nil || cfg.RateLimit.TrustedProxies != 2 {
+		t.Fatalf("expected 2 trusted proxies, got %+v (%v)", cfg.RateLimit, err)
+	}
+	t.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "-1")
+	if _, err := Load(Checks{}); err == nil || !strings.Contains(err.Error(), "RATE_LIMIT_TRUSTED_PROXIES") {
+		t.Fatalf("expected a negative hop count to be rejected, got %v", err)
+	}
+}
+
+func TestApplyReloadable(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	cur, err := Load(Checks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := *cur
+	next.Seed.Secrets.HMACSalt = "rotated"
+	next.RateLimit.IPBurst = 1
+	next.Retry.MaxAttempts = 9
+	next.Service.HTTPPort = 9999
+	next.Breaker.OpenTimeout = time.Hour
+
+	merged, restart := cur.ApplyReloadable(&next)
+	if merged.Seed.Secrets.HMACSalt != "rotated" || merged.RateLimit.IPBurst != 1 || merged.Retry.MaxAttempts != 9 {
+		t.Fatalf("reloadable settings not applied: %+v", merged)
+	}
+	if merged.Service.HTTPPort != cur.Service.HTTPPort || merged.Breaker.OpenTimeout != cur.Breaker.OpenTimeout {
+		t.Fatal("restart-only settings must keep their running values")
+	}
+	if strings.Join(restart, ",") != "service,breaker" {
+		t.Fatalf("restart = %v", restart)
+	}
+	if cur.Seed.Secrets.HMACSalt != "hmac-salt-value" {
+		t.Fatal("ApplyReloadable must not modify the running config")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	writeConfigFiles(t, validSeed, validDeployments)
+	t.Setenv("CHAIN_PRIVATE_KEY", "0x"+strings.Repeat("ab", 32))
+	t.Setenv("CHAIN_RPC_URLS", "https://rpc.example/v3/apikey123")
+	t.Setenv("DATABASE_URL", "postgres://app:hunter2@db:5432/fiatrails")
+	t.Setenv("ADMIN_TOKEN", "admintoken123")
+	cfg, err := Load(Checks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := cfg.Redacted()
+	dump := toString(out)
+	for _, secret := range []string{"hmac-salt-value", "idem-salt-value", "mpesa-secret-value", strings.Repeat("ab", 32), "apikey123", "hunter2", "admintoken123"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("redacted config leaks %q", secret)
+		}
+	}
+	chain := out["Chain"].(map[string]any)
+	if chain["PrivateKey"] != redacted {
+		t.Errorf("private key = %v", chain["PrivateKey"])
+	}
+	if chain["RPCTimeout"] != "5s" {
+		t.Errorf("durations should render as strings, got %v", chain["RPCTimeout"])
+	}
+	if db := out["Database"].(map[string]any)["URL"]; db != "postgres://db:5432" {
+		t.Errorf("database url = %v", db)
+	}
+}
+
+func toString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"math/big"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveFields are matched as substrings of lowercased field names.
+var sensitiveFields = []string{"secret", "salt", "privatekey", "password", "admintoken"}
+
+// Redacted renders the config as nested maps for display: secrets are
+// masked, URLs lose credentials, paths and query strings (provider URLs often
+// carry an API key there), and durations are shown as strings.
+func (c *AppConfig) Redacted() map[string]any {
+	out, _ := redactValue("", reflect.ValueOf(*c)).(map[string]any)
+	return out
+}
+
+func redactValue(name string, v reflect.Value) any {
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveFields {
+		if strings.Contains(lower, s) && v.Kind() != reflect.Struct {
+			if v.IsZero() {
+				return ""
+			}
+			return redacted
+		}
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		return x.String()
+	case time.Time:
+		return x
+	case *big.Int:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			key := f.Name
+			if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" && tag != "-" {
+				key = tag
+			}
+			out[key] = redactValue(f.Name, v.Field(i))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return []any{}
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redactValue(name, v.Index(i))
+		}
+		return out
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(name, v.Elem())
+	case reflect.String:
+		if strings.Contains(lower, "url") {
+			return redactURL(v.String())
+		}
+		return v.String()
+	}
+	return v.Interface()
+}
+
+// redactURL keeps only the scheme and host of a URL.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	return u.Scheme + "://" + u.Host
+}
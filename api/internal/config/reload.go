@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+)
+
+// ApplyReloadable returns a copy of c with the settings that are safe to
+// change at runtime taken from next: mint limits, retry policy, secrets and
+// rate limits. It also returns the sections that differ in next but only
+// take effect after a restart.
+func (c *AppConfig) ApplyReloadable(next *AppConfig) (*AppConfig, []string) {
+	merged := *c
+	merged.Seed.Limits = next.Seed.Limits
+	merged.Limits = next.Limits
+	merged.Seed.Retry = next.Seed.Retry
+	merged.Retry = next.Retry
+	merged.Seed.Secrets = next.Seed.Secrets
+	merged.RateLimit = next.RateLimit
+	merged.Source = next.Source
+
+	// Whatever still differs between merged and next was not applied.
+	var restart []string
+	if !reflect.DeepEqual(merged.Seed, next.Seed) {
+		restart = append(restart, "seed")
+	}
+	for _, section := range []struct {
+		name      string
+		cur, next any
+	}{
+		{"mode", merged.Mode, next.Mode},
+		{"deployment", merged.Deployment, next.Deployment},
+		{"service", merged.Service, next.Service},
+		{"chain", merged.Chain, next.Chain},
+		{"database", merged.Database, next.Database},
+		{"redis", merged.Redis, next.Redis},
+		{"breaker", merged.Breaker, next.Breaker},
+	} {
+		if !reflect.DeepEqual(section.cur, section.next) {
+			restart = append(restart, section.name)
+		}
+	}
+	return &merged, restart
+}
+
+// Files returns the config files a running service should watch.
+func (s Source) Files() []string {
+	var files []string
+	for _, f := range []string{s.SeedPath, s.DeploymentsPath} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Watch polls files and calls onChange whenever one of their modification
+// times or sizes changes, until ctx is cancelled.
+func Watch(ctx context.Context, interval time.Duration, files []string, onChange func()) {
+	if interval <= 0 || len(files) == 0 {
+		return
+	}
+	last := fileStamps(files)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fileStamps(files)
+			if !reflect.DeepEqual(current, last) {
+				last = current
+				onChange()
+			}
+		}
+	}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func fileStamps(files []string) []fileStamp {
+	out := make([]fileStamp, len(files))
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			out[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return out
+}
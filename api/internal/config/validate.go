@@ -0,0 +1,263 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ValidationError lists every problem found in a config, so an operator can
+// fix them in one pass instead of one restart per mistake.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Checks are the validations owned by other packages: keyring files, retry
+// jitter modes and fake chain fault rules. They are passed in so config does
+// not import those packages. A nil check is skipped.
+type Checks struct {
+	// Keyring loads the keyring at path and returns how many keys are
+	// valid now.
+	Keyring func(path string) (int, error)
+	// Jitter parses a RETRY_JITTER value.
+	Jitter func(mode string) error
+	// Faults parses a FAKE_CHAIN_FAULTS value.
+	Faults func(spec string) error
+}
+
+// Validate reports incoherent settings as a *ValidationError.
+func (c *AppConfig) Validate(checks Checks) error {
+	return c.validate(nil, true, checks)
+}
+
+func (c *AppConfig) validate(problems []string, requireSigner bool, checks Checks) error {
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Run mode
+	if !c.Mode.valid() {
+		add("RUN_MODE %q must be production, staging or dev", c.Mode)
+	}
+	if requireSigner && !c.Mode.AllowsFakes() && c.Chain.PrivateKey == "" {
+		add("CHAIN_PRIVATE_KEY is required in %s mode; the fake chain client is only allowed with RUN_MODE=dev", c.Mode)
+	}
+
+	// Secrets. A keyring replaces the seed secret, so it must load and hold
+	// a key that is valid now.
+	for _, s := range []struct {
+		secret, secretName, keyring, keyringName string
+	}{
+		{c.Seed.Secrets.HMACSalt, "secrets.hmacSalt", c.Service.HMACKeyringPath, "HMAC_KEYRING_PATH"},
+		{c.Seed.Secrets.MpesaWebhookSecret, "secrets.mpesaWebhookSecret", c.Service.MpesaKeyringPath, "MPESA_KEYRING_PATH"},
+	} {
+		if s.keyring == "" {
+			if s.secret == "" {
+				add("%s is empty and %s is not set", s.secretName, s.keyringName)
+			}
+			continue
+		}
+		if checks.Keyring == nil {
+			continue
+		}
+		active, err := checks.Keyring(s.keyring)
+		if err != nil {
+			add("%s: %v", s.keyringName, err)
+			continue
+		}
+		if active == 0 {
+			add("%s %s has no key valid now", s.keyringName, s.keyring)
+		}
+	}
+
+	// Retry policy
+	if c.Retry.MaxAttempts < 1 {
+		add("retry.maxAttempts must be at least 1, got %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.InitialBackoff <= 0 {
+		add("retry.initialBackoffMs must be positive")
+	}
+	if c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		add("retry.maxBackoffMs (%s) is below retry.initialBackoffMs (%s)", c.Retry.MaxBackoff, c.Retry.InitialBackoff)
+	}
+	if c.Retry.BackoffMultiplier < 1 {
+		add("retry.backoffMultiplier must be at least 1, got %g", c.Retry.BackoffMultiplier)
+	}
+	if checks.Jitter != nil {
+		if err := checks.Jitter(c.Retry.Jitter); err != nil {
+			add("RETRY_JITTER: %v", err)
+		}
+	}
+	if c.Retry.SubmitMaxAttempts < 1 {
+		add("RETRY_SUBMIT_MAX_ATTEMPTS must be at least 1, got %d", c.Retry.SubmitMaxAttempts)
+	}
+	if c.Retry.BudgetRatio < 0 {
+		add("RETRY_BUDGET_RATIO must not be negative, got %g", c.Retry.BudgetRatio)
+	}
+	if c.Retry.BudgetRatio > 0 && c.Retry.BudgetBurst < 1 {
+		add("RETRY_BUDGET_BURST must be at least 1 when the retry budget is enabled")
+	}
+
+	// Chain and deployment
+	escrowAddr := c.Deployment.Contracts.MintEscrow
+	switch {
+	case escrowAddr == "" && c.Chain.PrivateKey != "":
+		add("contracts.MintEscrow is empty in deployments.json but CHAIN_PRIVATE_KEY is set")
+	case escrowAddr != "" && !isNonZeroAddress(escrowAddr):
+		add("contracts.MintEscrow %q is not a valid contract address", escrowAddr)
+	}
+	if c.Chain.PrivateKey != "" {
+		if !isPrivateKeyHex(c.Chain.PrivateKey) {
+			add("CHAIN_PRIVATE_KEY must be 32 bytes of hex")
+		}
+		if len(c.Chain.RPCEndpoints) == 0 {
+			add("no RPC endpoint: set chain.rpcUrl, CHAIN_RPC_URL or CHAIN_RPC_URLS")
+		}
+	}
+	for _, ep := range c.Chain.RPCEndpoints {
+		u, err := url.Parse(ep.URL)
+		if err != nil || u.Host == "" || !validRPCScheme(u.Scheme) {
+			add("RPC endpoint %q must be an http(s) or ws(s) URL", redactURL(ep.URL))
+		}
+	}
+	if c.Seed.Chain.ChainID != 0 && c.Deployment.ChainID != 0 && c.Seed.Chain.ChainID != c.Deployment.ChainID {
+		add("deployments.json chainId %d does not match seed chain.chainId %d", c.Deployment.ChainID, c.Seed.Chain.ChainID)
+	}
+	if fake := c.Chain.Fake; fake.Enabled() {
+		if !c.Mode.AllowsFakes() || c.Chain.PrivateKey != "" {
+			add("FAKE_CHAIN_* settings only apply to the fake chain client (RUN_MODE=dev without CHAIN_PRIVATE_KEY)")
+		}
+		if checks.Faults != nil {
+			if err := checks.Faults(fake.Faults); err != nil {
+				add("FAKE_CHAIN_FAULTS: %v", err)
+			}
+		}
+		if fake.Latency < 0 || fake.Jitter < 0 {
+			add("FAKE_CHAIN_LATENCY_MS and FAKE_CHAIN_LATENCY_JITTER_MS must not be negative")
+		}
+	}
+	if c.Chain.Balance.MinBalanceWei != nil && c.Chain.Balance.MinBalanceWei.Sign() < 0 {
+		add("EXECUTOR_MIN_BALANCE_WEI must not be negative")
+	}
+
+	// Service
+	if c.Service.HTTPPort < 0 || c.Service.HTTPPort > 65535 {
+		add("API_HTTP_PORT %d is out of range", c.Service.HTTPPort)
+	}
+	if c.Service.IdempotencyWindow <= 0 {
+		add("timeouts.idempotencyWindowSeconds must be positive")
+	}
+	if c.Service.WebhookTimeout > 0 && c.Service.HTTPWriteTimeout > 0 && c.Service.HTTPWriteTimeout <= c.Service.WebhookTimeout {
+		add("HTTP_WRITE_TIMEOUT_SECONDS (%s) must exceed the webhook timeout (%s)", c.Service.HTTPWriteTimeout, c.Service.WebhookTimeout)
+	}
+	if c.Service.RetryQueueMaxAttempts < 0 {
+		add("RETRY_QUEUE_MAX_ATTEMPTS must not be negative")
+	}
+	switch c.Service.IdempotencyBackend {
+	case "file":
+	case "postgres":
+		if c.Database.URL == "" {
+			add("IDEMPOTENCY_BACKEND=postgres needs DATABASE_URL")
+		}
+	case "redis":
+		if c.Redis.URL == "" {
+			add("IDEMPOTENCY_BACKEND=redis needs REDIS_URL")
+		}
+	default:
+		add("IDEMPOTENCY_BACKEND %q must be postgres, redis or file", c.Service.IdempotencyBackend)
+	}
+	if c.Service.IdempotencySweepBatch < 0 {
+		add("IDEMPOTENCY_SWEEP_BATCH must not be negative")
+	}
+
+	// Rate limits
+	rl := c.RateLimit
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"RATE_LIMIT_IP_PER_MINUTE", rl.IPPerMinute},
+		{"RATE_LIMIT_IP_BURST", rl.IPBurst},
+		{"RATE_LIMIT_CLIENT_PER_MINUTE", rl.ClientPerMinute},
+		{"RATE_LIMIT_CLIENT_BURST", rl.ClientBurst},
+		{"RATE_LIMIT_USER_PER_MINUTE", rl.UserPerMinute},
+		{"RATE_LIMIT_USER_BURST", rl.UserBurst},
+		{"RATE_LIMIT_TRUSTED_PROXIES", rl.TrustedProxies},
+	} {
+		if v.value < 0 {
+			add("%s must not be negative", v.name)
+		}
+	}
+
+	// Circuit breaker
+	if c.Breaker.FailureThreshold < 0 {
+		add("CIRCUIT_BREAKER_FAILURES must not be negative")
+	}
+	if c.Breaker.FailureThreshold > 0 && c.Breaker.HalfOpenProbes < 1 {
+		add("CIRCUIT_BREAKER_HALF_OPEN_PROBES must be at least 1")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// parseMintLimits reads the seed limits; empty values leave a bound unset.
+func parseMintLimits(seed *SeedConfig) (MintLimits, []string) {
+	var limits MintLimits
+	var problems []string
+	parse := func(name, v string) *big.Int {
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok || n.Sign() < 0 {
+			problems = append(problems, fmt.Sprintf("limits.%s %q is not a non-negative integer", name, v))
+			return nil
+		}
+		return n
+	}
+	limits.Min = parse("minMintAmount", seed.Limits.MinMintAmount)
+	limits.Max = parse("maxMintAmount", seed.Limits.MaxMintAmount)
+	if limits.Min != nil && limits.Max != nil && limits.Min.Cmp(limits.Max) > 0 {
+		problems = append(problems, "limits.minMintAmount is above limits.maxMintAmount")
+	}
+	return limits, problems
+}
+
+// Allows reports whether amount is within the limits.
+func (l MintLimits) Allows(amount *big.Int) bool {
+	if l.Min != nil && amount.Cmp(l.Min) < 0 {
+		return false
+	}
+	if l.Max != nil && amount.Cmp(l.Max) > 0 {
+		return false
+	}
+	return true
+}
+
+func isNonZeroAddress(s string) bool {
+	return common.IsHexAddress(s) && common.HexToAddress(s) != (common.Address{})
+}
+
+func isPrivateKeyHex(s string) bool {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	return err == nil && len(b) == 32
+}
+
+func validRPCScheme(scheme string) bool {
+	switch scheme {
+	case "http", "https", "ws", "wss":
+		return true
+	}
+	return false
+}
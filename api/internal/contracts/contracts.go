@@ -4,3 +4,9 @@ import _ "embed"
 
 //go:embed MintEscrow.abi.json
 var MintEscrowABI []byte
+
+// ViewsABI holds the read-only ERC-20, AccessControl and ERC-165 functions
+// shared by the other FiatRails contracts, for deployment verification.
+//
+//go:embed Views.abi.json
+var ViewsABI []byte
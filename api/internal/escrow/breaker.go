@@ -0,0 +1,210 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrCircuitOpen is returned without calling the chain while the breaker is
+// open. Callers should park the work and retry later rather than give up on it.
+var ErrCircuitOpen = errors.New("escrow circuit open")
+
+// ErrInvalidRequest matches errors caused by the request itself; they never
+// count against the breaker.
+var ErrInvalidRequest = errors.New("invalid escrow request")
+
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string        { return e.msg }
+func (e *requestError) Is(target error) bool { return target == ErrInvalidRequest }
+
+func invalidRequest(format string, args ...interface{}) error {
+	return &requestError{msg: fmt.Sprintf(format, args...)}
+}
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures open the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is how many calls may probe the chain at once while half-open.
+	HalfOpenProbes int
+}
+
+// BreakerStatus is a snapshot for health output.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Breaker wraps a Client and stops calling it after repeated outages, so a
+// node failure is not multiplied by every caller's retry budget. Only outages
+// count: reverts and other answers from the node, invalid requests and
+// cancelled contexts leave the breaker alone.
+type Breaker struct {
+	next Client
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	// OnStateChange, if set, is called after every transition.
+	OnStateChange func(from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+	lastErr  string
+}
+
+func NewBreaker(next Client, cfg BreakerConfig) *Breaker {
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &Breaker{next: next, cfg: cfg, now: time.Now}
+}
+
+func (b *Breaker) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (SubmitIntentResponse, error) {
+	probe, err := b.acquire()
+	if err != nil {
+		return SubmitIntentResponse{}, err
+	}
+	resp, err := b.next.SubmitIntent(ctx, req)
+	b.record(ctx, probe, err)
+	return resp, err
+}
+
+func (b *Breaker) ExecuteMint(ctx context.Context, intentID string) (ExecuteMintResponse, error) {
+	probe, err := b.acquire()
+	if err != nil {
+		return ExecuteMintResponse{}, err
+	}
+	resp, err := b.next.ExecuteMint(ctx, intentID)
+	b.record(ctx, probe, err)
+	return resp, err
+}
+
+// State reports the current state, moving an expired open circuit to half-open.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked()
+	return b.state
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked()
+	st := BreakerStatus{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastErr,
+	}
+	if b.state != BreakerClosed {
+		opened := b.openedAt.UTC()
+		st.OpenedAt = &opened
+	}
+	return st
+}
+
+func (b *Breaker) acquire() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked()
+	switch b.state {
+	case BreakerOpen:
+		return false, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *Breaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	if probe {
+		b.probes--
+	}
+	var from, to BreakerState
+	changed := false
+	switch {
+	case !isOutage(ctx, err):
+		b.failures = 0
+		if b.state == BreakerHalfOpen && probe {
+			from, to, changed = b.state, BreakerClosed, true
+			b.state = BreakerClosed
+			b.lastErr = ""
+		}
+	default:
+		b.failures++
+		b.lastErr = err.Error()
+		if (b.state == BreakerHalfOpen && probe) || (b.state == BreakerClosed && b.failures >= b.cfg.FailureThreshold) {
+			from, to, changed = b.state, BreakerOpen, true
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	}
+	b.mu.Unlock()
+
+	if changed && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
+
+// expireLocked moves an open circuit to half-open once the timeout passes.
+// OnStateChange is not called for this transition; it happens lazily on the
+// next call or status read.
+func (b *Breaker) expireLocked() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = BreakerHalfOpen
+		b.probes = 0
+	}
+}
+
+// isOutage reports whether err means the chain could not be reached or used.
+func isOutage(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) || errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return true
+}
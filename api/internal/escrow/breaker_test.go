@@ -0,0 +1,109 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type scriptedClient struct {
+	errs  []error
+	calls int
+}
+
+func (c *scriptedClient) SubmitIntent(context.Context, SubmitIntentRequest) (SubmitIntentResponse, error) {
+	return SubmitIntentResponse{}, c.next()
+}
+
+func (c *scriptedClient) ExecuteMint(context.Context, string) (ExecuteMintResponse, error) {
+	return ExecuteMintResponse{TxHash: "0xhash"}, c.next()
+}
+
+func (c *scriptedClient) next() error {
+	i := c.calls
+	c.calls++
+	if i < len(c.errs) {
+		return c.errs[i]
+	}
+	return nil
+}
+
+type fakeRPCError struct{}
+
+func (fakeRPCError) Error() string  { return "execution reverted" }
+func (fakeRPCError) ErrorCode() int { return 3 }
+
+func TestBreakerOpensAndShortCircuits(t *testing.T) {
+	down := errors.New("dial tcp: connection refused")
+	next := &scriptedClient{errs: []error{down, down, down}}
+	b := NewBreaker(next, BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	var transitions []string
+	b.OnStateChange = func(from, to BreakerState) { transitions = append(transitions, from.String()+"->"+to.String()) }
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.ExecuteMint(context.Background(), "0x1"); !errors.Is(err, down) {
+			t.Fatalf("call %d: expected underlying error, got %v", i, err)
+		}
+	}
+	if _, err := b.ExecuteMint(context.Background(), "0x1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if next.calls != 2 {
+		t.Fatalf("open circuit must not call the client, got %d calls", next.calls)
+	}
+	if b.State() != BreakerOpen || len(transitions) != 1 || transitions[0] != "closed->open" {
+		t.Fatalf("unexpected state %v transitions %v", b.State(), transitions)
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	down := errors.New("i/o timeout")
+	next := &scriptedClient{errs: []error{down, down}}
+	b := NewBreaker(next, BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second})
+	now := time.Unix(1_700_000_000, 0)
+	b.now = func() time.Time { return now }
+
+	_, _ = b.ExecuteMint(context.Background(), "0x1")
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open, got %v", b.State())
+	}
+
+	now = now.Add(time.Second)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open after timeout, got %v", b.State())
+	}
+	// A failed probe reopens the circuit and restarts the timeout.
+	if _, err := b.ExecuteMint(context.Background(), "0x1"); !errors.Is(err, down) {
+		t.Fatalf("expected probe to reach client, got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected reopen after failed probe, got %v", b.State())
+	}
+
+	now = now.Add(time.Second)
+	if _, err := b.ExecuteMint(context.Background(), "0x1"); err != nil {
+		t.Fatalf("expected successful probe, got %v", err)
+	}
+	if b.State() != BreakerClosed || b.Status().ConsecutiveFailures != 0 {
+		t.Fatalf("expected closed after successful probe, got %+v", b.Status())
+	}
+}
+
+func TestBreakerIgnoresNodeAnswersAndBadRequests(t *testing.T) {
+	next := &scriptedClient{errs: []error{fakeRPCError{}, invalidRequest("invalid intent id"), fakeRPCError{}}}
+	b := NewBreaker(next, BreakerConfig{FailureThreshold: 1})
+
+	for i := 0; i < 3; i++ {
+		_, _ = b.ExecuteMint(context.Background(), "0x1")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next.errs = append(next.errs, context.Canceled)
+	_, _ = b.ExecuteMint(ctx, "0x1")
+
+	if b.State() != BreakerClosed {
+		t.Fatalf("reverts, invalid requests and cancellations must not open the circuit")
+	}
+}
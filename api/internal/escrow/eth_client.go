@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"fiatrails/internal/contracts"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/tracing"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -16,12 +18,13 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// EthClient submits transactions to MintEscrow.
+// EthClient submits transactions to MintEscrow through a pool of RPC nodes.
 type EthClient struct {
-	client    *ethclient.Client
-	contract  *bind.BoundContract
+	pool      *rpcPool
 	abi       abi.ABI
 	address   common.Address
 	chainID   *big.Int
@@ -29,23 +32,33 @@ type EthClient struct {
 }
 
 type EthClientConfig struct {
-	RPCURL             string
+	RPCURL string
+	// Endpoints, when set, replaces RPCURL with several nodes to fail over between.
+	Endpoints []Endpoint
+	Health    HealthSettings
+	// CallTimeout bounds each JSON-RPC call; zero leaves calls to the caller's context.
+	CallTimeout        time.Duration
 	PrivateKeyHex      string
 	ContractMintEscrow string
+	// Metrics, when set, records every JSON-RPC call made over HTTP.
+	Metrics *RPCMetrics
 }
 
 func NewEthClient(ctx context.Context, cfg EthClientConfig) (*EthClient, error) {
-	if cfg.RPCURL == "" {
+	endpoints := cfg.Endpoints
+	if len(endpoints) == 0 && cfg.RPCURL != "" {
+		endpoints = []Endpoint{{URL: cfg.RPCURL}}
+	}
+	if len(endpoints) == 0 {
 		return nil, fmt.Errorf("rpc url is required")
 	}
 	if cfg.ContractMintEscrow == "" {
 		return nil, fmt.Errorf("mint escrow address is required")
 	}
 
-	cli, err := ethclient.DialContext(ctx, cfg.RPCURL)
-	// Eth client call to remote.
+	pool, err := newRPCPool(ctx, endpoints, cfg.Health, cfg.CallTimeout, cfg.Metrics)
 	if err != nil {
-		return nil, fmt.Errorf("dial rpc: %w", err)
+		return nil, err
 	}
 
 	parsedABI, err := abi.JSON(strings.NewReader(string(contracts.MintEscrowABI)))
@@ -54,7 +67,6 @@ func NewEthClient(ctx context.Context, cfg EthClientConfig) (*EthClient, error)
 	}
 
 	address := common.HexToAddress(cfg.ContractMintEscrow)
-	bound := bind.NewBoundContract(address, parsedABI, cli, cli, cli)
 
 	var txOpts *bind.TransactOpts
 	if cfg.PrivateKeyHex != "" {
@@ -63,10 +75,16 @@ func NewEthClient(ctx context.Context, cfg EthClientConfig) (*EthClient, error)
 			return nil, err
 		}
 
-		chainID, err := cli.ChainID(ctx)
+		var chainID *big.Int
+		err = pool.call(ctx, func(cli *ethclient.Client) (err error) {
+			chainID, err = cli.ChainID(ctx)
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("fetch chain id: %w", err)
 		}
+		pool.expectChainID(chainID)
+		pool.checkAll(ctx)
 
 		txOpts, err = bind.NewKeyedTransactorWithChainID(pk, chainID)
 		if err != nil {
@@ -78,8 +96,7 @@ func NewEthClient(ctx context.Context, cfg EthClientConfig) (*EthClient, error)
 		txOpts.GasPrice = nil
 		txOpts.Nonce = nil
 		return &EthClient{
-			client:    cli,
-			contract:  bound,
+			pool:      pool,
 			abi:       parsedABI,
 			address:   address,
 			chainID:   chainID,
@@ -99,7 +116,19 @@ func parsePrivateKey(hexKey string) (*ecdsa.PrivateKey, error) {
 	return key, nil
 }
 
-func (c *EthClient) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (SubmitIntentResponse, error) {
+// AddressFromKey returns the account address of a hex private key.
+func AddressFromKey(hexKey string) (string, error) {
+	key, err := parsePrivateKey(hexKey)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey).Hex(), nil
+}
+
+func (c *EthClient) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (_ SubmitIntentResponse, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.SubmitIntent")
+	defer func() { tracing.End(span, err) }()
+
 	if c.transacts == nil {
 		return SubmitIntentResponse{}, fmt.Errorf("client is read-only")
 	}
@@ -109,24 +138,27 @@ func (c *EthClient) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (
 
 	amount, ok := new(big.Int).SetString(req.Amount, 10)
 	if !ok {
-		return SubmitIntentResponse{}, fmt.Errorf("invalid amount: %s", req.Amount)
+		return SubmitIntentResponse{}, invalidRequest("invalid amount: %s", req.Amount)
 	}
 
 	countryCodeBytes := toBytes32(req.CountryCode)
 	txRefBytes := toBytes32(req.TxRef)
 
-	opts := *c.transacts
-	opts.Context = ctx
-
-	tx, err := c.contract.Transact(&opts, "submitIntent", amount, countryCodeBytes, txRefBytes)
+	tx, err := c.transact(ctx, "submitIntent", amount, countryCodeBytes, txRefBytes)
 	if err != nil {
 		return SubmitIntentResponse{}, fmt.Errorf("submit intent tx: %w", err)
 	}
 
-	intentID, err := computeIntentID(req)
+	intentID, err := computeIntentID(c.transacts.From, req)
 	if err != nil {
 		return SubmitIntentResponse{}, err
 	}
+	span.SetAttributes(attribute.String("intent.id", intentID), attribute.String("tx.hash", tx.Hash().Hex()))
+	logging.FromContext(ctx).Debug("submitIntent sent",
+		logging.KeyIntentID, intentID,
+		logging.KeyTxHash, tx.Hash().Hex(),
+		"nonce", tx.Nonce(),
+	)
 
 	return SubmitIntentResponse{
 		IntentID: intentID,
@@ -134,66 +166,252 @@ func (c *EthClient) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (
 	}, nil
 }
 
-func (c *EthClient) ExecuteMint(ctx context.Context, intentID string) (ExecuteMintResponse, error) {
+// intentStatusExecuted is MintStatus.Executed in IMintEscrow.
+const intentStatusExecuted = 1
+
+// onChainIntent is MintEscrow.getIntent's MintIntent tuple.
+type onChainIntent struct {
+	User        common.Address
+	Amount      *big.Int
+	CountryCode [32]byte
+	TxRef       [32]byte
+	Timestamp   *big.Int
+	Status      uint8
+}
+
+// FindIntent reports whether the intent req would create already exists.
+// The contract zero-fills unknown IDs, so a zero timestamp means not found.
+func (c *EthClient) FindIntent(ctx context.Context, req SubmitIntentRequest) (_ string, _ bool, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.FindIntent", trace.WithAttributes(attribute.String("tx.ref", req.TxRef)))
+	defer func() { tracing.End(span, err) }()
+
+	if c.transacts == nil {
+		return "", false, fmt.Errorf("client is read-only")
+	}
+	if err := validateSubmitRequest(req); err != nil {
+		return "", false, err
+	}
+	intentID, err := computeIntentID(c.transacts.From, req)
+	if err != nil {
+		return "", false, err
+	}
+
+	intent, err := c.getIntent(ctx, intentID)
+	if err != nil {
+		return "", false, err
+	}
+	if intent.Timestamp == nil || intent.Timestamp.Sign() == 0 {
+		return intentID, false, nil
+	}
+	return intentID, true, nil
+}
+
+// IntentExecuted reports whether the intent has already been minted.
+func (c *EthClient) IntentExecuted(ctx context.Context, intentID string) (_ bool, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.IntentExecuted", trace.WithAttributes(attribute.String("intent.id", intentID)))
+	defer func() { tracing.End(span, err) }()
+
+	if len(intentID) != 66 || !strings.HasPrefix(intentID, "0x") {
+		return false, invalidRequest("invalid intent id")
+	}
+	intent, err := c.getIntent(ctx, intentID)
+	if err != nil {
+		return false, err
+	}
+	return intent.Status == intentStatusExecuted, nil
+}
+
+func (c *EthClient) getIntent(ctx context.Context, intentID string) (onChainIntent, error) {
+	var out []interface{}
+	err := c.pool.call(ctx, func(cli *ethclient.Client) error {
+		out = nil
+		contract := bind.NewBoundContract(c.address, c.abi, cli, cli, cli)
+		return contract.Call(&bind.CallOpts{Context: ctx}, &out, "getIntent", common.HexToHash(intentID))
+	})
+	if err != nil {
+		return onChainIntent{}, fmt.Errorf("get intent: %w", err)
+	}
+	return *abi.ConvertType(out[0], new(onChainIntent)).(*onChainIntent), nil
+}
+
+func (c *EthClient) ExecuteMint(ctx context.Context, intentID string) (_ ExecuteMintResponse, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.ExecuteMint", trace.WithAttributes(attribute.String("intent.id", intentID)))
+	defer func() { tracing.End(span, err) }()
+
 	if c.transacts == nil {
 		return ExecuteMintResponse{}, fmt.Errorf("client is read-only")
 	}
 	if len(intentID) != 66 || !strings.HasPrefix(intentID, "0x") {
-		return ExecuteMintResponse{}, fmt.Errorf("invalid intent id")
+		return ExecuteMintResponse{}, invalidRequest("invalid intent id")
 	}
 
 	hash := common.HexToHash(intentID)
 
-	opts := *c.transacts
-	opts.Context = ctx
-
-	tx, err := c.contract.Transact(&opts, "executeMint", hash)
+	tx, err := c.transact(ctx, "executeMint", hash)
 	if err != nil {
 		return ExecuteMintResponse{}, fmt.Errorf("execute mint tx: %w", err)
 	}
+	span.SetAttributes(attribute.String("tx.hash", tx.Hash().Hex()))
+	logging.FromContext(ctx).Debug("executeMint sent",
+		logging.KeyIntentID, intentID,
+		logging.KeyTxHash, tx.Hash().Hex(),
+		"nonce", tx.Nonce(),
+	)
 
 	return ExecuteMintResponse{TxHash: tx.Hash().Hex()}, nil
 }
 
+// RefundIntent returns the stablecoin held for a pending intent to the
+// account that deposited it. It is not part of Client: refunds are an
+// operator decision, not something a callback triggers.
+func (c *EthClient) RefundIntent(ctx context.Context, intentID, reason string) (_ ExecuteMintResponse, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.RefundIntent", trace.WithAttributes(attribute.String("intent.id", intentID)))
+	defer func() { tracing.End(span, err) }()
+
+	if c.transacts == nil {
+		return ExecuteMintResponse{}, fmt.Errorf("client is read-only")
+	}
+	if len(intentID) != 66 || !strings.HasPrefix(intentID, "0x") {
+		return ExecuteMintResponse{}, invalidRequest("invalid intent id")
+	}
+
+	tx, err := c.transact(ctx, "refundIntent", common.HexToHash(intentID), reason)
+	if err != nil {
+		return ExecuteMintResponse{}, fmt.Errorf("refund intent tx: %w", err)
+	}
+	span.SetAttributes(attribute.String("tx.hash", tx.Hash().Hex()))
+	logging.FromContext(ctx).Debug("refundIntent sent",
+		logging.KeyIntentID, intentID,
+		logging.KeyTxHash, tx.Hash().Hex(),
+		"nonce", tx.Nonce(),
+	)
+
+	return ExecuteMintResponse{TxHash: tx.Hash().Hex()}, nil
+}
+
+// transact builds, signs and sends a contract call. Nonce lookup, gas
+// estimation and signing all happen against one node so the transaction is
+// consistent; only the finished signed bytes may be rebroadcast elsewhere.
+func (c *EthClient) transact(ctx context.Context, method string, args ...interface{}) (*types.Transaction, error) {
+	ep := c.pool.pick()
+	contract := bind.NewBoundContract(c.address, c.abi, ep.client, ep.client, ep.client)
+
+	opts := *c.transacts
+	opts.Context = ctx
+	opts.NoSend = true
+
+	tx, err := contract.Transact(&opts, method, args...)
+	if err != nil {
+		if isEndpointFailure(ctx, err) {
+			c.pool.failed(ctx, ep, err)
+		}
+		return nil, err
+	}
+	if err := c.pool.broadcast(ctx, tx, ep); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
 func (c *EthClient) Ping(ctx context.Context) error {
-	if c.client == nil {
+	if c.pool == nil {
 		return fmt.Errorf("rpc client not configured")
 	}
-	_, err := c.client.BlockNumber(ctx)
-	return err
+	return c.pool.call(ctx, func(cli *ethclient.Client) error {
+		_, err := cli.BlockNumber(ctx)
+		return err
+	})
+}
+
+// Endpoints reports the health of every configured RPC node.
+func (c *EthClient) Endpoints() []EndpointStatus {
+	return c.pool.statuses()
+}
+
+// MonitorEndpoints health-checks the RPC nodes until ctx is cancelled.
+func (c *EthClient) MonitorEndpoints(ctx context.Context) {
+	c.pool.monitor(ctx)
+}
+
+// Balances returns the native balance of the executor account along with the
+// node's suggested gas price so callers can estimate how many transactions remain.
+func (c *EthClient) Balances(ctx context.Context) ([]AccountBalance, error) {
+	if c.transacts == nil {
+		return nil, fmt.Errorf("client is read-only")
+	}
+
+	var gasPrice *big.Int
+	err := c.pool.call(ctx, func(cli *ethclient.Client) (err error) {
+		gasPrice, err = cli.SuggestGasPrice(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("suggest gas price: %w", err)
+	}
+
+	accounts := []common.Address{c.transacts.From}
+	out := make([]AccountBalance, 0, len(accounts))
+	for _, addr := range accounts {
+		var bal *big.Int
+		err := c.pool.call(ctx, func(cli *ethclient.Client) (err error) {
+			bal, err = cli.BalanceAt(ctx, addr, nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("balance of %s: %w", addr.Hex(), err)
+		}
+		out = append(out, AccountBalance{
+			Address:  addr.Hex(),
+			Balance:  bal,
+			GasPrice: gasPrice,
+		})
+	}
+	return out, nil
 }
 
 func validateSubmitRequest(req SubmitIntentRequest) error {
 	if !common.IsHexAddress(req.UserAddress) {
-		return fmt.Errorf("invalid user address")
+		return invalidRequest("invalid user address")
 	}
 	if strings.TrimSpace(req.Amount) == "" {
-		return fmt.Errorf("amount required")
+		return invalidRequest("amount required")
 	}
 	if strings.TrimSpace(req.CountryCode) == "" {
-		return fmt.Errorf("country code required")
+		return invalidRequest("country code required")
 	}
 	if strings.TrimSpace(req.TxRef) == "" {
-		return fmt.Errorf("txRef required")
+		return invalidRequest("txRef required")
+	}
+	// Both travel as bytes32; longer values would be truncated and collide.
+	if len(req.CountryCode) > 32 {
+		return invalidRequest("country code longer than 32 bytes")
+	}
+	if len(req.TxRef) > 32 {
+		return invalidRequest("txRef longer than 32 bytes")
 	}
 	return nil
 }
 
+// toBytes32 left-aligns value and zero-pads it on the right, matching
+// Solidity's bytes32(bytes(value)).
 func toBytes32(value string) [32]byte {
 	var out [32]byte
 	copy(out[:], []byte(value))
 	return out
 }
 
-func computeIntentID(req SubmitIntentRequest) (string, error) {
+// computeIntentID mirrors MintEscrow.submitIntent:
+// keccak256(abi.encodePacked(msg.sender, amount, countryCode, txRef)). The
+// sender is the account signing the transaction, not req.UserAddress.
+func computeIntentID(sender common.Address, req SubmitIntentRequest) (string, error) {
 	amount, ok := new(big.Int).SetString(req.Amount, 10)
 	if !ok {
-		return "", fmt.Errorf("invalid amount %s", req.Amount)
+		return "", invalidRequest("invalid amount %s", req.Amount)
 	}
 	country := toBytes32(req.CountryCode)
 	txRef := toBytes32(req.TxRef)
 	trace := crypto.Keccak256Hash(
-		common.HexToAddress(req.UserAddress).Bytes(),
+		sender.Bytes(),
 		common.LeftPadBytes(amount.Bytes(), 32),
 		country[:],
 		txRef[:],
@@ -202,7 +420,10 @@ func computeIntentID(req SubmitIntentRequest) (string, error) {
 }
 
 // WaitForReceipt polls until the transaction is mined or context cancelled.
-func WaitForReceipt(ctx context.Context, client *ethclient.Client, tx *types.Transaction) (*types.Receipt, error) {
+func WaitForReceipt(ctx context.Context, client *ethclient.Client, tx *types.Transaction) (_ *types.Receipt, err error) {
+	ctx, span := tracer.Start(ctx, "escrow.WaitForReceipt", trace.WithAttributes(attribute.String("tx.hash", tx.Hash().Hex())))
+	defer func() { tracing.End(span, err) }()
+
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -0,0 +1,414 @@
+package escrow
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"fiatrails/internal/contracts"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// broadcastPath is forge's record of the local deployment; its CREATE
+// transactions carry the compiled creation code of each contract.
+const broadcastPath = "../../../contracts/broadcast/DeployFiatRails.s.sol/31430/run-latest.json"
+
+// simTokenABI covers the USDStablecoin, CountryToken and UserRegistry
+// functions the harness needs to set up a deployment.
+const simTokenABI = `[
+  {"type":"function","name":"mint","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+  {"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+  {"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+  {"type":"function","name":"grantRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[]},
+  {"type":"function","name":"updateUser","stateMutability":"nonpayable","inputs":[{"name":"user","type":"address"},{"name":"newRiskScore","type":"uint8"},{"name":"attestationHash","type":"bytes32"},{"name":"attestationType","type":"bytes32"},{"name":"actor","type":"address"}],"outputs":[]}
+]`
+
+// simDeployment is MintEscrow and its dependencies deployed on a simulated
+// chain served over HTTP, with the deployer acting as admin and executor.
+type simDeployment struct {
+	t          *testing.T
+	backend    *simulated.Backend
+	rpc        *ethclient.Client
+	url        string
+	key        string
+	owner      *bind.TransactOpts
+	escrowABI  abi.ABI
+	tokenABI   abi.ABI
+	stablecoin common.Address
+	country    common.Address
+	registry   common.Address
+	escrow     common.Address
+}
+
+func loadCreationCode(t *testing.T) map[string][]byte {
+	t.Helper()
+	raw, err := os.ReadFile(broadcastPath)
+	if err != nil {
+		t.Skipf("contract artifacts not available: %v", err)
+	}
+	var run struct {
+		Transactions []struct {
+			TransactionType string   `json:"transactionType"`
+			ContractName    string   `json:"contractName"`
+			Arguments       []string `json:"arguments"`
+			Transaction     struct {
+				Input string `json:"input"`
+			} `json:"transaction"`
+		} `json:"transactions"`
+	}
+	if err := json.Unmarshal(raw, &run); err != nil {
+		t.Fatalf("parse broadcast: %v", err)
+	}
+	code := make(map[string][]byte)
+	for _, tx := range run.Transactions {
+		if tx.TransactionType != "CREATE" {
+			continue
+		}
+		input, err := hex.DecodeString(strings.TrimPrefix(tx.Transaction.Input, "0x"))
+		if err != nil {
+			t.Fatalf("decode %s creation code: %v", tx.ContractName, err)
+		}
+		// Strip the recorded constructor arguments; each static argument is one word.
+		code[tx.ContractName] = input[:len(input)-32*len(tx.Arguments)]
+	}
+	return code
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newSimDeployment(t *testing.T) *simDeployment {
+	t.Helper()
+	code := loadCreationCode(t)
+
+	key, _ := crypto.GenerateKey()
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	port := freePort(t)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		owner: {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))},
+	}, func(nodeConf *node.Config, _ *ethconfig.Config) {
+		nodeConf.HTTPHost = "127.0.0.1"
+		nodeConf.HTTPPort = port
+		nodeConf.HTTPModules = []string{"eth", "net", "web3"}
+	})
+	t.Cleanup(func() { _ = backend.Close() })
+
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	rpcClient, err := ethclient.Dial(url)
+	if err != nil {
+		t.Fatalf("dial simulated node: %v", err)
+	}
+	t.Cleanup(rpcClient.Close)
+
+	chainID, err := rpcClient.ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("chain id: %v", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	escrowABI, _ := abi.JSON(strings.NewReader(string(contracts.MintEscrowABI)))
+	tokenABI, _ := abi.JSON(strings.NewReader(simTokenABI))
+	d := &simDeployment{
+		t:         t,
+		backend:   backend,
+		rpc:       rpcClient,
+		url:       url,
+		key:       hex.EncodeToString(crypto.FromECDSA(key)),
+		owner:     opts,
+		escrowABI: escrowABI,
+		tokenABI:  tokenABI,
+	}
+
+	d.stablecoin = d.deploy(code["USDStablecoin"])
+	d.country = d.deploy(code["CountryToken"])
+	d.registry = d.deploy(code["UserRegistry"], owner)
+	d.escrow = d.deploy(code["MintEscrow"], owner)
+
+	d.transact(d.escrow, escrowABI, "setStablecoin", d.stablecoin)
+	d.transact(d.escrow, escrowABI, "setUserRegistry", d.registry)
+	d.transact(d.escrow, escrowABI, "setCountryToken", toBytes32("KES"), d.country)
+	d.transact(d.escrow, escrowABI, "setExecutor", owner, true)
+	d.transact(d.country, tokenABI, "grantRole", minterRole, d.escrow)
+	d.transact(d.registry, tokenABI, "grantRole", crypto.Keccak256Hash([]byte("MANAGER_ROLE")), owner)
+	d.transact(d.registry, tokenABI, "updateUser", owner, uint8(10), crypto.Keccak256Hash([]byte("kyc")), toBytes32("KYC"), owner)
+	d.transact(d.stablecoin, tokenABI, "mint", owner, ether(5000))
+	d.transact(d.stablecoin, tokenABI, "approve", d.escrow, ether(5000))
+	return d
+}
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func (d *simDeployment) deploy(code []byte, args ...any) common.Address {
+	d.t.Helper()
+	var ctorArgs []byte
+	for _, a := range args {
+		ctorArgs = append(ctorArgs, common.LeftPadBytes(a.(common.Address).Bytes(), 32)...)
+	}
+	tx := types.NewContractCreation(d.nonce(), big.NewInt(0), 8_000_000, d.gasPrice(), append(append([]byte{}, code...), ctorArgs...))
+	receipt := d.sendSigned(tx)
+	return receipt.ContractAddress
+}
+
+func (d *simDeployment) transact(to common.Address, parsed abi.ABI, method string, args ...any) {
+	d.t.Helper()
+	contract := bind.NewBoundContract(to, parsed, d.rpc, d.rpc, d.rpc)
+	opts := *d.owner
+	opts.NoSend = true
+	tx, err := contract.Transact(&opts, method, args...)
+	if err != nil {
+		d.t.Fatalf("%s: %v", method, err)
+	}
+	d.sendSigned(tx)
+}
+
+func (d *simDeployment) nonce() uint64 {
+	n, err := d.rpc.PendingNonceAt(context.Background(), d.owner.From)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return n
+}
+
+func (d *simDeployment) gasPrice() *big.Int {
+	p, err := d.rpc.SuggestGasPrice(context.Background())
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return p
+}
+
+// sendSigned signs tx if needed, mines it and requires it to succeed.
+func (d *simDeployment) sendSigned(tx *types.Transaction) *types.Receipt {
+	d.t.Helper()
+	if v, _, _ := tx.RawSignatureValues(); v == nil || v.Sign() == 0 {
+		signed, err := d.owner.Signer(d.owner.From, tx)
+		if err != nil {
+			d.t.Fatal(err)
+		}
+		tx = signed
+	}
+	if err := d.rpc.SendTransaction(context.Background(), tx); err != nil {
+		d.t.Fatalf("send: %v", err)
+	}
+	return d.mined(tx.Hash().Hex())
+}
+
+// mined commits a block and returns the receipt of txHash, which must have succeeded.
+func (d *simDeployment) mined(txHash string) *types.Receipt {
+	d.t.Helper()
+	d.backend.Commit()
+	receipt, err := d.rpc.TransactionReceipt(context.Background(), common.HexToHash(txHash))
+	if err != nil {
+		d.t.Fatalf("receipt %s: %v", txHash, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		d.t.Fatalf("tx %s reverted", txHash)
+	}
+	return receipt
+}
+
+func (d *simDeployment) balanceOf(token common.Address, account common.Address) *big.Int {
+	d.t.Helper()
+	var out []any
+	err := bind.NewBoundContract(token, d.tokenABI, d.rpc, nil, nil).Call(&bind.CallOpts{}, &out, "balanceOf", account)
+	if err != nil {
+		d.t.Fatalf("balanceOf: %v", err)
+	}
+	return out[0].(*big.Int)
+}
+
+// event decodes the single log named name in receipt.
+func (d *simDeployment) event(receipt *types.Receipt, name string) (map[string]any, []common.Hash) {
+	d.t.Helper()
+	ev := d.escrowABI.Events[name]
+	for _, l := range receipt.Logs {
+		if len(l.Topics) == 0 || l.Topics[0] != ev.ID {
+			continue
+		}
+		fields := make(map[string]any)
+		if err := d.escrowABI.UnpackIntoMap(fields, name, l.Data); err != nil {
+			d.t.Fatalf("unpack %s: %v", name, err)
+		}
+		return fields, l.Topics[1:]
+	}
+	d.t.Fatalf("no %s event in receipt", name)
+	return nil, nil
+}
+
+func (d *simDeployment) client() *EthClient {
+	d.t.Helper()
+	c, err := NewEthClient(context.Background(), EthClientConfig{
+		RPCURL:             d.url,
+		CallTimeout:        5 * time.Second,
+		PrivateKeyHex:      d.key,
+		ContractMintEscrow: d.escrow.Hex(),
+		Metrics:            NewRPCMetrics(),
+	})
+	if err != nil {
+		d.t.Fatalf("new eth client: %v", err)
+	}
+	return c
+}
+
+func TestEthClientSubmitAndExecuteOnSimulatedChain(t *testing.T) {
+	d := newSimDeployment(t)
+	c := d.client()
+	ctx := context.Background()
+	owner := d.owner.From
+	amount := ether(250)
+
+	req := SubmitIntentRequest{
+		UserAddress: "0x70997970C51812dc3A010C7d01b50e20d17dc79C",
+		Amount:      amount.String(),
+		CountryCode: "KES",
+		TxRef:       "MPESA-REF-0001",
+	}
+	if _, found, err := c.FindIntent(ctx, req); err != nil || found {
+		t.Fatalf("intent found before submission: %v %v", found, err)
+	}
+	sub, err := c.SubmitIntent(ctx, req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	fields, topics := d.event(d.mined(sub.TxHash), "MintIntentSubmitted")
+	if id, found, err := c.FindIntent(ctx, req); err != nil || !found || id != sub.IntentID {
+		t.Fatalf("FindIntent = %s %v %v, want %s", id, found, err, sub.IntentID)
+	}
+	if topics[0].Hex() != sub.IntentID {
+		t.Fatalf("returned intent id %s, contract emitted %s", sub.IntentID, topics[0].Hex())
+	}
+	if common.BytesToAddress(topics[1].Bytes()) != owner {
+		t.Fatalf("intent user = %s, want signer %s", common.BytesToAddress(topics[1].Bytes()).Hex(), owner.Hex())
+	}
+	if topics[2] != common.Hash(toBytes32("KES")) {
+		t.Fatalf("country code topic = %x", topics[2])
+	}
+	if got := fields["txRef"].([32]byte); got != toBytes32("MPESA-REF-0001") {
+		t.Fatalf("txRef = %q", got)
+	}
+	if got := fields["amount"].(*big.Int); got.Cmp(amount) != 0 {
+		t.Fatalf("amount = %s", got)
+	}
+	if got := d.balanceOf(d.stablecoin, d.escrow); got.Cmp(amount) != 0 {
+		t.Fatalf("escrow holds %s stablecoin, want %s", got, amount)
+	}
+
+	if executed, err := c.IntentExecuted(ctx, sub.IntentID); err != nil || executed {
+		t.Fatalf("intent executed before executeMint: %v %v", executed, err)
+	}
+	exec, err := c.ExecuteMint(ctx, sub.IntentID)
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	_, topics = d.event(d.mined(exec.TxHash), "MintExecuted")
+	if executed, err := c.IntentExecuted(ctx, sub.IntentID); err != nil || !executed {
+		t.Fatalf("IntentExecuted = %v %v after executeMint", executed, err)
+	}
+	if topics[0].Hex() != sub.IntentID {
+		t.Fatalf("executed intent %s, want %s", topics[0].Hex(), sub.IntentID)
+	}
+	if got := d.balanceOf(d.country, owner); got.Cmp(amount) != 0 {
+		t.Fatalf("minted %s country tokens, want %s", got, amount)
+	}
+
+	if _, err := c.ExecuteMint(ctx, sub.IntentID); err == nil {
+		t.Fatal("executing an intent twice should fail")
+	}
+}
+
+func TestEthClientRefundOnSimulatedChain(t *testing.T) {
+	d := newSimDeployment(t)
+	c := d.client()
+	ctx := context.Background()
+	owner := d.owner.From
+	before := d.balanceOf(d.stablecoin, owner)
+
+	sub, err := c.SubmitIntent(ctx, SubmitIntentRequest{
+		UserAddress: owner.Hex(),
+		Amount:      ether(10).String(),
+		CountryCode: "KES",
+		TxRef:       "MPESA-REF-0002",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	d.mined(sub.TxHash)
+
+	refund, err := c.RefundIntent(ctx, sub.IntentID, "payment reversed")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	fields, topics := d.event(d.mined(refund.TxHash), "MintRefunded")
+	if topics[0].Hex() != sub.IntentID || fields["reason"] != "payment reversed" {
+		t.Fatalf("unexpected MintRefunded %v %v", topics, fields)
+	}
+	if got := d.balanceOf(d.stablecoin, owner); got.Cmp(before) != 0 {
+		t.Fatalf("stablecoin balance %s after refund, want %s", got, before)
+	}
+	if _, err := c.ExecuteMint(ctx, sub.IntentID); err == nil {
+		t.Fatal("executing a refunded intent should fail")
+	}
+}
+
+func TestEthClientRejectsOversizedBytes32(t *testing.T) {
+	d := newSimDeployment(t)
+	_, err := d.client().SubmitIntent(context.Background(), SubmitIntentRequest{
+		UserAddress: d.owner.From.Hex(),
+		Amount:      ether(10).String(),
+		CountryCode: "KES",
+		TxRef:       strings.Repeat("x", 33),
+	})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest for a 33-byte txRef, got %v", err)
+	}
+}
+
+func TestVerifyDeploymentOnSimulatedChain(t *testing.T) {
+	d := newSimDeployment(t)
+	chainID, _ := d.rpc.ChainID(context.Background())
+	report := d.client().VerifyDeployment(context.Background(), DeploymentSpec{
+		ChainID:              chainID.Int64(),
+		USDStablecoin:        d.stablecoin.Hex(),
+		CountryToken:         d.country.Hex(),
+		UserRegistry:         d.registry.Hex(),
+		MintEscrow:           d.escrow.Hex(),
+		CountryCode:          "KES",
+		StablecoinDecimals:   18,
+		CountryTokenDecimals: 18,
+	})
+	// The harness does not deploy ComplianceManager; everything else must pass.
+	for _, c := range report.Checks {
+		if !c.OK && c.Name != "ComplianceManager code" {
+			t.Errorf("%s: %s", c.Name, c.Detail)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fault is an error FaultClient can inject in place of a call's normal result.
+type Fault string
+
+const (
+	// FaultNone lets the call through.
+	FaultNone Fault = ""
+	// FaultTransient fails like an unreachable node, which the circuit breaker counts.
+	FaultTransient Fault = "transient"
+	// FaultTimeout holds the call until its context expires (or Timeout passes).
+	FaultTimeout Fault = "timeout"
+	// FaultRateLimited answers as a node asking the caller to back off.
+	FaultRateLimited Fault = "rate_limited"
+	// FaultNotCompliant reverts with UserNotCompliant.
+	FaultNotCompliant Fault = "not_compliant"
+	// FaultAlreadyExecuted reverts with IntentAlreadyExecuted.
+	FaultAlreadyExecuted Fault = "already_executed"
+	// FaultLostResponse applies the call, then fails as if the reply was
+	// lost, so a retry meets the state the first attempt left behind.
+	FaultLostResponse Fault = "lost_response"
+)
+
+var knownFaults = []Fault{FaultTransient, FaultTimeout, FaultRateLimited, FaultNotCompliant, FaultAlreadyExecuted, FaultLostResponse}
+
+// Methods FaultClient records and injects faults into.
+const (
+	MethodSubmitIntent = "submitIntent"
+	MethodExecuteMint  = "executeMint"
+	MethodRefundIntent = "refundIntent"
+	MethodPing         = "ping"
+)
+
+// RevertError is a contract revert, shaped like the JSON-RPC error a node
+// returns for a failed eth_estimateGas.
+type RevertError struct {
+	Reason string
+}
+
+func (e *RevertError) Error() string  { return "execution reverted: " + e.Reason }
+func (e *RevertError) ErrorCode() int { return 3 }
+
+// FaultRule injects Fault into a share of calls to Method; an empty Method
+// matches every method.
+type FaultRule struct {
+	Method string
+	Fault  Fault
+	Rate   float64
+}
+
+// ParseFaultRules reads a comma-separated list of [method:]fault=rate, for
+// example "transient=0.1,executeMint:not_compliant=0.02".
+func ParseFaultRules(spec string) ([]FaultRule, error) {
+	var rules []FaultRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, rate, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault rule %q: want [method:]fault=rate", item)
+		}
+		var rule FaultRule
+		if method, fault, ok := strings.Cut(name, ":"); ok {
+			switch method {
+			case MethodSubmitIntent, MethodExecuteMint, MethodRefundIntent, MethodPing:
+			default:
+				return nil, fmt.Errorf("fault rule %q: unknown method %q", item, method)
+			}
+			rule.Method = method
+			name = fault
+		}
+		rule.Fault = Fault(name)
+		if !isKnownFault(rule.Fault) {
+			return nil, fmt.Errorf("fault rule %q: unknown fault %q", item, name)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("fault rule %q: rate must be between 0 and 1", item)
+		}
+		rule.Rate = r
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func isKnownFault(f Fault) bool {
+	for _, k := range knownFaults {
+		if f == k {
+			return true
+		}
+	}
+	return false
+}
+
+// IntentStatus mirrors MintEscrow.MintStatus.
+type IntentStatus string
+
+const (
+	IntentPending  IntentStatus = "pending"
+	IntentExecuted IntentStatus = "executed"
+	IntentRefunded IntentStatus = "refunded"
+)
+
+// FakeIntent is an intent as FaultClient holds it.
+type FakeIntent struct {
+	ID     string
+	TxRef  string
+	Amount string
+	Status IntentStatus
+}
+
+// Call is one recorded FaultClient call.
+type Call struct {
+	Method   string
+	IntentID string
+	TxRef    string
+	Fault    Fault
+	Err      error
+	At       time.Time
+}
+
+// FaultClient is a Client that keeps intents the way MintEscrow does
+// (a txRef is used once, an intent executes once) and injects latency and
+// errors on demand, for resilience tests and demos without a chain.
+// Configure it before use; the zero value is not ready, use NewFaultClient.
+type FaultClient struct {
+	// Latency delays every call; Jitter adds up to that much more at random.
+	Latency time.Duration
+	Jitter  time.Duration
+	// Timeout bounds FaultTimeout for callers without a deadline.
+	Timeout time.Duration
+	// Sender plays msg.sender when deriving intent IDs.
+	Sender common.Address
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	rules   []FaultRule
+	script  map[string][]Fault
+	calls   []Call
+	intents map[string]*FakeIntent
+	txRefs  map[string]bool
+	nonce   uint64
+}
+
+// NewFaultClient returns a FaultClient whose random faults and jitter are
+// drawn from seed, so a run can be repeated.
+func NewFaultClient(seed uint64) *FaultClient {
+	return &FaultClient{
+		Timeout: 5 * time.Second,
+		Sender:  common.HexToAddress("0x00000000000000000000000000000000000fa4e5"),
+		rand:    rand.New(rand.NewPCG(seed, seed)),
+		script:  make(map[string][]Fault),
+		intents: make(map[string]*FakeIntent),
+		txRefs:  make(map[string]bool),
+	}
+}
+
+// Script queues faults for the next calls to method, one per call, ahead of
+// any random rules. FaultNone in the sequence lets that call through.
+func (f *FaultClient) Script(method string, faults ...Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script[method] = append(f.script[method], faults...)
+}
+
+// SetRules replaces the random fault rules.
+func (f *FaultClient) SetRules(rules ...FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append([]FaultRule(nil), rules...)
+}
+
+// Calls returns every call made so far.
+func (f *FaultClient) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount returns how many calls were made to method.
+func (f *FaultClient) CallCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Intent returns the intent with the given ID.
+func (f *FaultClient) Intent(id string) (FakeIntent, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, ok := f.intents[strings.ToLower(id)]
+	if !ok {
+		return FakeIntent{}, false
+	}
+	return *in, true
+}
+
+// FindIntent looks the intent up without going through the fault schedule,
+// so a test can inject a lost reply and watch the caller reconcile it.
+func (f *FaultClient) FindIntent(_ context.Context, req SubmitIntentRequest) (string, bool, error) {
+	if err := validateSubmitRequest(req); err != nil {
+		return "", false, err
+	}
+	id, err := computeIntentID(f.Sender, req)
+	if err != nil {
+		return "", false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, found := f.intents[strings.ToLower(id)]
+	return id, found, nil
+}
+
+// IntentExecuted bypasses the fault schedule like FindIntent.
+func (f *FaultClient) IntentExecuted(_ context.Context, intentID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in, ok := f.intents[strings.ToLower(intentID)]
+	return ok && in.Status == IntentExecuted, nil
+}
+
+func (f *FaultClient) SubmitIntent(ctx context.Context, req SubmitIntentRequest) (SubmitIntentResponse, error) {
+	var resp SubmitIntentResponse
+	err := f.do(ctx, Call{Method: MethodSubmitIntent, TxRef: req.TxRef}, func() error {
+		if err := validateSubmitRequest(req); err != nil {
+			return err
+		}
+		id, err := computeIntentID(f.Sender, req)
+		if err != nil {
+			return err
+		}
+		if f.txRefs[req.TxRef] {
+			return &RevertError{Reason: "TxRefAlreadyConsumed"}
+		}
+		key := strings.ToLower(id)
+		if _, exists := f.intents[key]; exists {
+			return &RevertError{Reason: "IntentAlreadyExists"}
+		}
+		f.txRefs[req.TxRef] = true
+		f.intents[key] = &FakeIntent{ID: id, TxRef: req.TxRef, Amount: req.Amount, Status: IntentPending}
+		resp = SubmitIntentResponse{IntentID: id, TxHash: f.txHash()}
+		return nil
+	})
+	return resp, err
+}
+
+func (f *FaultClient) ExecuteMint(ctx context.Context, intentID string) (ExecuteMintResponse, error) {
+	return f.settle(ctx, MethodExecuteMint, intentID, IntentExecuted)
+}
+
+// RefundIntent marks a pending intent refunded, like EthClient.RefundIntent.
+func (f *FaultClient) RefundIntent(ctx context.Context, intentID, _ string) (ExecuteMintResponse, error) {
+	return f.settle(ctx, MethodRefundIntent, intentID, IntentRefunded)
+}
+
+func (f *FaultClient) settle(ctx context.Context, method, intentID string, to IntentStatus) (ExecuteMintResponse, error) {
+	var resp ExecuteMintResponse
+	err := f.do(ctx, Call{Method: method, IntentID: intentID}, func() error {
+		if len(intentID) != 66 || !strings.HasPrefix(intentID, "0x") {
+			return invalidRequest("invalid intent id")
+		}
+		in, ok := f.intents[strings.ToLower(intentID)]
+		if !ok {
+			return &RevertError{Reason: "IntentNotFound"}
+		}
+		if in.Status != IntentPending {
+			return &RevertError{Reason: "IntentAlreadyExecuted"}
+		}
+		in.Status = to
+		resp = ExecuteMintResponse{TxHash: f.txHash()}
+		return nil
+	})
+	return resp, err
+}
+
+func (f *FaultClient) Ping(ctx context.Context) error {
+	return f.do(ctx, Call{Method: MethodPing}, func() error { return nil })
+}
+
+// do waits out the latency, picks a fault and records the call. apply runs
+// under the lock and carries the call's normal effect.
+func (f *FaultClient) do(ctx context.Context, call Call, apply func() error) error {
+	f.mu.Lock()
+	delay := f.Latency
+	if f.Jitter > 0 {
+		delay += time.Duration(f.rand.Int64N(int64(f.Jitter)))
+	}
+	fault := f.nextFault(call.Method)
+	f.mu.Unlock()
+
+	err := f.wait(ctx, delay)
+	if err == nil {
+		err = f.inject(ctx, fault, apply)
+	}
+
+	f.mu.Lock()
+	call.Fault, call.Err, call.At = fault, err, time.Now()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+	return err
+}
+
+// nextFault must be called with f.mu held.
+func (f *FaultClient) nextFault(method string) Fault {
+	if queued := f.script[method]; len(queued) > 0 {
+		f.script[method] = queued[1:]
+		return queued[0]
+	}
+	for _, r := range f.rules {
+		if (r.Method == "" || r.Method == method) && f.rand.Float64() < r.Rate {
+			return r.Fault
+		}
+	}
+	return FaultNone
+}
+
+func (f *FaultClient) inject(ctx context.Context, fault Fault, apply func() error) error {
+	switch fault {
+	case FaultTransient:
+		return errors.New("injected fault: dial tcp: connection refused")
+	case FaultTimeout:
+		limit := f.Timeout
+		if err := f.wait(ctx, limit); err != nil {
+			return fmt.Errorf("rpc call timed out: %w", err)
+		}
+		return fmt.Errorf("rpc call timed out after %s: %w", limit, context.DeadlineExceeded)
+	case FaultRateLimited:
+		return &RateLimitError{Endpoint: "fault-client", Delay: time.Second}
+	case FaultNotCompliant:
+		return &RevertError{Reason: "UserNotCompliant"}
+	case FaultAlreadyExecuted:
+		return &RevertError{Reason: "IntentAlreadyExecuted"}
+	}
+
+	f.mu.Lock()
+	err := apply()
+	f.mu.Unlock()
+	if err == nil && fault == FaultLostResponse {
+		return errors.New("injected fault: connection reset after request was sent")
+	}
+	return err
+}
+
+func (f *FaultClient) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// txHash must be called with f.mu held.
+func (f *FaultClient) txHash() string {
+	f.nonce++
+	return fakeHash(f.Sender.Hex() + strconv.FormatUint(f.nonce, 10))
+}
@@ -0,0 +1,175 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func faultRequest(txRef string) SubmitIntentRequest {
+	return SubmitIntentRequest{
+		UserAddress: "0x00000000000000000000000000000000000000aa",
+		Amount:      "1000",
+		CountryCode: "KES",
+		TxRef:       txRef,
+	}
+}
+
+func TestFaultClientMirrorsEscrowState(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaultClient(1)
+
+	sub, err := f.SubmitIntent(ctx, faultRequest("TX1"))
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := f.SubmitIntent(ctx, faultRequest("TX1")); err == nil || !strings.Contains(err.Error(), "TxRefAlreadyConsumed") {
+		t.Fatalf("reused txRef should revert, got %v", err)
+	}
+	if _, err := f.ExecuteMint(ctx, sub.IntentID); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	_, err = f.ExecuteMint(ctx, sub.IntentID)
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) || !strings.Contains(err.Error(), "IntentAlreadyExecuted") {
+		t.Fatalf("second execute should revert with IntentAlreadyExecuted, got %v", err)
+	}
+	if _, err := f.ExecuteMint(ctx, "0x"+strings.Repeat("ab", 32)); err == nil || !strings.Contains(err.Error(), "IntentNotFound") {
+		t.Fatalf("unknown intent should revert, got %v", err)
+	}
+	if in, ok := f.Intent(sub.IntentID); !ok || in.Status != IntentExecuted {
+		t.Fatalf("intent state = %+v, %v", in, ok)
+	}
+
+	other, _ := f.SubmitIntent(ctx, faultRequest("TX2"))
+	if _, err := f.RefundIntent(ctx, other.IntentID, "expired"); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if _, err := f.ExecuteMint(ctx, other.IntentID); err == nil {
+		t.Fatal("refunded intent must not execute")
+	}
+}
+
+func TestFaultClientScriptAndRecording(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaultClient(1)
+	f.Script(MethodSubmitIntent, FaultTransient, FaultRateLimited, FaultNone)
+	f.Script(MethodExecuteMint, FaultNotCompliant)
+
+	if _, err := f.SubmitIntent(ctx, faultRequest("TX1")); err == nil || !isOutage(ctx, err) {
+		t.Fatalf("transient fault should look like an outage, got %v", err)
+	}
+	_, err := f.SubmitIntent(ctx, faultRequest("TX1"))
+	if d, ok := RetryAfterHint(err); !ok || d != time.Second {
+		t.Fatalf("rate-limited fault should carry a hint, got %v", err)
+	}
+	sub, err := f.SubmitIntent(ctx, faultRequest("TX1"))
+	if err != nil {
+		t.Fatalf("script exhausted, submit should succeed: %v", err)
+	}
+	if _, err := f.ExecuteMint(ctx, sub.IntentID); err == nil || !strings.Contains(err.Error(), "UserNotCompliant") {
+		t.Fatalf("expected UserNotCompliant, got %v", err)
+	}
+	if in, _ := f.Intent(sub.IntentID); in.Status != IntentPending {
+		t.Fatalf("injected revert must not change state, got %s", in.Status)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 4 || f.CallCount(MethodSubmitIntent) != 3 || f.CallCount(MethodExecuteMint) != 1 {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+	if calls[0].Fault != FaultTransient || calls[0].TxRef != "TX1" || calls[2].Err != nil || calls[3].IntentID != sub.IntentID {
+		t.Fatalf("unexpected recording %+v", calls)
+	}
+}
+
+func TestFaultClientLostResponseAppliesCall(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaultClient(1)
+	sub, _ := f.SubmitIntent(ctx, faultRequest("TX1"))
+
+	f.Script(MethodExecuteMint, FaultLostResponse)
+	if _, err := f.ExecuteMint(ctx, sub.IntentID); err == nil {
+		t.Fatal("expected the reply to be lost")
+	}
+	if in, _ := f.Intent(sub.IntentID); in.Status != IntentExecuted {
+		t.Fatalf("lost response should still execute, got %s", in.Status)
+	}
+	if _, err := f.ExecuteMint(ctx, sub.IntentID); err == nil || !strings.Contains(err.Error(), "IntentAlreadyExecuted") {
+		t.Fatalf("retry should meet the executed intent, got %v", err)
+	}
+}
+
+func TestFaultClientLatencyAndTimeout(t *testing.T) {
+	f := NewFaultClient(1)
+	f.Latency = 20 * time.Millisecond
+
+	start := time.Now()
+	if err := f.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("latency not applied, took %v", elapsed)
+	}
+
+	f.Latency = 0
+	f.Script(MethodPing, FaultTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	err := f.Ping(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timeout fault ignored the caller's deadline, took %v", elapsed)
+	}
+}
+
+func TestFaultClientRulesAreSeeded(t *testing.T) {
+	run := func() []Fault {
+		f := NewFaultClient(42)
+		f.SetRules(FaultRule{Method: MethodPing, Fault: FaultTransient, Rate: 0.5})
+		for i := 0; i < 20; i++ {
+			_ = f.Ping(context.Background())
+		}
+		var got []Fault
+		for _, c := range f.Calls() {
+			got = append(got, c.Fault)
+		}
+		return got
+	}
+	a, b := run(), run()
+	faults := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed gave different faults: %v vs %v", a, b)
+		}
+		if a[i] == FaultTransient {
+			faults++
+		}
+	}
+	if faults == 0 || faults == len(a) {
+		t.Fatalf("rate 0.5 injected %d of %d faults", faults, len(a))
+	}
+}
+
+func TestParseFaultRules(t *testing.T) {
+	rules, err := ParseFaultRules("transient=0.1, executeMint:not_compliant=0.02")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []FaultRule{{Fault: FaultTransient, Rate: 0.1}, {Method: MethodExecuteMint, Fault: FaultNotCompliant, Rate: 0.02}}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", rules, want)
+	}
+	for _, bad := range []string{"transient", "bogus=0.1", "timeout=2", "executeMint:transient=x", "mint:transient=0.1"} {
+		if _, err := ParseFaultRules(bad); err == nil {
+			t.Fatalf("%q should not parse", bad)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package escrow
 
 import (
 	"context"
+	"math/big"
+	"time"
 )
 
 // Client abstracts the on-chain escrow interaction.
@@ -10,10 +12,30 @@ type Client interface {
 	ExecuteMint(ctx context.Context, intentID string) (ExecuteMintResponse, error)
 }
 
+// IntentFinder is implemented by clients that can look an intent up on
+// chain. A SubmitIntent or ExecuteMint whose reply was lost may still have
+// been applied, so it is reconciled through FindIntent or IntentExecuted
+// rather than sent again.
+type IntentFinder interface {
+	FindIntent(ctx context.Context, req SubmitIntentRequest) (intentID string, found bool, err error)
+	IntentExecuted(ctx context.Context, intentID string) (bool, error)
+}
+
 type HealthChecker interface {
 	Ping(ctx context.Context) error
 }
 
+// BalanceChecker reports the native balance of every account the client signs with.
+type BalanceChecker interface {
+	Balances(ctx context.Context) ([]AccountBalance, error)
+}
+
+type AccountBalance struct {
+	Address  string
+	Balance  *big.Int // wei
+	GasPrice *big.Int // current suggested gas price in wei
+}
+
 type SubmitIntentRequest struct {
 	UserAddress string
 	Amount      string // decimal string in wei
@@ -29,3 +51,24 @@ type SubmitIntentResponse struct {
 type ExecuteMintResponse struct {
 	TxHash string
 }
+
+// EndpointMonitor is implemented by clients that spread RPC traffic across
+// several nodes and health-check them in the background.
+type EndpointMonitor interface {
+	Endpoints() []EndpointStatus
+	MonitorEndpoints(ctx context.Context)
+}
+
+// EndpointStatus is one RPC node as last seen by the health checker.
+type EndpointStatus struct {
+	Name                string    `json:"name"`
+	Priority            int       `json:"priority"`
+	Healthy             bool      `json:"healthy"`
+	Active              bool      `json:"active"`
+	BlockNumber         uint64    `json:"block_number"`
+	BlockLag            uint64    `json:"block_lag"`
+	LatencyMs           float64   `json:"latency_ms"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
@@ -0,0 +1,97 @@
+package escrow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// rpcLimitExceeded is the JSON-RPC code providers such as Infura and Alchemy
+// return when a key exceeds its request rate.
+const rpcLimitExceeded = -32005
+
+// RateLimitError reports an RPC node answering 429 Too Many Requests.
+type RateLimitError struct {
+	Endpoint string
+	// Delay is the node's Retry-After, or zero when it gave none.
+	Delay time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Delay > 0 {
+		return fmt.Sprintf("rpc %s rate limited, retry after %s", e.Endpoint, e.Delay)
+	}
+	return fmt.Sprintf("rpc %s rate limited", e.Endpoint)
+}
+
+// RetryAfter implements the hint interface understood by the retry package.
+func (e *RateLimitError) RetryAfter() time.Duration {
+	return e.Delay
+}
+
+// RetryAfterHint returns the delay a node asked for, either through a 429
+// Retry-After header or a JSON-RPC limit-exceeded error with
+// data.rate.backoff_seconds. ok is false when err is not a rate limit.
+func RetryAfterHint(err error) (time.Duration, bool) {
+	var rle *RateLimitError
+	if errors.As(err, &rle) {
+		return rle.Delay, true
+	}
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != rpcLimitExceeded {
+		return 0, false
+	}
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return 0, true
+	}
+	return backoffFromData(dataErr.ErrorData()), true
+}
+
+func backoffFromData(data interface{}) time.Duration {
+	var hint struct {
+		Rate struct {
+			BackoffSeconds float64 `json:"backoff_seconds"`
+		} `json:"rate"`
+	}
+	switch v := data.(type) {
+	case json.RawMessage:
+		_ = json.Unmarshal(v, &hint)
+	case string:
+		_ = json.Unmarshal([]byte(v), &hint)
+	default:
+		blob, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		_ = json.Unmarshal(blob, &hint)
+	}
+	if hint.Rate.BackoffSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(hint.Rate.BackoffSeconds * float64(time.Second))
+}
+
+// parseRetryAfter accepts delta-seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package escrow
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHintFromRetryAfterHeader(t *testing.T) {
+	cli, _ := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := cli.BlockNumber(context.Background())
+	if err == nil {
+		t.Fatal("expected rate limit error")
+	}
+	delay, ok := RetryAfterHint(err)
+	if !ok || delay != 7*time.Second {
+		t.Fatalf("expected 7s hint, got %v ok=%v (err %v)", delay, ok, err)
+	}
+}
+
+func TestRateLimitHintFromJSONRPCError(t *testing.T) {
+	cli, _ := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"daily request count exceeded","data":{"rate":{"allowed_rps":1,"backoff_seconds":2.5,"current_rps":3}}}}`))
+	})
+
+	_, err := cli.BlockNumber(context.Background())
+	delay, ok := RetryAfterHint(err)
+	if !ok || delay != 2500*time.Millisecond {
+		t.Fatalf("expected 2.5s hint, got %v ok=%v (err %v)", delay, ok, err)
+	}
+
+	if _, ok := RetryAfterHint(fakeRPCError{}); ok {
+		t.Fatal("revert must not be treated as a rate limit")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"Mon, 01 Jan 2024 12:00:10 GMT": 10 * time.Second,
+		"Mon, 01 Jan 2024 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for in, want := range cases {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
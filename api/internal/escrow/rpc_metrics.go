@@ -0,0 +1,88 @@
+package escrow
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RPCMetrics counts JSON-RPC calls made by EthClient. It is a
+// prometheus.Collector so the server can register it alongside its own metrics.
+type RPCMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	timeouts *prometheus.CounterVec
+	up       *prometheus.GaugeVec
+}
+
+func NewRPCMetrics() *RPCMetrics {
+	return &RPCMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fiatrails_rpc_requests_total",
+			Help: "JSON-RPC calls sent to the chain node by method",
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fiatrails_rpc_errors_total",
+			Help: "JSON-RPC calls that failed in transport, with an HTTP error or with a JSON-RPC error, by method",
+		}, []string{"method"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fiatrails_rpc_duration_seconds",
+			Help:    "Round-trip time of JSON-RPC calls by method",
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		}, []string{"method"}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fiatrails_rpc_timeouts_total",
+			Help: "JSON-RPC calls abandoned after the per-call deadline, by method",
+		}, []string{"method"}),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fiatrails_rpc_endpoint_up",
+			Help: "Whether an RPC endpoint is in rotation (1) or failed its health checks (0)",
+		}, []string{"endpoint"}),
+	}
+}
+
+func (m *RPCMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+	m.timeouts.Describe(ch)
+	m.up.Describe(ch)
+}
+
+func (m *RPCMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+	m.timeouts.Collect(ch)
+	m.up.Collect(ch)
+}
+
+func (m *RPCMetrics) observe(method string, elapsed time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(method).Inc()
+	m.duration.WithLabelValues(method).Observe(elapsed.Seconds())
+	if failed {
+		m.errors.WithLabelValues(method).Inc()
+	}
+}
+
+func (m *RPCMetrics) incTimeout(method string) {
+	if m == nil {
+		return
+	}
+	m.timeouts.WithLabelValues(method).Inc()
+}
+
+func (m *RPCMetrics) setEndpointUp(endpoint string, up bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if up {
+		v = 1
+	}
+	m.up.WithLabelValues(endpoint).Set(v)
+}
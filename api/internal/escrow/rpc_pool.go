@@ -0,0 +1,367 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fiatrails/internal/logging"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Endpoint is one chain node; lower Priority is preferred.
+type Endpoint struct {
+	URL      string
+	Priority int
+}
+
+// HealthSettings decide when a node is taken out of rotation. Zero values
+// disable the corresponding check.
+type HealthSettings struct {
+	CheckInterval    time.Duration
+	MaxBlockLag      uint64
+	MaxLatency       time.Duration
+	FailureThreshold int
+}
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	healthCheckTimeout         = 5 * time.Second
+)
+
+type rpcEndpoint struct {
+	name     string
+	priority int
+	client   *ethclient.Client
+
+	// Guarded by rpcPool.mu.
+	healthy   bool
+	chainOK   bool
+	block     uint64
+	lag       uint64
+	latency   time.Duration
+	failures  int
+	lastErr   string
+	checkedAt time.Time
+}
+
+// rpcPool routes calls to the preferred healthy node and fails over to the
+// next one by priority. Nodes come back into rotation as soon as a health
+// check passes, so traffic returns to the primary once it recovers.
+type rpcPool struct {
+	mu        sync.RWMutex
+	endpoints []*rpcEndpoint
+	chainID   *big.Int
+	settings  HealthSettings
+	metrics   *RPCMetrics
+}
+
+func newRPCPool(ctx context.Context, endpoints []Endpoint, settings HealthSettings, callTimeout time.Duration, metrics *RPCMetrics) (*rpcPool, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("at least one rpc endpoint is required")
+	}
+	sorted := append([]Endpoint(nil), endpoints...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	p := &rpcPool{settings: settings, metrics: metrics}
+	for _, ep := range sorted {
+		rpcClient, err := rpc.DialOptions(ctx, ep.URL, rpc.WithHTTPClient(newRPCHTTPClient(metrics, callTimeout)))
+		if err != nil {
+			return nil, fmt.Errorf("dial rpc %s: %w", endpointName(ep.URL), err)
+		}
+		p.endpoints = append(p.endpoints, &rpcEndpoint{
+			name:     endpointName(ep.URL),
+			priority: ep.Priority,
+			client:   ethclient.NewClient(rpcClient),
+			healthy:  true,
+		})
+	}
+	return p, nil
+}
+
+// endpointName keeps only scheme, host and port: provider URLs often carry an
+// API key in the path or query, which must not reach logs or /health.
+func endpointName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "endpoint"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// pick returns the preferred healthy node, or the preferred node overall when
+// none is healthy so calls still have somewhere to go.
+func (p *rpcPool) pick() *rpcEndpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, ep := range p.endpoints {
+		if ep.healthy {
+			return ep
+		}
+	}
+	return p.endpoints[0]
+}
+
+// candidates lists nodes to try in order: first (if given), then healthy
+// nodes by priority, then the rest.
+func (p *rpcPool) candidates(first *rpcEndpoint) []*rpcEndpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]*rpcEndpoint, 0, len(p.endpoints))
+	if first != nil {
+		out = append(out, first)
+	}
+	for _, healthy := range []bool{true, false} {
+		for _, ep := range p.endpoints {
+			if ep != first && ep.healthy == healthy {
+				out = append(out, ep)
+			}
+		}
+	}
+	return out
+}
+
+// call runs a read-only request, moving on to the next node when one is
+// unreachable. Answers from a node, including JSON-RPC errors, are returned as-is.
+func (p *rpcPool) call(ctx context.Context, fn func(*ethclient.Client) error) error {
+	var lastErr error
+	for _, ep := range p.candidates(nil) {
+		err := fn(ep.client)
+		if err == nil {
+			p.succeeded(ep)
+			return nil
+		}
+		if !isEndpointFailure(ctx, err) {
+			return err
+		}
+		p.failed(ctx, ep, err)
+		lastErr = err
+	}
+	return lastErr
+}
+
+// broadcast sends a signed transaction, starting with the node that built it.
+// Only the exact signed bytes are ever re-sent, so every node sees the same
+// transaction hash and nonce; a node that already has it counts as success.
+func (p *rpcPool) broadcast(ctx context.Context, tx *types.Transaction, first *rpcEndpoint) error {
+	var lastErr error
+	for i, ep := range p.candidates(first) {
+		err := ep.client.SendTransaction(ctx, tx)
+		if err == nil || isAlreadyKnown(err) || (i > 0 && p.hasTransaction(ctx, ep, tx)) {
+			p.succeeded(ep)
+			return nil
+		}
+		if !isEndpointFailure(ctx, err) {
+			return err
+		}
+		p.failed(ctx, ep, err)
+		logging.FromContext(ctx).Warn("rpc send failed, rebroadcasting signed tx",
+			"endpoint", ep.name,
+			logging.KeyTxHash, tx.Hash().Hex(),
+			"error", err,
+		)
+		lastErr = err
+	}
+	return lastErr
+}
+
+// hasTransaction reports whether a node already knows tx, e.g. because the
+// send that timed out on another node was gossiped before it failed.
+func (p *rpcPool) hasTransaction(ctx context.Context, ep *rpcEndpoint, tx *types.Transaction) bool {
+	found, _, err := ep.client.TransactionByHash(ctx, tx.Hash())
+	return err == nil && found != nil
+}
+
+func (p *rpcPool) succeeded(ep *rpcEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep.failures = 0
+}
+
+func (p *rpcPool) failed(ctx context.Context, ep *rpcEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep.failures++
+	ep.lastErr = err.Error()
+	if p.settings.FailureThreshold > 0 && ep.failures >= p.settings.FailureThreshold && ep.healthy {
+		ep.healthy = false
+		p.metrics.setEndpointUp(ep.name, false)
+		logging.FromContext(ctx).Warn("rpc endpoint marked unhealthy", "endpoint", ep.name, "failures", ep.failures, "error", err)
+	}
+}
+
+// expectChainID records the chain every node must report.
+func (p *rpcPool) expectChainID(id *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chainID = id
+}
+
+type probe struct {
+	chainID *big.Int
+	block   uint64
+	latency time.Duration
+	err     error
+}
+
+// checkAll probes every node concurrently and updates which ones are in rotation.
+func (p *rpcPool) checkAll(ctx context.Context) {
+	p.mu.RLock()
+	endpoints := append([]*rpcEndpoint(nil), p.endpoints...)
+	p.mu.RUnlock()
+
+	results := make([]probe, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(i int, ep *rpcEndpoint) {
+			defer wg.Done()
+			results[i] = p.probe(ctx, ep)
+		}(i, ep)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var head uint64
+	for _, r := range results {
+		if r.err == nil && r.block > head {
+			head = r.block
+		}
+	}
+
+	now := time.Now().UTC()
+	for i, ep := range endpoints {
+		r := results[i]
+		ep.checkedAt = now
+		if r.chainID != nil {
+			if p.chainID != nil && r.chainID.Cmp(p.chainID) != 0 {
+				r.err = fmt.Errorf("chain id %s does not match expected %s", r.chainID, p.chainID)
+			} else {
+				ep.chainOK = true
+			}
+		}
+
+		wasHealthy := ep.healthy
+		switch {
+		case r.err != nil:
+			ep.healthy = false
+			ep.lastErr = r.err.Error()
+		default:
+			ep.block = r.block
+			ep.lag = head - r.block
+			ep.latency = r.latency
+			ep.healthy = ep.chainOK
+			switch {
+			case p.settings.MaxBlockLag > 0 && ep.lag > p.settings.MaxBlockLag:
+				ep.healthy = false
+				ep.lastErr = fmt.Sprintf("%d blocks behind", ep.lag)
+			case p.settings.MaxLatency > 0 && r.latency > p.settings.MaxLatency:
+				ep.healthy = false
+				ep.lastErr = fmt.Sprintf("latency %s above %s", r.latency.Round(time.Millisecond), p.settings.MaxLatency)
+			case ep.healthy:
+				ep.failures = 0
+				ep.lastErr = ""
+			}
+		}
+		p.metrics.setEndpointUp(ep.name, ep.healthy)
+		if wasHealthy != ep.healthy {
+			logging.FromContext(ctx).Warn("rpc endpoint health changed", "endpoint", ep.name, "healthy", ep.healthy, "reason", ep.lastErr)
+		}
+	}
+}
+
+func (p *rpcPool) probe(ctx context.Context, ep *rpcEndpoint) probe {
+	timeout := healthCheckTimeout
+	if p.settings.MaxLatency > 0 && 2*p.settings.MaxLatency < timeout {
+		timeout = 2 * p.settings.MaxLatency
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var r probe
+	p.mu.RLock()
+	verified := ep.chainOK
+	p.mu.RUnlock()
+	if !verified {
+		id, err := ep.client.ChainID(ctx)
+		if err != nil {
+			r.err = fmt.Errorf("chain id: %w", err)
+			return r
+		}
+		r.chainID = id
+	}
+
+	start := time.Now()
+	r.block, r.err = ep.client.BlockNumber(ctx)
+	r.latency = time.Since(start)
+	return r
+}
+
+func (p *rpcPool) monitor(ctx context.Context) {
+	interval := p.settings.CheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkAll(ctx)
+		}
+	}
+}
+
+func (p *rpcPool) statuses() []EndpointStatus {
+	active := p.pick()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		out = append(out, EndpointStatus{
+			Name:                ep.name,
+			Priority:            ep.priority,
+			Healthy:             ep.healthy,
+			Active:              ep == active,
+			BlockNumber:         ep.block,
+			BlockLag:            ep.lag,
+			LatencyMs:           float64(ep.latency.Microseconds()) / 1000.0,
+			ConsecutiveFailures: ep.failures,
+			LastError:           ep.lastErr,
+			CheckedAt:           ep.checkedAt,
+		})
+	}
+	return out
+}
+
+// isEndpointFailure separates a node being unreachable or broken from the node
+// answering with an error: only the former should trigger failover.
+func isEndpointFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	return true
+}
+
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
@@ -0,0 +1,218 @@
+package escrow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// fakeNode answers the handful of JSON-RPC methods the pool uses.
+type fakeNode struct {
+	mu      sync.Mutex
+	down    bool
+	chainID int64
+	block   uint64
+	sendErr string
+	raw     []string
+	srv     *httptest.Server
+}
+
+func newFakeNode(t *testing.T, block uint64) *fakeNode {
+	t.Helper()
+	n := &fakeNode{chainID: 31337, block: block}
+	n.srv = httptest.NewServer(http.HandlerFunc(n.serve))
+	t.Cleanup(n.srv.Close)
+	return n
+}
+
+func (n *fakeNode) set(fn func(n *fakeNode)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fn(n)
+}
+
+func (n *fakeNode) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.raw...)
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	var result interface{}
+	var rpcErr interface{}
+	switch req.Method {
+	case "eth_chainId":
+		result = fmt.Sprintf("0x%x", n.chainID)
+	case "eth_blockNumber":
+		result = fmt.Sprintf("0x%x", n.block)
+	case "eth_sendRawTransaction":
+		var raw string
+		_ = json.Unmarshal(req.Params[0], &raw)
+		n.raw = append(n.raw, raw)
+		if n.sendErr != "" {
+			rpcErr = map[string]interface{}{"code": -32000, "message": n.sendErr}
+		}
+	case "eth_getTransactionByHash":
+		result = nil
+	default:
+		rpcErr = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newTestPool(t *testing.T, settings HealthSettings, nodes ...*fakeNode) *rpcPool {
+	t.Helper()
+	var endpoints []Endpoint
+	for i, n := range nodes {
+		endpoints = append(endpoints, Endpoint{URL: n.srv.URL, Priority: i})
+	}
+	pool, err := newRPCPool(context.Background(), endpoints, settings, 0, NewRPCMetrics())
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	pool.expectChainID(big.NewInt(31337))
+	return pool
+}
+
+func signedTestTx(t *testing.T) *types.Transaction {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 7, To: &to, Gas: 21000, GasPrice: big.NewInt(1)})
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(big.NewInt(31337)), key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func TestPoolFailsOverAndBack(t *testing.T) {
+	primary, backup := newFakeNode(t, 100), newFakeNode(t, 100)
+	pool := newTestPool(t, HealthSettings{MaxBlockLag: 5}, primary, backup)
+
+	pool.checkAll(context.Background())
+	if got := pool.pick(); got.name != endpointName(primary.srv.URL) {
+		t.Fatalf("expected primary to be active, got %s", got.name)
+	}
+
+	primary.set(func(n *fakeNode) { n.down = true })
+	pool.checkAll(context.Background())
+	if got := pool.pick(); got.name != endpointName(backup.srv.URL) {
+		t.Fatalf("expected failover to backup, got %s", got.name)
+	}
+
+	primary.set(func(n *fakeNode) { n.down = false })
+	pool.checkAll(context.Background())
+	if got := pool.pick(); got.name != endpointName(primary.srv.URL) {
+		t.Fatalf("expected failback to primary, got %s", got.name)
+	}
+}
+
+func TestPoolSkipsLaggingAndWrongChainNodes(t *testing.T) {
+	primary, wrongChain, backup := newFakeNode(t, 90), newFakeNode(t, 100), newFakeNode(t, 100)
+	wrongChain.set(func(n *fakeNode) { n.chainID = 1 })
+	pool := newTestPool(t, HealthSettings{MaxBlockLag: 5}, primary, wrongChain, backup)
+
+	pool.checkAll(context.Background())
+	if got := pool.pick(); got.name != endpointName(backup.srv.URL) {
+		t.Fatalf("expected backup to be active, got %s", got.name)
+	}
+
+	statuses := pool.statuses()
+	if statuses[0].Healthy || statuses[0].BlockLag != 10 {
+		t.Fatalf("expected lagging primary to be unhealthy with lag 10, got %+v", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].LastError == "" {
+		t.Fatalf("expected wrong-chain node to be unhealthy, got %+v", statuses[1])
+	}
+	if !statuses[2].Active {
+		t.Fatalf("expected backup to be reported active, got %+v", statuses[2])
+	}
+}
+
+func TestPoolCallFailsOverAfterThreshold(t *testing.T) {
+	primary, backup := newFakeNode(t, 100), newFakeNode(t, 100)
+	pool := newTestPool(t, HealthSettings{FailureThreshold: 2}, primary, backup)
+	primary.set(func(n *fakeNode) { n.down = true })
+
+	for i := 0; i < 2; i++ {
+		err := pool.call(context.Background(), func(cli *ethclient.Client) error {
+			_, err := cli.BlockNumber(context.Background())
+			return err
+		})
+		if err != nil {
+			t.Fatalf("call %d: expected failover to succeed, got %v", i, err)
+		}
+	}
+	if got := pool.pick(); got.name != endpointName(backup.srv.URL) {
+		t.Fatalf("expected primary out of rotation after threshold, got %s", got.name)
+	}
+}
+
+func TestBroadcastResendsSameSignedTx(t *testing.T) {
+	primary, backup := newFakeNode(t, 100), newFakeNode(t, 100)
+	pool := newTestPool(t, HealthSettings{}, primary, backup)
+	tx := signedTestTx(t)
+
+	primary.set(func(n *fakeNode) { n.down = true })
+	if err := pool.broadcast(context.Background(), tx, pool.pick()); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	sent := backup.sent()
+	raw, _ := tx.MarshalBinary()
+	if len(sent) != 1 || sent[0] != fmt.Sprintf("0x%x", raw) {
+		t.Fatalf("expected backup to receive the identical signed tx, got %v", sent)
+	}
+}
+
+func TestBroadcastDoesNotFailOverOnRejection(t *testing.T) {
+	primary, backup := newFakeNode(t, 100), newFakeNode(t, 100)
+	pool := newTestPool(t, HealthSettings{}, primary, backup)
+	primary.set(func(n *fakeNode) { n.sendErr = "insufficient funds for gas * price + value" })
+
+	if err := pool.broadcast(context.Background(), signedTestTx(t), pool.pick()); err == nil {
+		t.Fatalf("expected rejection to be returned")
+	}
+	if len(backup.sent()) != 0 {
+		t.Fatalf("rejected tx must not be sent to another node")
+	}
+
+	primary.set(func(n *fakeNode) { n.sendErr = "already known" })
+	if err := pool.broadcast(context.Background(), signedTestTx(t), pool.pick()); err != nil {
+		t.Fatalf("already known should count as sent, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package escrow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"fiatrails/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("fiatrails/internal/escrow")
+
+// rpcTransport sits under ethclient so every JSON-RPC call (gas estimation,
+// nonce lookup, send, receipt polling) gets its own client span, metrics and,
+// when timeout is set, its own deadline.
+type rpcTransport struct {
+	base    http.RoundTripper
+	metrics *RPCMetrics
+	timeout time.Duration
+}
+
+func newRPCHTTPClient(metrics *RPCMetrics, timeout time.Duration) *http.Client {
+	return &http.Client{Transport: &rpcTransport{base: http.DefaultTransport, metrics: metrics, timeout: timeout}}
+}
+
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+func (t *rpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var calls []rpcMessage
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			blob, _ := io.ReadAll(body)
+			_ = body.Close()
+			calls = decodeRPCMessages(blob)
+		}
+	}
+	if len(calls) == 0 {
+		calls = []rpcMessage{{}}
+	}
+	for i := range calls {
+		if calls[i].Method == "" {
+			calls[i].Method = "unknown"
+		}
+	}
+	method := spanMethod(calls)
+
+	ctx, span := tracer.Start(req.Context(), "rpc "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+	// A hung node must not hold the caller for its whole request budget: each
+	// call gets its own deadline so the pool can fail over in time.
+	parent := ctx
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		// Buffer the body while the deadline is still live; ethclient reads
+		// it after RoundTrip returns and the deferred cancel has run.
+		if err = bufferBody(resp); err != nil {
+			resp = nil
+		}
+	}
+
+	failedAll := err != nil
+	var failedIDs map[string]bool
+	if err == nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			failedAll = true
+		} else {
+			failedIDs = rpcErrorIDs(resp)
+		}
+	}
+	if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		for _, c := range calls {
+			t.metrics.incTimeout(c.Method)
+		}
+		err = fmt.Errorf("rpc call timed out after %s: %w", t.timeout, err)
+	}
+	elapsed := time.Since(start)
+
+	// A lone call owns whatever error came back, whatever ID the node echoed.
+	if len(calls) == 1 && len(failedIDs) > 0 {
+		failedAll = true
+	}
+	for _, c := range calls {
+		t.metrics.observe(c.Method, elapsed, failedAll || failedIDs[string(c.ID)])
+	}
+	if err == nil && (failedAll || len(failedIDs) > 0) {
+		span.SetStatus(codes.Error, "json-rpc error")
+	}
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		// Surface throttling as an error carrying the node's Retry-After so
+		// callers back off for as long as asked instead of their own schedule.
+		err = &RateLimitError{Endpoint: req.URL.Host, Delay: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		resp = nil
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// bufferBody reads the response body into memory so it can be inspected and
+// still handed to ethclient.
+func bufferBody(resp *http.Response) error {
+	blob, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(blob))
+	return err
+}
+
+// rpcErrorIDs returns the IDs of buffered responses carrying a JSON-RPC error.
+func rpcErrorIDs(resp *http.Response) map[string]bool {
+	blob, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(blob))
+	var failed map[string]bool
+	for _, m := range decodeRPCMessages(blob) {
+		if len(m.Error) > 0 && string(m.Error) != "null" {
+			if failed == nil {
+				failed = make(map[string]bool)
+			}
+			failed[string(m.ID)] = true
+		}
+	}
+	return failed
+}
+
+// decodeRPCMessages accepts a single JSON-RPC message or a batch.
+func decodeRPCMessages(body []byte) []rpcMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if body[0] == '[' {
+		var batch []rpcMessage
+		if json.Unmarshal(body, &batch) != nil {
+			return nil
+		}
+		return batch
+	}
+	var msg rpcMessage
+	if json.Unmarshal(body, &msg) != nil {
+		return nil
+	}
+	return []rpcMessage{msg}
+}
+
+// spanMethod names a call; batches are named after their distinct methods
+// joined by commas.
+func spanMethod(calls []rpcMessage) string {
+	var names []string
+	seen := map[string]bool{}
+	for _, c := range calls {
+		if !seen[c.Method] {
+			seen[c.Method] = true
+			names = append(names, c.Method)
+		}
+	}
+	return strings.Join(names, ",")
+}
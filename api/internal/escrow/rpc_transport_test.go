@@ -0,0 +1,159 @@
+package escrow
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestNode(t *testing.T, handler http.HandlerFunc) (*ethclient.Client, *RPCMetrics) {
+	t.Helper()
+	return newTimedTestNode(t, 0, handler)
+}
+
+func newTimedTestNode(t *testing.T, timeout time.Duration, handler http.HandlerFunc) (*ethclient.Client, *RPCMetrics) {
+	t.Helper()
+	node := httptest.NewServer(handler)
+	t.Cleanup(node.Close)
+
+	metrics := NewRPCMetrics()
+	rpcClient, err := rpc.DialOptions(context.Background(), node.URL, rpc.WithHTTPClient(newRPCHTTPClient(metrics, timeout)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return ethclient.NewClient(rpcClient), metrics
+}
+
+func TestRPCTransportSpanPerMethod(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	cli, _ := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	if _, err := cli.BlockNumber(ctx); err != nil {
+		t.Fatalf("block number: %v", err)
+	}
+	parent.End()
+
+	var rpcSpan sdktrace.ReadOnlySpan
+	for _, s := range exp.GetSpans().Snapshots() {
+		if s.Name() == "rpc eth_blockNumber" {
+			rpcSpan = s
+		}
+	}
+	if rpcSpan == nil {
+		t.Fatalf("no rpc span recorded: %+v", exp.GetSpans())
+	}
+	if rpcSpan.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("expected client span, got %v", rpcSpan.SpanKind())
+	}
+	if rpcSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("rpc span not parented to caller span")
+	}
+}
+
+func TestRPCTransportMetrics(t *testing.T) {
+	cli, metrics := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "eth_gasPrice") {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"boom"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cli.BlockNumber(context.Background()); err != nil {
+			t.Fatalf("block number: %v", err)
+		}
+	}
+	if _, err := cli.SuggestGasPrice(context.Background()); err == nil {
+		t.Fatalf("expected json-rpc error")
+	}
+
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues("eth_blockNumber")); got != 2 {
+		t.Fatalf("eth_blockNumber requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.errors.WithLabelValues("eth_blockNumber")); got != 0 {
+		t.Fatalf("eth_blockNumber errors = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(metrics.errors.WithLabelValues("eth_gasPrice")); got != 1 {
+		t.Fatalf("eth_gasPrice errors = %v, want 1", got)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics)
+	if n, err := testutil.GatherAndCount(reg, "fiatrails_rpc_duration_seconds"); err != nil || n != 2 {
+		t.Fatalf("expected duration series for 2 methods, got %d (%v)", n, err)
+	}
+}
+
+func TestRPCTransportPerCallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	cli, metrics := newTimedTestNode(t, 50*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "eth_gasPrice") {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	})
+
+	start := time.Now()
+	_, err := cli.SuggestGasPrice(context.Background())
+	if err == nil {
+		t.Fatal("expected timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("call held for %v despite 50ms deadline", elapsed)
+	}
+	if !isEndpointFailure(context.Background(), err) {
+		t.Fatalf("timeout should count against the node: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.timeouts.WithLabelValues("eth_gasPrice")); got != 1 {
+		t.Fatalf("eth_gasPrice timeouts = %v, want 1", got)
+	}
+
+	// Fast calls, whose body is read after RoundTrip returns, are unaffected.
+	if _, err := cli.BlockNumber(context.Background()); err != nil {
+		t.Fatalf("block number: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.timeouts.WithLabelValues("eth_blockNumber")); got != 0 {
+		t.Fatalf("eth_blockNumber timeouts = %v, want 0", got)
+	}
+}
+
+func TestDecodeRPCBatch(t *testing.T) {
+	calls := decodeRPCMessages([]byte(`[{"id":1,"method":"eth_getBalance"},{"id":2,"method":"eth_gasPrice"},{"id":3,"method":"eth_getBalance"}]`))
+	if got := spanMethod(calls); got != "eth_getBalance,eth_gasPrice" {
+		t.Fatalf("unexpected batch name %q", got)
+	}
+	if calls := decodeRPCMessages([]byte(`not json`)); calls != nil {
+		t.Fatalf("expected no calls, got %+v", calls)
+	}
+}
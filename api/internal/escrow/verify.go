@@ -0,0 +1,288 @@
+package escrow
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"fiatrails/internal/contracts"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ERC-165 interface IDs checked on contracts built on OpenZeppelin AccessControl.
+var (
+	ierc165ID        = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+	iaccessControlID = [4]byte{0x79, 0x65, 0xdb, 0x0b}
+	invalidID        = [4]byte{0xff, 0xff, 0xff, 0xff}
+
+	executorRole = crypto.Keccak256Hash([]byte("EXECUTOR_ROLE"))
+	minterRole   = crypto.Keccak256Hash([]byte("MINTER_ROLE"))
+)
+
+// ChainReader is the read-only node access verification needs;
+// *ethclient.Client implements it.
+type ChainReader interface {
+	bind.ContractCaller
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// DeploymentSpec is what the FiatRails contracts on chain are expected to look like.
+type DeploymentSpec struct {
+	ChainID           int64
+	USDStablecoin     string
+	CountryToken      string
+	UserRegistry      string
+	ComplianceManager string
+	MintEscrow        string
+	// Executors must hold EXECUTOR_ROLE on MintEscrow.
+	Executors []string
+	// CountryCode is the key MintEscrow maps to CountryToken.
+	CountryCode          string
+	StablecoinDecimals   uint8
+	CountryTokenDecimals uint8
+}
+
+// VerifyCheck is the result of one verification step.
+type VerifyCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// VerifyReport lists every check run against a deployment.
+type VerifyReport struct {
+	Checks []VerifyCheck
+}
+
+// Err summarises the failed checks, or returns nil when all passed.
+func (r *VerifyReport) Err() error {
+	var failed []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c.Name+": "+c.Detail)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("deployment verification failed:\n  - %s", strings.Join(failed, "\n  - "))
+}
+
+func (r *VerifyReport) add(name string, ok bool, format string, args ...any) {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+}
+
+// VerifyDeployment checks that the node serves the expected chain, that each
+// contract has code, answers ERC-165 where it should, and that MintEscrow,
+// CountryToken and the roles between them are wired as spec describes.
+// Checks against a contract without code are skipped.
+func VerifyDeployment(ctx context.Context, backend ChainReader, spec DeploymentSpec) *VerifyReport {
+	report := &VerifyReport{}
+	views, err := abi.JSON(strings.NewReader(string(contracts.ViewsABI)))
+	if err != nil {
+		report.add("abi", false, "parse views abi: %v", err)
+		return report
+	}
+	escrowABI, err := abi.JSON(strings.NewReader(string(contracts.MintEscrowABI)))
+	if err != nil {
+		report.add("abi", false, "parse MintEscrow abi: %v", err)
+		return report
+	}
+
+	switch id, err := backend.ChainID(ctx); {
+	case err != nil:
+		report.add("chain id", false, "fetch: %v", err)
+	case id.Int64() != spec.ChainID:
+		report.add("chain id", false, "node reports %s, deployments.json expects %d", id, spec.ChainID)
+	default:
+		report.add("chain id", true, "%s", id)
+	}
+
+	deployed := make(map[string]bool)
+	for _, c := range []struct{ name, addr string }{
+		{"USDStablecoin", spec.USDStablecoin},
+		{"CountryToken", spec.CountryToken},
+		{"UserRegistry", spec.UserRegistry},
+		{"ComplianceManager", spec.ComplianceManager},
+		{"MintEscrow", spec.MintEscrow},
+	} {
+		name := c.name + " code"
+		if !common.IsHexAddress(c.addr) || common.HexToAddress(c.addr) == (common.Address{}) {
+			report.add(name, false, "%q is not a contract address", c.addr)
+			continue
+		}
+		code, err := backend.CodeAt(ctx, common.HexToAddress(c.addr), nil)
+		switch {
+		case err != nil:
+			report.add(name, false, "fetch code at %s: %v", c.addr, err)
+		case len(code) == 0:
+			report.add(name, false, "no contract code at %s", c.addr)
+		default:
+			report.add(name, true, "%d bytes at %s", len(code), c.addr)
+			deployed[c.name] = true
+		}
+	}
+
+	call := func(parsed abi.ABI, addr, method string, args ...any) ([]any, error) {
+		contract := bind.NewBoundContract(common.HexToAddress(addr), parsed, backend, nil, nil)
+		var out []any
+		err := contract.Call(&bind.CallOpts{Context: ctx}, &out, method, args...)
+		return out, err
+	}
+	callBool := func(parsed abi.ABI, addr, method string, args ...any) (bool, error) {
+		out, err := call(parsed, addr, method, args...)
+		if err != nil {
+			return false, err
+		}
+		return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+	}
+	callAddress := func(addr, method string, args ...any) (common.Address, error) {
+		out, err := call(escrowABI, addr, method, args...)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+	}
+
+	// USDStablecoin is a plain ERC-20 and does not implement ERC-165.
+	for _, c := range []struct{ name, addr string }{
+		{"CountryToken", spec.CountryToken},
+		{"UserRegistry", spec.UserRegistry},
+		{"ComplianceManager", spec.ComplianceManager},
+		{"MintEscrow", spec.MintEscrow},
+	} {
+		if !deployed[c.name] {
+			continue
+		}
+		name := c.name + " ERC-165"
+		erc165, err := callBool(views, c.addr, "supportsInterface", ierc165ID)
+		if err != nil {
+			report.add(name, false, "supportsInterface: %v", err)
+			continue
+		}
+		invalid, _ := callBool(views, c.addr, "supportsInterface", invalidID)
+		accessControl, _ := callBool(views, c.addr, "supportsInterface", iaccessControlID)
+		report.add(name, erc165 && !invalid && accessControl,
+			"IERC165=%t IAccessControl=%t 0xffffffff=%t", erc165, accessControl, invalid)
+	}
+
+	if deployed["MintEscrow"] {
+		for _, p := range []struct {
+			name, method, want string
+			args               []any
+		}{
+			{"MintEscrow stablecoin", "stablecoin", spec.USDStablecoin, nil},
+			{"MintEscrow userRegistry", "userRegistry", spec.UserRegistry, nil},
+			{"MintEscrow country token", "getCountryToken", spec.CountryToken, []any{toBytes32(spec.CountryCode)}},
+		} {
+			got, err := callAddress(spec.MintEscrow, p.method, p.args...)
+			switch {
+			case err != nil:
+				report.add(p.name, false, "%s: %v", p.method, err)
+			case got != common.HexToAddress(p.want):
+				report.add(p.name, false, "points to %s, deployments.json has %s", got.Hex(), p.want)
+			default:
+				report.add(p.name, true, "%s", got.Hex())
+			}
+		}
+
+		seen := make(map[common.Address]bool)
+		for _, executor := range spec.Executors {
+			if seen[common.HexToAddress(executor)] {
+				continue
+			}
+			seen[common.HexToAddress(executor)] = true
+			name := "EXECUTOR_ROLE " + executor
+			ok, err := callBool(views, spec.MintEscrow, "hasRole", executorRole, common.HexToAddress(executor))
+			switch {
+			case err != nil:
+				report.add(name, false, "hasRole: %v", err)
+			case !ok:
+				report.add(name, false, "%s lacks EXECUTOR_ROLE on MintEscrow", executor)
+			default:
+				report.add(name, true, "granted")
+			}
+		}
+	}
+
+	if deployed["CountryToken"] && deployed["MintEscrow"] {
+		ok, err := callBool(views, spec.CountryToken, "hasRole", minterRole, common.HexToAddress(spec.MintEscrow))
+		switch {
+		case err != nil:
+			report.add("MINTER_ROLE", false, "hasRole: %v", err)
+		case !ok:
+			report.add("MINTER_ROLE", false, "CountryToken has not granted MINTER_ROLE to MintEscrow")
+		default:
+			report.add("MINTER_ROLE", true, "granted to MintEscrow")
+		}
+	}
+
+	for _, d := range []struct {
+		name, key, addr string
+		want            uint8
+	}{
+		{"USDStablecoin decimals", "USDStablecoin", spec.USDStablecoin, spec.StablecoinDecimals},
+		{"CountryToken decimals", "CountryToken", spec.CountryToken, spec.CountryTokenDecimals},
+	} {
+		if !deployed[d.key] {
+			continue
+		}
+		out, err := call(views, d.addr, "decimals")
+		if err != nil {
+			report.add(d.name, false, "decimals: %v", err)
+			continue
+		}
+		got := *abi.ConvertType(out[0], new(uint8)).(*uint8)
+		report.add(d.name, got == d.want, "contract has %d, seed.json has %d", got, d.want)
+	}
+	return report
+}
+
+// VerifyDeployment runs VerifyDeployment through the client's RPC pool and
+// also requires the signing account to hold EXECUTOR_ROLE.
+func (c *EthClient) VerifyDeployment(ctx context.Context, spec DeploymentSpec) *VerifyReport {
+	if c.transacts != nil {
+		spec.Executors = append(spec.Executors, c.transacts.From.Hex())
+	}
+	return VerifyDeployment(ctx, poolReader{c.pool}, spec)
+}
+
+// poolReader sends read-only calls through the pool so verification fails
+// over like any other call.
+type poolReader struct {
+	pool *rpcPool
+}
+
+func (r poolReader) ChainID(ctx context.Context) (*big.Int, error) {
+	var id *big.Int
+	err := r.pool.call(ctx, func(cli *ethclient.Client) (err error) {
+		id, err = cli.ChainID(ctx)
+		return err
+	})
+	return id, err
+}
+
+func (r poolReader) CodeAt(ctx context.Context, addr common.Address, block *big.Int) ([]byte, error) {
+	var code []byte
+	err := r.pool.call(ctx, func(cli *ethclient.Client) (err error) {
+		code, err = cli.CodeAt(ctx, addr, block)
+		return err
+	})
+	return code, err
+}
+
+func (r poolReader) CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	var out []byte
+	err := r.pool.call(ctx, func(cli *ethclient.Client) (err error) {
+		out, err = cli.CallContract(ctx, msg, block)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,159 @@
+package escrow
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"fiatrails/internal/contracts"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// stubChain answers verification calls from an in-memory deployment.
+type stubChain struct {
+	chainID  int64
+	code     map[common.Address]bool
+	abis     []abi.ABI
+	pointers map[string]common.Address // MintEscrow view method -> address
+	roles    map[common.Address]map[common.Hash]map[common.Address]bool
+	decimals map[common.Address]uint8
+}
+
+func newStubChain(t *testing.T, spec DeploymentSpec) *stubChain {
+	t.Helper()
+	var abis []abi.ABI
+	for _, raw := range [][]byte{contracts.MintEscrowABI, contracts.ViewsABI} {
+		parsed, err := abi.JSON(strings.NewReader(string(raw)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		abis = append(abis, parsed)
+	}
+	addr := common.HexToAddress
+	c := &stubChain{
+		chainID: spec.ChainID,
+		code:    make(map[common.Address]bool),
+		abis:    abis,
+		pointers: map[string]common.Address{
+			"stablecoin":      addr(spec.USDStablecoin),
+			"userRegistry":    addr(spec.UserRegistry),
+			"getCountryToken": addr(spec.CountryToken),
+		},
+		roles: map[common.Address]map[common.Hash]map[common.Address]bool{
+			addr(spec.MintEscrow):   {executorRole: {addr(spec.Executors[0]): true}},
+			addr(spec.CountryToken): {minterRole: {addr(spec.MintEscrow): true}},
+		},
+		decimals: map[common.Address]uint8{
+			addr(spec.USDStablecoin): spec.StablecoinDecimals,
+			addr(spec.CountryToken):  spec.CountryTokenDecimals,
+		},
+	}
+	for _, a := range []string{spec.USDStablecoin, spec.CountryToken, spec.UserRegistry, spec.ComplianceManager, spec.MintEscrow} {
+		c.code[addr(a)] = true
+	}
+	return c
+}
+
+func (c *stubChain) ChainID(context.Context) (*big.Int, error) {
+	return big.NewInt(c.chainID), nil
+}
+
+func (c *stubChain) CodeAt(_ context.Context, addr common.Address, _ *big.Int) ([]byte, error) {
+	if c.code[addr] {
+		return []byte{0x60, 0x80}, nil
+	}
+	return nil, nil
+}
+
+func (c *stubChain) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	to := *msg.To
+	for _, parsed := range c.abis {
+		method, err := parsed.MethodById(msg.Data[:4])
+		if err != nil {
+			continue
+		}
+		args, err := method.Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+		var out any
+		switch method.Name {
+		case "supportsInterface":
+			id := args[0].([4]byte)
+			out = id == ierc165ID || id == iaccessControlID
+		case "hasRole":
+			out = c.roles[to][common.Hash(args[0].([32]byte))][args[1].(common.Address)]
+		case "decimals":
+			out = c.decimals[to]
+		default:
+			out = c.pointers[method.Name]
+		}
+		return method.Outputs.Pack(out)
+	}
+	return nil, fmt.Errorf("unexpected call %x", msg.Data[:4])
+}
+
+func testDeploymentSpec() DeploymentSpec {
+	return DeploymentSpec{
+		ChainID:              31337,
+		USDStablecoin:        "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+		CountryToken:         "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512",
+		UserRegistry:         "0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0",
+		ComplianceManager:    "0xDc64a140Aa3E981100a9becA4E685f962f0cF6C9",
+		MintEscrow:           "0x0165878A594ca255338adfa4d48449f69242Eb8F",
+		Executors:            []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"},
+		CountryCode:          "KES",
+		StablecoinDecimals:   18,
+		CountryTokenDecimals: 18,
+	}
+}
+
+func TestVerifyDeploymentPasses(t *testing.T) {
+	spec := testDeploymentSpec()
+	report := VerifyDeployment(context.Background(), newStubChain(t, spec), spec)
+	if err := report.Err(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// chain id, 5 code, 4 ERC-165, 3 pointers, 1 executor, minter, 2 decimals
+	if len(report.Checks) != 17 {
+		t.Fatalf("expected 17 checks, got %d: %+v", len(report.Checks), report.Checks)
+	}
+}
+
+func TestVerifyDeploymentReportsMiswiring(t *testing.T) {
+	spec := testDeploymentSpec()
+	chain := newStubChain(t, spec)
+	chain.pointers["stablecoin"] = common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	chain.roles[common.HexToAddress(spec.CountryToken)] = nil
+	chain.decimals[common.HexToAddress(spec.USDStablecoin)] = 6
+	delete(chain.code, common.HexToAddress(spec.UserRegistry))
+
+	want := spec
+	want.ChainID = 1
+	want.Executors = append(want.Executors, "0x70997970C51812dc3A010C7d01b50e20d17dc79C")
+
+	err := VerifyDeployment(context.Background(), chain, want).Err()
+	if err == nil {
+		t.Fatal("expected verification to fail")
+	}
+	for _, msg := range []string{
+		"chain id: node reports 31337, deployments.json expects 1",
+		"UserRegistry code: no contract code",
+		"MintEscrow stablecoin: points to 0x000000000000000000000000000000000000dEaD",
+		"0x70997970C51812dc3A010C7d01b50e20d17dc79C lacks EXECUTOR_ROLE",
+		"CountryToken has not granted MINTER_ROLE to MintEscrow",
+		"USDStablecoin decimals: contract has 6, seed.json has 18",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("missing %q in:\n%v", msg, err)
+		}
+	}
+	if strings.Contains(err.Error(), "UserRegistry ERC-165") {
+		t.Errorf("checks against a contract without code should be skipped:\n%v", err)
+	}
+}
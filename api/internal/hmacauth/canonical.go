@@ -0,0 +1,104 @@
+package hmacauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Signature scheme versions carried as a "v2=" style prefix on the signature
+// header. An unprefixed signature is treated as v1.
+const (
+	SignatureV1 = "v1"
+	SignatureV2 = "v2"
+
+	canonicalV2Algorithm = "FIATRAILS-HMAC-SHA256-V2"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported signature version")
+
+// parseSignature splits a signature header into its scheme version and hex digest.
+func parseSignature(value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	version, sig, found := strings.Cut(value, "=")
+	if !found {
+		return SignatureV1, strings.ToLower(value), nil
+	}
+	switch version {
+	case SignatureV1, SignatureV2:
+		return version, strings.ToLower(sig), nil
+	default:
+		return "", "", ErrUnsupportedVersion
+	}
+}
+
+// CanonicalRequest builds the v2 string to sign:
+//
+//	FIATRAILS-HMAC-SHA256-V2
+//	<timestamp>
+//	<nonce>
+//	<METHOD>
+//	<escaped path>
+//	<query sorted by key then value>
+//	<signed header names, lowercase, ';'-joined>
+//	<name>:<trimmed value>   (one line per signed header)
+//	<hex sha256 of body>
+func CanonicalRequest(r *http.Request, timestamp, nonce string, headers []string, body []byte) string {
+	names := make([]string, 0, len(headers))
+	for _, h := range headers {
+		names = append(names, strings.ToLower(h))
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(canonicalV2Algorithm + "\n")
+	b.WriteString(timestamp + "\n")
+	b.WriteString(nonce + "\n")
+	b.WriteString(strings.ToUpper(r.Method) + "\n")
+	b.WriteString(canonicalPath(r.URL) + "\n")
+	b.WriteString(canonicalQuery(r.URL.Query()) + "\n")
+	b.WriteString(strings.Join(names, ";") + "\n")
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(r.Header.Get(name)) + "\n")
+	}
+	bodyHash := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+	return b.String()
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(values))
+	for _, k := range keys {
+		vals := append([]string(nil), values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func computeSignatureV2(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package hmacauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalRequest_NormalisesQueryAndHeaders(t *testing.T) {
+	a := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents?b=2&a=3&a=1", nil)
+	a.Header.Set("X-Idempotency-Key", "  key-1 ")
+	b := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents?a=1&b=2&a=3", nil)
+	b.Header.Set("X-Idempotency-Key", "key-1")
+
+	ca := CanonicalRequest(a, "100", "n", []string{"X-Idempotency-Key"}, []byte("{}"))
+	cb := CanonicalRequest(b, "100", "n", []string{"x-idempotency-key"}, []byte("{}"))
+	if ca != cb {
+		t.Fatalf("expected equal canonical requests:\n%s\n---\n%s", ca, cb)
+	}
+	if !strings.Contains(ca, "\na=1&a=3&b=2\n") {
+		t.Fatalf("query not sorted:\n%s", ca)
+	}
+}
+
+func TestVerifier_V2RejectsTamperedQuery(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{}`)
+	v := &Verifier{Secret: "secret", MaxSkew: time.Minute, Now: func() time.Time { return now }}
+	signer := &Signer{Secret: "secret", Now: func() time.Time { return now }}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/mint-intents?status=executed", nil)
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if !strings.HasPrefix(req.Header.Get(defaultSignatureHeader), "v2=") {
+		t.Fatalf("expected v2 prefix, got %q", req.Header.Get(defaultSignatureHeader))
+	}
+	if res, err := v.verify(withBody(req, body)); err != nil || res.version != SignatureV2 {
+		t.Fatalf("expected v2 verification, got %+v %v", res, err)
+	}
+
+	req.URL.RawQuery = "status=refunded"
+	if _, err := v.verify(withBody(req, body)); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerifier_VersionNegotiation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := `{"foo":"bar"}`
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := computeSignature("secret", ts, []byte(body))
+
+	cases := []struct {
+		name      string
+		header    string
+		disableV1 bool
+		want      error
+	}{
+		{name: "bare v1", header: sig},
+		{name: "prefixed v1", header: "v1=" + sig},
+		{name: "v1 disabled", header: sig, disableV1: true, want: ErrUnsupportedVersion},
+		{name: "unknown version", header: "v9=" + sig, want: ErrUnsupportedVersion},
+	}
+
+	for _, tc := range cases {
+		v := &Verifier{
+			Secret:    "secret",
+			MaxSkew:   time.Minute,
+			Now:       func() time.Time { return now },
+			DisableV1: tc.disableV1,
+		}
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		req.Header.Set(defaultSignatureHeader, tc.header)
+		req.Header.Set(defaultTimestampHeader, ts)
+		if _, err := v.verify(req); err != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func withBody(r *http.Request, body []byte) *http.Request {
+	clone := r.Clone(r.Context())
+	clone.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body))).Body
+	return clone
+}
@@ -0,0 +1,194 @@
+package hmacauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Key is a single HMAC secret with an optional validity window.
+type Key struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"`
+	NotBefore time.Time `json:"notBefore,omitempty"`
+	NotAfter  time.Time `json:"notAfter,omitempty"`
+}
+
+func (k Key) activeAt(t time.Time) bool {
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && !t.Before(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// Keyring holds the set of secrets a Verifier accepts. It is safe for
+// concurrent use and can be reloaded from disk while serving traffic.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    []Key
+	path    string
+	modTime time.Time
+}
+
+type keyringFile struct {
+	Keys []Key `json:"keys"`
+}
+
+func NewKeyring(keys ...Key) *Keyring {
+	return &Keyring{keys: keys}
+}
+
+// LoadKeyring reads a keyring JSON file of the form {"keys":[{"id":..,"secret":..}]}.
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.LoadFile(path); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadFile replaces the keys with the contents of path and remembers it for Reload.
+func (k *Keyring) LoadFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	keys, err := readKeyringFile(path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.path = path
+	k.modTime = info.ModTime()
+	return nil
+}
+
+// Reload re-reads the file passed to LoadFile. The current keys are kept if the file is invalid.
+func (k *Keyring) Reload() error {
+	k.mu.RLock()
+	path := k.path
+	k.mu.RUnlock()
+	if path == "" {
+		return errors.New("keyring has no backing file")
+	}
+	return k.LoadFile(path)
+}
+
+// Watch polls the backing file and reloads it whenever its modification time changes.
+func (k *Keyring) Watch(ctx context.Context, interval time.Duration, onErr func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		k.mu.RLock()
+		path, last := k.path, k.modTime
+		k.mu.RUnlock()
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().Equal(last) {
+			continue
+		}
+		if err == nil {
+			err = k.LoadFile(path)
+		}
+		if err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
+
+// Lookup returns the key with the given ID.
+func (k *Keyring) Lookup(id string) (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Active returns the keys valid at t.
+func (k *Keyring) Active(t time.Time) []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	out := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		if key.activeAt(t) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// IDs returns the ID of every key, active or not.
+func (k *Keyring) IDs() []string {
+	if k == nil {
+		return nil
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
+
+func (k *Keyring) Len() int {
+	if k == nil {
+		return 0
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.keys)
+}
+
+func readKeyringFile(path string) ([]Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyringFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parse keyring: %w", err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, errors.New("keyring has no keys")
+	}
+
+	seen := make(map[string]bool, len(file.Keys))
+	for _, key := range file.Keys {
+		if key.ID == "" {
+			return nil, errors.New("keyring entry missing id")
+		}
+		if key.Secret == "" {
+			return nil, fmt.Errorf("keyring entry %q missing secret", key.ID)
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate keyring id %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	return file.Keys, nil
+}
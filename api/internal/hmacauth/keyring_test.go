@@ -0,0 +1,131 @@
+package hmacauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(secret, keyID, body string, now time.Time) *http.Request {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	req.Header.Set(defaultSignatureHeader, computeSignature(secret, ts, []byte(body)))
+	req.Header.Set(defaultTimestampHeader, ts)
+	if keyID != "" {
+		req.Header.Set(defaultKeyIDHeader, keyID)
+	}
+	return req
+}
+
+func TestKeyring_VerifiesWithSelectedKey(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	var verifiedWith string
+	v := &Verifier{
+		Keys: NewKeyring(
+			Key{ID: "old", Secret: "old-secret"},
+			Key{ID: "new", Secret: "new-secret"},
+		),
+		MaxSkew:    time.Minute,
+		Now:        func() time.Time { return now },
+		OnVerified: func(id, _ string) { verifiedWith = id },
+	}
+
+	for _, id := range []string{"old", "new"} {
+		rec := httptest.NewRecorder()
+		v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(rec, signedRequest(id+"-secret", id, `{}`, now))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("key %s: expected 200, got %d", id, rec.Code)
+		}
+		if verifiedWith != id {
+			t.Fatalf("expected key %s reported, got %s", id, verifiedWith)
+		}
+	}
+
+	// Without a key ID header every active key is tried.
+	if res, err := v.verify(signedRequest("new-secret", "", `{}`, now)); err != nil || res.keyID != "new" {
+		t.Fatalf("expected match on new key, got %q %v", res.keyID, err)
+	}
+	if _, err := v.verify(signedRequest("old-secret", "missing", `{}`, now)); err != ErrUnknownKey {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeyring_RespectsValidityWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v := &Verifier{
+		Keys: NewKeyring(
+			Key{ID: "expired", Secret: "s1", NotAfter: now.Add(-time.Hour)},
+			Key{ID: "future", Secret: "s2", NotBefore: now.Add(time.Hour)},
+		),
+		MaxSkew: time.Minute,
+		Now:     func() time.Time { return now },
+	}
+
+	if _, err := v.verify(signedRequest("s1", "expired", `{}`, now)); err != ErrInactiveKey {
+		t.Fatalf("expected ErrInactiveKey for expired key, got %v", err)
+	}
+	if _, err := v.verify(signedRequest("s2", "future", `{}`, now)); err != ErrInactiveKey {
+		t.Fatalf("expected ErrInactiveKey for future key, got %v", err)
+	}
+	if _, err := v.verify(signedRequest("s1", "", `{}`, now)); err != ErrInvalidSignature {
+		t.Fatalf("expected inactive key to be skipped, got %v", err)
+	}
+}
+
+func TestKeyring_ReloadsFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyring(t, path, `{"keys":[{"id":"k1","secret":"one"}]}`)
+
+	keys, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go keys.Watch(ctx, 5*time.Millisecond, nil)
+
+	writeKeyring(t, path, `{"keys":[{"id":"k2","secret":"two"}]}`)
+	// Make sure the mtime moves even on filesystems with coarse timestamps.
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := keys.Lookup("k2"); ok {
+			if _, stale := keys.Lookup("k1"); stale {
+				t.Fatalf("old key still present after reload")
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("keyring was not reloaded")
+}
+
+func TestKeyring_RejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyring(t, path, `{"keys":[{"id":"k1","secret":"one"},{"id":"k1","secret":"two"}]}`)
+
+	if _, err := LoadKeyring(path); err == nil {
+		t.Fatalf("expected duplicate id error")
+	}
+}
+
+func writeKeyring(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+}
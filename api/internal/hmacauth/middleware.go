@@ -1,12 +1,14 @@
 package hmacauth
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +17,11 @@ import (
 const (
 	defaultSignatureHeader = "X-Request-Signature"
 	defaultTimestampHeader = "X-Request-Timestamp"
+	defaultKeyIDHeader     = "X-Key-Id"
+	defaultNonceHeader     = "X-Request-Nonce"
+
+	// LegacyKeyID identifies the single Secret when no keyring entry matched.
+	LegacyKeyID = "default"
 )
 
 var (
@@ -22,30 +29,148 @@ var (
 	ErrMissingTimestamp = errors.New("missing request timestamp")
 	ErrStaleTimestamp   = errors.New("stale request timestamp")
 	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrInactiveKey      = errors.New("signing key not active")
+	ErrReplayedRequest  = errors.New("request signature already used")
+	ErrReplayCache      = errors.New("replay cache unavailable")
+	ErrKeyLookup        = errors.New("signing key lookup failed")
+	ErrNoKeys           = errors.New("no signing keys configured")
 )
 
+// defaultSignedHeaders are covered by v2 signatures unless SignedHeaders is set.
+var defaultSignedHeaders = []string{"X-Idempotency-Key"}
+
+// Verifier checks request signatures against Secret and, when set, every key in Keys.
+// Clients select a key with the key ID header; without it all active keys are tried.
+//
+// v1 signatures cover timestamp||body. v2 signatures ("v2=<hex>") cover the
+// CanonicalRequest: method, path, sorted query, SignedHeaders and body hash.
+// Set DisableV1 once every client has migrated. With Replay set, a v2 signature
+// carrying a nonce is accepted once within the clock skew window; the claim is
+// dropped if the handler answers 5xx so the client may resend it. v1 and
+// nonce-less signatures are left to the handler's own idempotency.
 type Verifier struct {
-	Secret          string
+	Secret string
+	// SecretFunc, when set, replaces Secret and is read per request so the
+	// secret can be rotated without rebuilding the Verifier.
+	SecretFunc      func() string
+	Keys            *Keyring
 	MaxSkew         time.Duration
 	Now             func() time.Time
 	BodyCopy        bool
 	SignatureHeader string
 	TimestampHeader string
+	KeyIDHeader     string
+	NonceHeader     string
+	SignedHeaders   []string
+	DisableV1       bool
+	Replay          ReplayCache
+	// Resolver supplies secrets for key IDs not found in Keys, e.g. per-client credentials.
+	Resolver KeyResolver
+	// OnVerified is called with the key ID and scheme version of each accepted request.
+	OnVerified func(keyID, version string)
+	// Required refuses every request when there is no secret, key or resolver
+	// to check against. Without it an unconfigured Verifier lets requests
+	// through unsigned, which is only meant for local development.
+	Required bool
 }
 
 func (v *Verifier) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := v.verify(r); err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		res, err := v.verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), statusFor(err))
+			return
+		}
+		if res.keyID != "" {
+			if v.OnVerified != nil {
+				v.OnVerified(res.keyID, res.version)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), keyIDContextKey{}, verifiedKey{id: res.keyID, resolved: res.resolved}))
+		}
+		if res.claim == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
-		next.ServeHTTP(w, r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status >= http.StatusInternalServerError {
+			// The claim expires on its own if this fails.
+			_ = v.Replay.Unclaim(context.WithoutCancel(r.Context()), res.claim)
+		}
 	})
 }
 
-func (v *Verifier) verify(r *http.Request) error {
-	if v.Secret == "" {
-		return nil
+type verifyResult struct {
+	keyID    string
+	version  string
+	resolved bool   // the key came from Resolver
+	claim    string // replay cache key, when the signature was claimed
+}
+
+// statusWriter records the status the handler sent.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// KeyResolver looks up a signing key by ID. It returns ErrUnknownKey when the ID does not exist.
+type KeyResolver interface {
+	ResolveKey(ctx context.Context, id string) (Key, error)
+}
+
+type keyIDContextKey struct{}
+
+type verifiedKey struct {
+	id       string
+	resolved bool
+}
+
+// KeyIDFromContext returns the ID of the key that verified the request.
+func KeyIDFromContext(ctx context.Context) (string, bool) {
+	k, ok := ctx.Value(keyIDContextKey{}).(verifiedKey)
+	return k.id, ok
+}
+
+// ResolvedKeyIDFromContext returns the ID of the key that verified the
+// request only if the Resolver supplied it. Keyring and legacy key IDs share
+// the header with resolver IDs, so only this tells a per-client key apart.
+func ResolvedKeyIDFromContext(ctx context.Context) (string, bool) {
+	k, ok := ctx.Value(keyIDContextKey{}).(verifiedKey)
+	return k.id, ok && k.resolved
+}
+
+func (v *Verifier) secret() string {
+	if v.SecretFunc != nil {
+		return v.SecretFunc()
+	}
+	return v.Secret
+}
+
+func (v *Verifier) verify(r *http.Request) (verifyResult, error) {
+	if v.secret() == "" && v.Keys.Len() == 0 && v.Resolver == nil {
+		if v.Required {
+			return verifyResult{}, ErrNoKeys
+		}
+		return verifyResult{}, nil
 	}
 
 	sigHeader := v.SignatureHeader
@@ -57,17 +182,24 @@ func (v *Verifier) verify(r *http.Request) error {
 		tsHeaderName = defaultTimestampHeader
 	}
 
-	sig := r.Header.Get(sigHeader)
-	if sig == "" {
-		return ErrMissingSignature
+	rawSig := r.Header.Get(sigHeader)
+	if rawSig == "" {
+		return verifyResult{}, ErrMissingSignature
+	}
+	version, sig, err := parseSignature(rawSig)
+	if err != nil {
+		return verifyResult{}, err
+	}
+	if version == SignatureV1 && v.DisableV1 {
+		return verifyResult{}, ErrUnsupportedVersion
 	}
 	tsHeader := r.Header.Get(tsHeaderName)
 	if tsHeader == "" {
-		return ErrMissingTimestamp
+		return verifyResult{}, ErrMissingTimestamp
 	}
 	ts, err := strconv.ParseInt(tsHeader, 10, 64)
 	if err != nil {
-		return ErrMissingTimestamp
+		return verifyResult{}, ErrMissingTimestamp
 	}
 
 	now := time.Now()
@@ -77,21 +209,125 @@ func (v *Verifier) verify(r *http.Request) error {
 
 	reqTime := time.Unix(ts, 0)
 	if now.Sub(reqTime) > v.MaxSkew || reqTime.Sub(now) > v.MaxSkew {
-		return ErrStaleTimestamp
+		return verifyResult{}, ErrStaleTimestamp
+	}
+
+	candidates, resolved, err := v.candidateKeys(r, now)
+	if err != nil {
+		return verifyResult{}, err
 	}
 
 	bodyBytes, err := readBody(r)
 	if err != nil {
-		return err
+		return verifyResult{}, err
 	}
 
-	expected := computeSignature(v.Secret, tsHeader, bodyBytes)
-	if !hmac.Equal([]byte(expected), []byte(sig)) {
-		return ErrInvalidSignature
+	var canonical, nonce string
+	if version == SignatureV2 {
+		signed := v.SignedHeaders
+		if signed == nil {
+			signed = defaultSignedHeaders
+		}
+		nonce = r.Header.Get(orDefault(v.NonceHeader, defaultNonceHeader))
+		canonical = CanonicalRequest(r, tsHeader, nonce, signed, bodyBytes)
+	}
+
+	for _, key := range candidates {
+		var expected string
+		if version == SignatureV2 {
+			expected = computeSignatureV2(key.Secret, canonical)
+		} else {
+			expected = computeSignature(key.Secret, tsHeader, bodyBytes)
+		}
+		if hmac.Equal([]byte(expected), []byte(sig)) {
+			res := verifyResult{keyID: key.ID, version: version, resolved: resolved}
+			// Only a nonce makes each attempt's signature unique; without
+			// one an honest retry is byte-for-byte the same request.
+			if v.Replay != nil && version == SignatureV2 && nonce != "" {
+				res.claim = "sig:" + sig
+				if err := v.claim(r.Context(), res.claim); err != nil {
+					return verifyResult{}, err
+				}
+			}
+			return res, nil
+		}
+	}
+	return verifyResult{}, ErrInvalidSignature
+}
+
+// claim records an accepted signature. Timestamps are valid MaxSkew either
+// side of now, so the signature must be remembered for twice that long.
+func (v *Verifier) claim(ctx context.Context, key string) error {
+	fresh, err := v.Replay.Claim(ctx, key, 2*v.MaxSkew)
+	if err != nil {
+		return ErrReplayCache
+	}
+	if !fresh {
+		return ErrReplayedRequest
 	}
 	return nil
 }
 
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrReplayedRequest):
+		return http.StatusConflict
+	case errors.Is(err, ErrReplayCache), errors.Is(err, ErrKeyLookup), errors.Is(err, ErrNoKeys):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		// The client did not finish sending the body within the server's
+		// read timeout; it was never authenticated, not rejected.
+		return http.StatusRequestTimeout
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// candidateKeys returns the keys to try and whether they came from Resolver.
+func (v *Verifier) candidateKeys(r *http.Request, now time.Time) ([]Key, bool, error) {
+	keyIDHeader := v.KeyIDHeader
+	if keyIDHeader == "" {
+		keyIDHeader = defaultKeyIDHeader
+	}
+
+	if id := strings.TrimSpace(r.Header.Get(keyIDHeader)); id != "" {
+		if v.Keys != nil {
+			if key, ok := v.Keys.Lookup(id); ok {
+				if !key.activeAt(now) {
+					return nil, false, ErrInactiveKey
+				}
+				return []Key{key}, false, nil
+			}
+		}
+		if secret := v.secret(); id == LegacyKeyID && secret != "" {
+			return []Key{{ID: LegacyKeyID, Secret: secret}}, false, nil
+		}
+		if v.Resolver != nil && id != LegacyKeyID {
+			key, err := v.Resolver.ResolveKey(r.Context(), id)
+			if errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrInactiveKey) {
+				return nil, false, err
+			}
+			if err != nil {
+				return nil, false, ErrKeyLookup
+			}
+			if !key.activeAt(now) {
+				return nil, false, ErrInactiveKey
+			}
+			return []Key{key}, true, nil
+		}
+		return nil, false, ErrUnknownKey
+	}
+
+	var keys []Key
+	if v.Keys != nil {
+		keys = v.Keys.Active(now)
+	}
+	if secret := v.secret(); secret != "" {
+		keys = append(keys, Key{ID: LegacyKeyID, Secret: secret})
+	}
+	return keys, false, nil
+}
+
 func computeSignature(secret, timestamp string, body []byte) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write([]byte(timestamp))
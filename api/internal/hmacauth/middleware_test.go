@@ -1,6 +1,7 @@
 package hmacauth
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -71,6 +72,20 @@ func TestMiddleware_RejectsInvalidSignature(t *testing.T) {
 	}
 }
 
+func TestMiddleware_RequiredWithoutKeysRefuses(t *testing.T) {
+	v := &Verifier{Required: true, Keys: NewKeyring(), MaxSkew: time.Minute}
+
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
+
 func TestMiddleware_CustomHeaders(t *testing.T) {
 	body := `{"foo":"bar"}`
 	now := time.Unix(1_700_000_100, 0)
@@ -104,3 +119,87 @@ func TestMiddleware_CustomHeaders(t *testing.T) {
 		t.Fatalf("expected 200, got %d", rec.Code)
 	}
 }
+
+func TestMiddleware_RejectsReplayedSignature(t *testing.T) {
+	body := `{"foo":"bar"}`
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	v := &Verifier{
+		Secret:  "secret",
+		MaxSkew: time.Minute,
+		Now:     func() time.Time { return now },
+		Replay:  NewMemoryReplayCache(),
+	}
+
+	nonced := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	signer := &Signer{Secret: "secret", Now: func() time.Time { return now }}
+	if err := signer.Sign(nonced, []byte(body)); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	v1 := http.Header{}
+	v1.Set(defaultSignatureHeader, computeSignature("secret", ts, []byte(body)))
+	v1.Set(defaultTimestampHeader, ts)
+
+	cases := []struct {
+		name    string
+		header  http.Header
+		handler []int // status the handler answers on each attempt
+		want    []int
+	}{
+		// Without a nonce a retry is the same request; idempotency handles it.
+		{"v1 is not claimed", v1, []int{200, 200}, []int{200, 200}},
+		// A 5xx frees the claim so the client can resend.
+		{"v2 nonce claimed once", nonced.Header, []int{503, 200, 200}, []int{503, 200, 409}},
+	}
+	for _, tc := range cases {
+		var got []int
+		for _, status := range tc.handler {
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+			req.Header = tc.header.Clone()
+			rec := httptest.NewRecorder()
+			v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			})).ServeHTTP(rec, req)
+			got = append(got, rec.Code)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMiddleware_V2SignatureCoversRequestLine(t *testing.T) {
+	body := []byte(`{"foo":"bar"}`)
+	now := time.Unix(1_700_000_000, 0)
+	signer := &Signer{Secret: "secret", Now: func() time.Time { return now }}
+	v := &Verifier{
+		Secret:  "secret",
+		MaxSkew: time.Minute,
+		Now:     func() time.Time { return now },
+		Replay:  NewMemoryReplayCache(),
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", strings.NewReader(string(body)))
+	req.Header.Set("X-Idempotency-Key", "key-1")
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := v.verify(req); err != nil {
+		t.Fatalf("expected signed request to verify: %v", err)
+	}
+
+	tampered := []func(*http.Request){
+		func(r *http.Request) { r.URL.Path = "/api/v1/callbacks/mpesa" },
+		func(r *http.Request) { r.Method = http.MethodPut },
+		func(r *http.Request) { r.Header.Set("X-Idempotency-Key", "key-2") },
+	}
+	for i, mutate := range tampered {
+		clone := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", strings.NewReader(string(body)))
+		clone.Header = req.Header.Clone()
+		mutate(clone)
+		if _, err := v.verify(clone); err != ErrInvalidSignature {
+			t.Fatalf("case %d: expected ErrInvalidSignature, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package hmacauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers signatures that have already been accepted.
+type ReplayCache interface {
+	// Claim records key for ttl and reports whether it was not already recorded.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Unclaim forgets key so the same request may be accepted again.
+	Unclaim(ctx context.Context, key string) error
+}
+
+const memorySweepEvery = 1024
+
+// MemoryReplayCache is a process-local ReplayCache. Use a shared cache such as
+// idempotency.PostgresStore or idempotency.RedisStore when running more than
+// one replica.
+type MemoryReplayCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	claims  int
+	now     func() time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryReplayCache) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.claims++
+	if m.claims%memorySweepEvery == 0 {
+		for k, exp := range m.entries {
+			if !now.Before(exp) {
+				delete(m.entries, k)
+			}
+		}
+	}
+
+	if exp, ok := m.entries[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.entries[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (m *MemoryReplayCache) Unclaim(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
@@ -0,0 +1,324 @@
+package idempotency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"fiatrails/internal/logging"
+)
+
+// defaultCompactMin is the log length below which FileStore never compacts.
+const defaultCompactMin = 1024
+
+// FileStore keeps records in an append-only log for single-node deployments.
+// Save and Sweep append one line per record and fsync before returning, so a
+// saved response survives a crash. NewFileStore replays the log; a final line
+// torn by a crash mid-write is cut off. Once dead entries outnumber live
+// records the log is rewritten to a temp file and renamed into place.
+//
+// Each line is "<crc32 hex> <json>\n". Files written by the old whole-map
+// JSON format are read and converted on open.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	data    map[string]Record
+	log     *os.File
+	size    int64 // bytes of the log known to be intact
+	entries int   // lines in the log, live or dead
+
+	compactMin int
+}
+
+// logEntry is one line of the log. A nil Record deletes Key.
+type logEntry struct {
+	Key    string  `json:"k"`
+	Record *Record `json:"r,omitempty"`
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{
+		path:       path,
+		data:       make(map[string]Record),
+		compactMin: defaultCompactMin,
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	legacy, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if legacy || f.needsCompaction() {
+		if err := f.compact(); err != nil {
+			_ = f.log.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// load replays the log into f.data and truncates a torn tail. It reports
+// whether the file was in the old whole-map JSON format.
+func (f *FileStore) load() (legacy bool, err error) {
+	blob, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if trimmed := bytes.TrimSpace(blob); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &f.data); err != nil {
+			return false, fmt.Errorf("idempotency store %s: %w", f.path, err)
+		}
+		return true, nil
+	}
+
+	off := 0
+	for off < len(blob) {
+		n := bytes.IndexByte(blob[off:], '\n')
+		if n < 0 {
+			break // last write never completed
+		}
+		e, ok := decodeEntry(blob[off : off+n])
+		if !ok {
+			if off+n+1 < len(blob) {
+				// Appends are sequential, so only the last line can be torn.
+				return false, fmt.Errorf("idempotency store %s: corrupt entry at byte %d", f.path, off)
+			}
+			break
+		}
+		if e.Record == nil {
+			delete(f.data, e.Key)
+		} else {
+			f.data[e.Key] = *e.Record
+		}
+		f.entries++
+		off += n + 1
+	}
+	if off < len(blob) {
+		if err := os.Truncate(f.path, int64(off)); err != nil {
+			return false, err
+		}
+	}
+	f.size = int64(off)
+	return false, nil
+}
+
+func (f *FileStore) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	_, statErr := os.Stat(f.path)
+	log, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	f.log = log
+	if errors.Is(statErr, os.ErrNotExist) {
+		return syncDir(filepath.Dir(f.path))
+	}
+	return nil
+}
+
+func (f *FileStore) Get(_ context.Context, key string) (*Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record, ok := f.data[key]
+	if !ok || time.Now().After(record.ExpiresAt) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (f *FileStore) Save(ctx context.Context, key string, record Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.append(logEntry{Key: key, Record: &record}); err != nil {
+		return err
+	}
+	f.data[key] = record
+	f.maybeCompact(ctx)
+	return nil
+}
+
+// Sweep appends one delete per expired record in a single write and fsync.
+func (f *FileStore) Sweep(ctx context.Context, now time.Time, limit int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var dels []logEntry
+	for key, rec := range f.data {
+		if len(dels) == limit {
+			break
+		}
+		if now.After(rec.ExpiresAt) {
+			dels = append(dels, logEntry{Key: key})
+		}
+	}
+	if len(dels) == 0 {
+		return 0, nil
+	}
+	if err := f.append(dels...); err != nil {
+		return 0, err
+	}
+	for _, e := range dels {
+		delete(f.data, e.Key)
+	}
+	f.maybeCompact(ctx)
+	return len(dels), nil
+}
+
+func (f *FileStore) Size(context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.data), nil
+}
+
+// Close releases the log file. The store must not be used afterwards.
+func (f *FileStore) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.log != nil {
+		_ = f.log.Close()
+		f.log = nil
+	}
+}
+
+// append writes entries and fsyncs. On failure the log is cut back to its
+// last intact length so the next append does not follow a torn line.
+func (f *FileStore) append(entries ...logEntry) error {
+	if f.log == nil {
+		return errors.New("idempotency store is closed")
+	}
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := encodeEntry(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+	}
+	if _, err := f.log.Write(buf.Bytes()); err != nil {
+		_ = f.log.Truncate(f.size)
+		return err
+	}
+	if err := f.log.Sync(); err != nil {
+		_ = f.log.Truncate(f.size)
+		return err
+	}
+	f.size += int64(buf.Len())
+	f.entries += len(entries)
+	return nil
+}
+
+func (f *FileStore) needsCompaction() bool {
+	return f.entries > f.compactMin && f.entries > 2*len(f.data)
+}
+
+// maybeCompact compacts when due. A failed compaction is logged, not
+// returned: the append before it is already durable.
+func (f *FileStore) maybeCompact(ctx context.Context) {
+	if !f.needsCompaction() {
+		return
+	}
+	if err := f.compact(); err != nil {
+		logging.FromContext(ctx).Warn("idempotency log compaction failed", "path", f.path, "error", err)
+	}
+}
+
+// compact writes the live records to a temp file, fsyncs it and renames it
+// over the log, so a crash leaves either the old log or the new one. The temp
+// file's handle becomes the log; until the rename succeeds the old handle
+// stays in place.
+func (f *FileStore) compact() error {
+	tmp := f.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(out)
+	var size int64
+	for key, rec := range f.data {
+		line, err := encodeEntry(logEntry{Key: key, Record: &rec})
+		if err != nil {
+			_ = out.Close()
+			return err
+		}
+		n, _ := w.Write(line)
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	// The old handle points at the replaced file.
+	if f.log != nil {
+		_ = f.log.Close()
+	}
+	f.log = out
+	f.size = size
+	f.entries = len(f.data)
+	return syncDir(filepath.Dir(f.path))
+}
+
+func encodeEntry(e logEntry) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(body)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(body))
+	line = append(line, body...)
+	return append(line, '\n'), nil
+}
+
+func decodeEntry(line []byte) (logEntry, bool) {
+	var e logEntry
+	if len(line) < 10 || line[8] != ' ' {
+		return e, false
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	body := line[9:]
+	if err != nil || uint32(sum) != crc32.ChecksumIEEE(body) {
+		return e, false
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Key == "" {
+		return e, false
+	}
+	return e, true
+}
+
+// syncDir makes a create or rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,180 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecord(body string) Record {
+	return Record{StatusCode: 201, Response: []byte(body), CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().Add(time.Hour).UTC()}
+}
+
+func openFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestFileStoreRecoversFromTornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "idem.log")
+
+	store := openFileStore(t, path)
+	for _, key := range []string{"a", "b"} {
+		if err := store.Save(ctx, key, testRecord(key)); err != nil {
+			t.Fatalf("save %s: %v", key, err)
+		}
+	}
+	store.Close()
+	intact, _ := os.ReadFile(path)
+
+	store = openFileStore(t, path)
+	if err := store.Save(ctx, "c", testRecord("c")); err != nil {
+		t.Fatalf("save c: %v", err)
+	}
+	store.Close()
+	full, _ := os.ReadFile(path)
+
+	// Crash at every point while "c" was being written: it may be lost, but
+	// the store must open with a and b and accept new writes after it.
+	for cut := len(intact); cut < len(full); cut++ {
+		t.Run(strconv.Itoa(cut-len(intact)), func(t *testing.T) {
+			torn := filepath.Join(dir, "torn-"+strconv.Itoa(cut)+".log")
+			if err := os.WriteFile(torn, full[:cut], 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			s := openFileStore(t, torn)
+			if n, _ := s.Size(ctx); n != 2 {
+				t.Fatalf("recovered %d records, want 2", n)
+			}
+			if rec, _ := s.Get(ctx, "c"); rec != nil {
+				t.Fatal("torn record was loaded")
+			}
+			if err := s.Save(ctx, "d", testRecord("d")); err != nil {
+				t.Fatalf("save after recovery: %v", err)
+			}
+			s.Close()
+
+			s = openFileStore(t, torn)
+			for _, key := range []string{"a", "b", "d"} {
+				if rec, _ := s.Get(ctx, key); rec == nil || string(rec.Response) != key {
+					t.Fatalf("record %s lost after recovery: %+v", key, rec)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreRefusesCorruptionBeforeTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idem.log")
+	store := openFileStore(t, path)
+	_ = store.Save(ctx, "a", testRecord("a"))
+	_ = store.Save(ctx, "b", testRecord("b"))
+	store.Close()
+
+	// Damage the first entry; dropping it silently could let a retried
+	// request mint twice.
+	blob, _ := os.ReadFile(path)
+	blob[12] ^= 0xff
+	if err := os.WriteFile(path, blob, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil || !strings.Contains(err.Error(), "corrupt entry at byte 0") {
+		t.Fatalf("expected corruption error, got %v", err)
+	}
+}
+
+func TestFileStoreCompacts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idem.log")
+	store := openFileStore(t, path)
+	store.compactMin = 8
+
+	for i := 0; i < 50; i++ {
+		if err := store.Save(ctx, "hot", testRecord("v"+strconv.Itoa(i))); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	_ = store.Save(ctx, "cold", testRecord("cold"))
+	if store.entries > 2*store.compactMin {
+		t.Fatalf("log holds %d entries for 2 keys; compaction did not run", store.entries)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("compaction left its temp file behind: %v", err)
+	}
+	store.Close()
+
+	reopened := openFileStore(t, path)
+	if rec, _ := reopened.Get(ctx, "hot"); rec == nil || string(rec.Response) != "v49" {
+		t.Fatalf("latest value lost by compaction: %+v", rec)
+	}
+	if rec, _ := reopened.Get(ctx, "cold"); rec == nil {
+		t.Fatal("cold record lost by compaction")
+	}
+}
+
+func TestFileStoreKeepsLogWhenCompactionFails(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idem.log")
+	store := openFileStore(t, path)
+	if err := store.Save(ctx, "a", testRecord("a")); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// A non-empty directory at the log path makes the rename fail.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "blocker"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := store.log
+	if err := store.compact(); err == nil {
+		t.Fatal("expected compaction to fail")
+	}
+	if store.log != old {
+		t.Fatal("failed compaction swapped the log handle")
+	}
+	if err := store.Save(ctx, "b", testRecord("b")); err != nil {
+		t.Fatalf("save after failed compaction: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("compaction left its temp file behind: %v", err)
+	}
+}
+
+func TestFileStoreConvertsLegacyFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idem.json")
+	legacy, _ := json.MarshalIndent(map[string]Record{"old": testRecord("old")}, "", "  ")
+	if err := os.WriteFile(path, legacy, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := openFileStore(t, path)
+	if rec, _ := store.Get(ctx, "old"); rec == nil || string(rec.Response) != "old" {
+		t.Fatalf("legacy record not loaded: %+v", rec)
+	}
+	_ = store.Save(ctx, "new", testRecord("new"))
+	store.Close()
+
+	if blob, _ := os.ReadFile(path); strings.HasPrefix(string(blob), "{") {
+		t.Fatal("legacy file was not rewritten as a log")
+	}
+	if n, _ := openFileStore(t, path).Size(ctx); n != 2 {
+		t.Fatalf("expected 2 records after conversion, got %d", n)
+	}
+}
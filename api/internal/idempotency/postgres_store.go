@@ -14,17 +14,8 @@ type PostgresStore struct {
 	pool *pgxpool.Pool
 }
 
-const createTableSQL = `
-CREATE TABLE IF NOT EXISTS idempotency_records (
-    key TEXT PRIMARY KEY,
-    status_code INT NOT NULL,
-    response BYTEA NOT NULL,
-    created_at TIMESTAMPTZ NOT NULL,
-    expires_at TIMESTAMPTZ NOT NULL
-);
-`
-
-// NewPostgresStore connects to Postgres using the DSN and ensures the table exists.
+// NewPostgresStore connects to Postgres using the DSN. The tables come from
+// package migrate, which must run before the store is used.
 func NewPostgresStore(ctx context.Context, dsn string) (*PostgresStore, error) {
 	if dsn == "" {
 		return nil, errors.New("postgres dsn is empty")
@@ -40,11 +31,6 @@ func NewPostgresStore(ctx context.Context, dsn string) (*PostgresStore, error) {
 		return nil, err
 	}
 
-	if _, err := pool.Exec(ctx, createTableSQL); err != nil {
-		pool.Close()
-		return nil, err
-	}
-
 	return &PostgresStore{pool: pool}, nil
 }
 
@@ -54,6 +40,11 @@ func (p *PostgresStore) Close() {
 	}
 }
 
+// Pool exposes the connection pool so other Postgres-backed components can share it.
+func (p *PostgresStore) Pool() *pgxpool.Pool {
+	return p.pool
+}
+
 func (p *PostgresStore) Ping(ctx context.Context) error {
 	return p.pool.Ping(ctx)
 }
@@ -74,7 +65,6 @@ WHERE key = $1
 	}
 
 	if time.Now().After(rec.ExpiresAt) {
-		go p.deleteKey(context.Background(), key)
 		return nil, nil
 	}
 	return &rec, nil
@@ -93,6 +83,58 @@ SET status_code = EXCLUDED.status_code,
 	return err
 }
 
-func (p *PostgresStore) deleteKey(ctx context.Context, key string) {
-	_, _ = p.pool.Exec(ctx, `DELETE FROM idempotency_records WHERE key = $1`, key)
+// Claim records key until ttl elapses, returning false if an unexpired claim
+// already exists. It lets the store back hmacauth replay protection across replicas.
+func (p *PostgresStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	rows, err := p.pool.Query(ctx, `
+INSERT INTO replay_claims (key, expires_at)
+VALUES ($1, now() + $2::interval)
+ON CONFLICT (key) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE replay_claims.expires_at <= now()
+RETURNING key
+`, key, ttl)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	claimed := rows.Next()
+	return claimed, rows.Err()
+}
+
+// Unclaim deletes a replay claim so a request that failed on our side can be
+// retried with the same signature.
+func (p *PostgresStore) Unclaim(ctx context.Context, key string) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM replay_claims WHERE key = $1`, key)
+	return err
+}
+
+// Sweep deletes expired records and then expired replay claims, at most limit
+// rows from each table. SKIP LOCKED lets replicas sweep at the same time
+// without waiting on each other's rows.
+func (p *PostgresStore) Sweep(ctx context.Context, now time.Time, limit int) (int, error) {
+	total := 0
+	for _, table := range []string{"idempotency_records", "replay_claims"} {
+		tag, err := p.pool.Exec(ctx, `
+DELETE FROM `+table+`
+WHERE key IN (
+    SELECT key FROM `+table+`
+    WHERE expires_at < $1
+    ORDER BY expires_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`, now, limit)
+		if err != nil {
+			return total, err
+		}
+		total += int(tag.RowsAffected())
+	}
+	return total, nil
+}
+
+func (p *PostgresStore) Size(ctx context.Context) (int, error) {
+	var n int
+	err := p.pool.QueryRow(ctx, `SELECT count(*) FROM idempotency_records`).Scan(&n)
+	return n, err
 }
@@ -5,22 +5,38 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"fiatrails/internal/migrate"
 )
 
-func TestPostgresStoreLifecycle(t *testing.T) {
+// newTestPostgresStore connects to POSTGRES_TEST_DSN and migrates it, or
+// skips the test.
+func newTestPostgresStore(ctx context.Context, t *testing.T) *PostgresStore {
+	t.Helper()
 	dsn := os.Getenv("POSTGRES_TEST_DSN")
 	if dsn == "" {
 		t.Skip("POSTGRES_TEST_DSN not set")
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
 	store, err := NewPostgresStore(ctx, dsn)
 	if err != nil {
 		t.Fatalf("new store: %v", err)
 	}
-	defer store.Close()
+	t.Cleanup(store.Close)
+
+	m, err := migrate.New(store.Pool())
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return store
+}
+
+func TestPostgresStoreLifecycle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := newTestPostgresStore(ctx, t)
 
 	key := "test-key"
 	rec := Record{
@@ -42,3 +58,26 @@ func TestPostgresStoreLifecycle(t *testing.T) {
 		t.Fatalf("unexpected record: %#v", got)
 	}
 }
+
+func TestPostgresStoreClaim(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := newTestPostgresStore(ctx, t)
+
+	key := "sig:" + time.Now().Format(time.RFC3339Nano)
+	first, err := store.Claim(ctx, key, time.Minute)
+	if err != nil || !first {
+		t.Fatalf("expected first claim to succeed, got %v %v", first, err)
+	}
+	second, err := store.Claim(ctx, key, time.Minute)
+	if err != nil || second {
+		t.Fatalf("expected duplicate claim to fail, got %v %v", second, err)
+	}
+
+	if err := store.Unclaim(ctx, key); err != nil {
+		t.Fatalf("unclaim: %v", err)
+	}
+	if ok, err := store.Claim(ctx, key, time.Minute); err != nil || !ok {
+		t.Fatalf("expected claim after unclaim to succeed, got %v %v", ok, err)
+	}
+}
@@ -0,0 +1,193 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pendingPrefix marks a reserved key; the rest of the value is the token of
+// the reservation. Records are JSON objects, so they never start with it.
+const pendingPrefix = "pending:"
+
+// setIfOwner replaces a reservation with the finished record, or deletes it
+// when ARGV[2] is empty, only while the key still holds the caller's token.
+var setIfOwner = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return 1
+`)
+
+// RedisStore keeps records and replay claims in Redis so replicas share them
+// without Postgres on the request path. Redis expires keys itself, so the
+// store needs no sweeper.
+//
+// Reserve writes a pending marker with SET NX PX before a request is
+// processed, and Save replaces only the marker this store wrote, so two
+// replicas given the same key cannot both process it.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+
+	mu     sync.Mutex
+	tokens map[string]string // live reservations made by this store
+}
+
+// NewRedisStore connects using a redis:// or rediss:// URL. prefix is put
+// in front of every key so one Redis can serve several deployments.
+func NewRedisStore(ctx context.Context, rawURL, prefix string) (*RedisStore, error) {
+	if rawURL == "" {
+		return nil, errors.New("redis url is empty")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return NewRedisStoreFromClient(client, prefix), nil
+}
+
+// NewRedisStoreFromClient wraps an existing client, e.g. a cluster client.
+func NewRedisStoreFromClient(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, tokens: make(map[string]string)}
+}
+
+func (r *RedisStore) Close() {
+	_ = r.client.Close()
+}
+
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (*Record, error) {
+	blob, err := r.client.Get(ctx, r.prefix+"idem:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(string(blob), pendingPrefix) {
+		return nil, nil // reserved, still being processed
+	}
+	var rec Record
+	if err := json.Unmarshal(blob, &rec); err != nil {
+		return nil, err
+	}
+	// Redis expiry has millisecond precision; do not serve a record past its time.
+	if time.Now().After(rec.ExpiresAt) {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// Reserve writes a pending marker for key with SET NX PX.
+func (r *RedisStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	token, err := newToken()
+	if err != nil {
+		return false, err
+	}
+	ok, err := r.client.SetNX(ctx, r.prefix+"idem:"+key, pendingPrefix+token, ttl).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	r.mu.Lock()
+	r.tokens[key] = token
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Release deletes key if it still holds this store's reservation.
+func (r *RedisStore) Release(ctx context.Context, key string) error {
+	token, ok := r.takeToken(key)
+	if !ok {
+		return nil
+	}
+	return r.dropReservation(ctx, key, token)
+}
+
+// Save stores record until ExpiresAt. If this store reserved key, the record
+// replaces that reservation and ErrReservationLost is returned when it is
+// gone; otherwise the record is written only if key is free. A record that
+// has already expired is not written.
+func (r *RedisStore) Save(ctx context.Context, key string, record Record) error {
+	token, reserved := r.takeToken(key)
+	ttl := time.Until(record.ExpiresAt)
+	if ttl < time.Millisecond {
+		if reserved {
+			return r.dropReservation(ctx, key, token)
+		}
+		return nil
+	}
+	blob, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return r.client.SetNX(ctx, r.prefix+"idem:"+key, blob, ttl).Err()
+	}
+	n, err := setIfOwner.Run(ctx, r.client, []string{r.prefix + "idem:" + key}, pendingPrefix+token, blob, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrReservationLost
+	}
+	return nil
+}
+
+func (r *RedisStore) dropReservation(ctx context.Context, key, token string) error {
+	return setIfOwner.Run(ctx, r.client, []string{r.prefix + "idem:" + key}, pendingPrefix+token, "", 0).Err()
+}
+
+func (r *RedisStore) takeToken(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[key]
+	delete(r.tokens, key)
+	return token, ok
+}
+
+func newToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Claim reserves key with SET NX PX, returning false while an earlier claim
+// is live. It lets the store back hmacauth replay protection across replicas.
+func (r *RedisStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return r.client.SetNX(ctx, r.prefix+"claim:"+key, 1, ttl).Result()
+}
+
+// Unclaim deletes a replay claim so a request that failed on our side can be
+// retried with the same signature.
+func (r *RedisStore) Unclaim(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+"claim:"+key).Err()
+}
@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(context.Background(), "redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(store.Close)
+	return store, mr
+}
+
+func TestRedisStoreLifecycle(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	ctx := context.Background()
+
+	if rec, err := store.Get(ctx, "missing"); rec != nil || err != nil {
+		t.Fatalf("expected miss, got %+v %v", rec, err)
+	}
+
+	rec := Record{StatusCode: 201, Response: []byte("payload"), CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().Add(time.Minute).UTC()}
+	if err := store.Save(ctx, "k", rec); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := store.Get(ctx, "k")
+	if err != nil || got == nil || got.StatusCode != 201 || string(got.Response) != "payload" {
+		t.Fatalf("unexpected record %+v %v", got, err)
+	}
+
+	// The key carries a native TTL matching ExpiresAt.
+	if ttl := mr.TTL("test:idem:k"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("TTL = %v, want (0, 1m]", ttl)
+	}
+	mr.FastForward(2 * time.Minute)
+	if got, _ := store.Get(ctx, "k"); got != nil {
+		t.Fatalf("record served after its TTL: %+v", got)
+	}
+
+	if err := store.Save(ctx, "stale", Record{ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("save expired: %v", err)
+	}
+	if mr.Exists("test:idem:stale") {
+		t.Fatal("an already expired record was written")
+	}
+}
+
+func TestRedisStoreClaim(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	ctx := context.Background()
+
+	first, err := store.Claim(ctx, "sig", time.Minute)
+	if err != nil || !first {
+		t.Fatalf("expected first claim to succeed, got %v %v", first, err)
+	}
+	second, err := store.Claim(ctx, "sig", time.Minute)
+	if err != nil || second {
+		t.Fatalf("expected duplicate claim to fail, got %v %v", second, err)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+	again, err := store.Claim(ctx, "sig", time.Minute)
+	if err != nil || !again {
+		t.Fatalf("expected claim after expiry to succeed, got %v %v", again, err)
+	}
+
+	if err := store.Unclaim(ctx, "sig"); err != nil {
+		t.Fatalf("unclaim: %v", err)
+	}
+	if ok, err := store.Claim(ctx, "sig", time.Minute); err != nil || !ok {
+		t.Fatalf("expected claim after unclaim to succeed, got %v %v", ok, err)
+	}
+}
+
+func TestRedisStoreReportsOutage(t *testing.T) {
+	store, mr := newTestRedisStore(t)
+	mr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := store.Ping(ctx); err == nil {
+		t.Fatal("expected ping to fail with Redis down")
+	}
+	// An unreachable store must not look like a miss, or a retried mint would run twice.
+	if _, err := store.Get(ctx, "k"); err == nil {
+		t.Fatal("expected Get to fail with Redis down")
+	}
+}
+
+func TestRedisStoreReservation(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	replica := func() *RedisStore {
+		s, err := NewRedisStore(ctx, "redis://"+mr.Addr(), "test:")
+		if err != nil {
+			t.Fatalf("new store: %v", err)
+		}
+		t.Cleanup(s.Close)
+		return s
+	}
+	a, b := replica(), replica()
+	rec := testRecord("a")
+
+	if ok, err := a.Reserve(ctx, "k", time.Minute); err != nil || !ok {
+		t.Fatalf("first reserve = %v %v", ok, err)
+	}
+	if ok, err := b.Reserve(ctx, "k", time.Minute); err != nil || ok {
+		t.Fatalf("a second replica reserved a held key: %v %v", ok, err)
+	}
+	if got, err := b.Get(ctx, "k"); got != nil || err != nil {
+		t.Fatalf("a reservation must read as a miss, got %+v %v", got, err)
+	}
+	// Only the owner's Save replaces the reservation.
+	if err := b.Save(ctx, "k", testRecord("b")); err != nil {
+		t.Fatalf("save without reservation: %v", err)
+	}
+	if err := a.Save(ctx, "k", rec); err != nil {
+		t.Fatalf("owner save: %v", err)
+	}
+	if got, _ := b.Get(ctx, "k"); got == nil || string(got.Response) != "a" {
+		t.Fatalf("expected the owner's record, got %+v", got)
+	}
+	if err := a.Release(ctx, "k"); err != nil || !mr.Exists("test:idem:k") {
+		t.Fatalf("release after save removed the record: %v", err)
+	}
+
+	// A released key can be reserved again.
+	if ok, _ := a.Reserve(ctx, "r", time.Minute); !ok {
+		t.Fatal("reserve failed")
+	}
+	if err := a.Release(ctx, "r"); err != nil || mr.Exists("test:idem:r") {
+		t.Fatalf("release left the reservation behind: %v", err)
+	}
+	if ok, _ := b.Reserve(ctx, "r", time.Minute); !ok {
+		t.Fatal("released key could not be reserved again")
+	}
+
+	// Once a reservation expires and another replica takes the key, the
+	// original owner's Save must not overwrite it.
+	if ok, _ := a.Reserve(ctx, "x", time.Second); !ok {
+		t.Fatal("reserve failed")
+	}
+	mr.FastForward(2 * time.Second)
+	if ok, _ := b.Reserve(ctx, "x", time.Minute); !ok {
+		t.Fatal("expired reservation was not released")
+	}
+	if err := a.Save(ctx, "x", rec); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("expected ErrReservationLost, got %v", err)
+	}
+	if err := b.Save(ctx, "x", testRecord("b")); err != nil {
+		t.Fatalf("new owner save: %v", err)
+	}
+	if got, _ := a.Get(ctx, "x"); got == nil || string(got.Response) != "b" {
+		t.Fatalf("expected the new owner's record, got %+v", got)
+	}
+}
@@ -2,10 +2,7 @@ package idempotency
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 )
@@ -24,6 +21,33 @@ type Store interface {
 	Save(ctx context.Context, key string, record Record) error
 }
 
+// Reserver is implemented by stores shared across replicas. A request
+// reserves its key before doing any work so a concurrent request with the
+// same key, possibly on another replica, is refused instead of run twice.
+type Reserver interface {
+	// Reserve marks key as in progress for at most ttl. It returns false if
+	// the key already holds a record or a live reservation.
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release drops a reservation that will not be saved so the request can
+	// be retried. It leaves a saved record, or another caller's
+	// reservation, alone.
+	Release(ctx context.Context, key string) error
+}
+
+// ErrReservationLost is returned by Save when the caller's reservation
+// expired and the key was taken by another request in the meantime.
+var ErrReservationLost = errors.New("idempotency reservation lost")
+
+// Sweeper is implemented by stores that delete expired records in bulk.
+// Get ignores expired records but leaves them for the sweeper.
+type Sweeper interface {
+	// Sweep deletes at most limit records that expired before now and
+	// returns how many it deleted.
+	Sweep(ctx context.Context, now time.Time, limit int) (int, error)
+	// Size counts stored records, expired or not.
+	Size(ctx context.Context) (int, error)
+}
+
 // MemoryStore is mostly for testing.
 type MemoryStore struct {
 	mu   sync.RWMutex
@@ -56,70 +80,29 @@ func (m *MemoryStore) Save(_ context.Context, key string, record Record) error {
 	return nil
 }
 
-// FileStore persists records to disk. Suitable for local dev; can be swapped with SQLite later.
-type FileStore struct {
-	path string
-	mu   sync.Mutex
-	data map[string]Record
-}
-
-func NewFileStore(path string) (*FileStore, error) {
-	fs := &FileStore{
-		path: path,
-		data: make(map[string]Record),
-	}
-	if err := fs.load(); err != nil {
-		return nil, err
-	}
-	return fs, nil
-}
-
-func (f *FileStore) load() error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	blob, err := os.ReadFile(f.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-	if len(blob) == 0 {
-		return nil
-	}
-	return json.Unmarshal(blob, &f.data)
+func (m *MemoryStore) Sweep(_ context.Context, now time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sweepMap(m.data, now, limit), nil
 }
 
-func (f *FileStore) persist() error {
-	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
-		return err
-	}
-	blob, err := json.MarshalIndent(f.data, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(f.path, blob, 0o600)
+func (m *MemoryStore) Size(context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data), nil
 }
 
-func (f *FileStore) Get(_ context.Context, key string) (*Record, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	record, ok := f.data[key]
-	if !ok {
-		return nil, nil
+// sweepMap deletes at most limit records in data that expired before now.
+func sweepMap(data map[string]Record, now time.Time, limit int) int {
+	n := 0
+	for key, rec := range data {
+		if n == limit {
+			break
+		}
+		if now.After(rec.ExpiresAt) {
+			delete(data, key)
+			n++
+		}
 	}
-	if time.Now().After(record.ExpiresAt) {
-		delete(f.data, key)
-		_ = f.persist()
-		return nil, nil
-	}
-	return &record, nil
-}
-
-func (f *FileStore) Save(_ context.Context, key string, record Record) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	f.data[key] = record
-	return f.persist()
+	return n
 }
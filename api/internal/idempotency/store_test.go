@@ -66,3 +66,34 @@ func TestFileStorePersists(t *testing.T) {
 		t.Fatalf("unexpected record: %+v", got)
 	}
 }
+
+func TestFileStoreSweepLeavesGetReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idem.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	_ = store.Save(ctx, "old", Record{StatusCode: 201, ExpiresAt: now.Add(-time.Minute)})
+	_ = store.Save(ctx, "new", Record{StatusCode: 201, ExpiresAt: now.Add(time.Hour)})
+
+	// An expired hit is a miss, but deleting it is left to the sweeper.
+	if rec, _ := store.Get(ctx, "old"); rec != nil {
+		t.Fatalf("expired record returned: %+v", rec)
+	}
+	if n, _ := store.Size(ctx); n != 2 {
+		t.Fatalf("size = %d before sweep, want 2", n)
+	}
+
+	if n, err := store.Sweep(ctx, now, 10); err != nil || n != 1 {
+		t.Fatalf("sweep = %d, %v; want 1", n, err)
+	}
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if n, _ := reopened.Size(ctx); n != 1 {
+		t.Fatalf("sweep not persisted: size %d", n)
+	}
+}
@@ -0,0 +1,198 @@
+// Package intents keeps the off-chain record of every mint intent: who asked
+// for it, what it is for, and how far it has got. Status changes go through
+// Transition so an intent can only move along the lifecycle in Allowed.
+package intents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is where an intent is in its lifecycle.
+type Status string
+
+const (
+	// StatusReceived: the API accepted the request and has not reached the chain yet.
+	StatusReceived Status = "received"
+	// StatusSubmitted: submitIntent was broadcast and the intent has an ID;
+	// the transaction may not be mined yet.
+	StatusSubmitted Status = "submitted"
+	// StatusConfirmed: the payment provider confirmed the payment.
+	StatusConfirmed Status = "confirmed"
+	// StatusExecuted: executeMint was mined. Terminal.
+	StatusExecuted Status = "executed"
+	// StatusRefunded: the intent was refunded on-chain. Terminal.
+	StatusRefunded Status = "refunded"
+	// StatusFailed: submission or execution failed for good; see LastError.
+	StatusFailed Status = "failed"
+)
+
+var (
+	// ErrNotFound is returned when no intent matches.
+	ErrNotFound = errors.New("intent not found")
+	// ErrDuplicate is returned by Create when the txRef is already recorded.
+	ErrDuplicate = errors.New("intent already exists for txRef")
+	// ErrInvalidTransition is returned when the current status does not
+	// allow the requested one.
+	ErrInvalidTransition = errors.New("invalid intent status transition")
+)
+
+// Intent is one mint request and its progress.
+type Intent struct {
+	TxRef          string    `json:"txRef"`
+	IntentID       string    `json:"intentId,omitempty"`
+	ClientID       string    `json:"clientId,omitempty"`
+	IdempotencyKey string    `json:"idempotencyKey,omitempty"`
+	UserAddress    string    `json:"userAddress"`
+	Amount         string    `json:"amount"`
+	CountryCode    string    `json:"countryCode"`
+	Status         Status    `json:"status"`
+	SubmitTxHash   string    `json:"submitTxHash,omitempty"`
+	ExecuteTxHash  string    `json:"executeTxHash,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	// ExecutedAt is set when the intent reaches StatusExecuted.
+	ExecutedAt *time.Time `json:"executedAt,omitempty"`
+}
+
+// Update carries what a transition learned. Empty hashes and IDs leave the
+// stored value alone; Error replaces LastError, so a successful step clears it.
+type Update struct {
+	IntentID      string
+	SubmitTxHash  string
+	ExecuteTxHash string
+	Error         string
+}
+
+// Allowed reports whether an intent may move to the given status. A failed
+// intent may be retried: from received when it never reached the chain, or
+// from confirmed when the payment arrived but execution failed.
+func (in Intent) Allowed(to Status) bool {
+	switch in.Status {
+	case StatusReceived:
+		return to == StatusSubmitted || to == StatusFailed
+	case StatusSubmitted:
+		return to == StatusConfirmed || to == StatusRefunded || to == StatusFailed
+	case StatusConfirmed:
+		return to == StatusExecuted || to == StatusRefunded || to == StatusFailed
+	case StatusFailed:
+		if in.IntentID == "" {
+			return to == StatusReceived
+		}
+		return to == StatusConfirmed || to == StatusRefunded
+	}
+	return false
+}
+
+// apply moves in to status to, or returns ErrInvalidTransition.
+func (in *Intent) apply(to Status, u Update, now time.Time) error {
+	if !in.Allowed(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, in.Status, to)
+	}
+	in.Status = to
+	if u.IntentID != "" {
+		in.IntentID = u.IntentID
+	}
+	if u.SubmitTxHash != "" {
+		in.SubmitTxHash = u.SubmitTxHash
+	}
+	if u.ExecuteTxHash != "" {
+		in.ExecuteTxHash = u.ExecuteTxHash
+	}
+	in.LastError = u.Error
+	in.UpdatedAt = now
+	if to == StatusExecuted {
+		in.ExecutedAt = &now
+	}
+	return nil
+}
+
+// Repository stores intents keyed by txRef, which the escrow contract also
+// treats as unique.
+type Repository interface {
+	// Create records a new intent in StatusReceived, or returns ErrDuplicate.
+	Create(ctx context.Context, in Intent) (Intent, error)
+	Get(ctx context.Context, txRef string) (Intent, error)
+	GetByIntentID(ctx context.Context, intentID string) (Intent, error)
+	// Transition moves the intent for txRef to status to, atomically with
+	// respect to other transitions of the same intent.
+	Transition(ctx context.Context, txRef string, to Status, u Update) (Intent, error)
+}
+
+// MemoryRepository keeps intents in process memory, for tests.
+type MemoryRepository struct {
+	mu   sync.Mutex
+	byTx map[string]*Intent
+	byID map[string]string
+	now  func() time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		byTx: make(map[string]*Intent),
+		byID: make(map[string]string),
+		now:  time.Now,
+	}
+}
+
+func (m *MemoryRepository) Create(_ context.Context, in Intent) (Intent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.byTx[in.TxRef]; exists {
+		return Intent{}, ErrDuplicate
+	}
+	now := m.now().UTC()
+	in.Status = StatusReceived
+	in.CreatedAt, in.UpdatedAt = now, now
+	m.byTx[in.TxRef] = &in
+	m.index(&in)
+	return in, nil
+}
+
+func (m *MemoryRepository) Get(_ context.Context, txRef string) (Intent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.byTx[txRef]
+	if !ok {
+		return Intent{}, ErrNotFound
+	}
+	return *in, nil
+}
+
+func (m *MemoryRepository) GetByIntentID(ctx context.Context, intentID string) (Intent, error) {
+	m.mu.Lock()
+	txRef, ok := m.byID[strings.ToLower(intentID)]
+	m.mu.Unlock()
+	if !ok {
+		return Intent{}, ErrNotFound
+	}
+	return m.Get(ctx, txRef)
+}
+
+func (m *MemoryRepository) Transition(_ context.Context, txRef string, to Status, u Update) (Intent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.byTx[txRef]
+	if !ok {
+		return Intent{}, ErrNotFound
+	}
+	next := *in
+	if err := next.apply(to, u, m.now().UTC()); err != nil {
+		return *in, err
+	}
+	*in = next
+	m.index(in)
+	return next, nil
+}
+
+// index must be called with m.mu held.
+func (m *MemoryRepository) index(in *Intent) {
+	if in.IntentID != "" {
+		m.byID[strings.ToLower(in.IntentID)] = in.TxRef
+	}
+}
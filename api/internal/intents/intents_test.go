@@ -0,0 +1,95 @@
+package intents
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAllowedTransitions(t *testing.T) {
+	all := []Status{StatusReceived, StatusSubmitted, StatusConfirmed, StatusExecuted, StatusRefunded, StatusFailed}
+	allowed := map[Status][]Status{
+		StatusReceived:  {StatusSubmitted, StatusFailed},
+		StatusSubmitted: {StatusConfirmed, StatusRefunded, StatusFailed},
+		StatusConfirmed: {StatusExecuted, StatusRefunded, StatusFailed},
+	}
+	// Failed depends on whether the intent reached the chain; checked below.
+	for _, from := range all[:len(all)-1] {
+		for _, to := range all {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := (Intent{Status: from, IntentID: "0x1"}).Allowed(to); got != want {
+				t.Errorf("%s -> %s: allowed = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	// A failed intent is retried from wherever it failed.
+	if !(Intent{Status: StatusFailed}).Allowed(StatusReceived) || (Intent{Status: StatusFailed}).Allowed(StatusConfirmed) {
+		t.Error("failed before submission should only go back to received")
+	}
+	if (Intent{Status: StatusFailed, IntentID: "0x1"}).Allowed(StatusReceived) || !(Intent{Status: StatusFailed, IntentID: "0x1"}).Allowed(StatusConfirmed) {
+		t.Error("failed after submission should only go back to confirmed")
+	}
+}
+
+func TestMemoryRepositoryLifecycle(t *testing.T) {
+	testRepositoryLifecycle(t, NewMemoryRepository(), "tx-lifecycle")
+}
+
+// testRepositoryLifecycle runs the same checks against any Repository.
+func testRepositoryLifecycle(t *testing.T, repo Repository, txRef string) {
+	t.Helper()
+	ctx := context.Background()
+	intentID := "0xABCDEF" + txRef
+
+	in, err := repo.Create(ctx, Intent{TxRef: txRef, ClientID: "acme", UserAddress: "0xaa", Amount: "100", CountryCode: "KES"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if in.Status != StatusReceived || in.CreatedAt.IsZero() {
+		t.Fatalf("unexpected created intent %+v", in)
+	}
+	if _, err := repo.Create(ctx, Intent{TxRef: txRef, UserAddress: "0xbb", Amount: "1", CountryCode: "KES"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+
+	if _, err := repo.Transition(ctx, txRef, StatusExecuted, Update{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("received -> executed should be refused, got %v", err)
+	}
+	if _, err := repo.Transition(ctx, txRef, StatusSubmitted, Update{IntentID: intentID, SubmitTxHash: "0xs"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := repo.Transition(ctx, txRef, StatusConfirmed, Update{}); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if _, err := repo.Transition(ctx, txRef, StatusFailed, Update{Error: "rpc down"}); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if _, err := repo.Transition(ctx, txRef, StatusConfirmed, Update{}); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+	done, err := repo.Transition(ctx, txRef, StatusExecuted, Update{ExecuteTxHash: "0xe"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if done.LastError != "" || done.ExecutedAt == nil || done.SubmitTxHash != "0xs" {
+		t.Fatalf("unexpected executed intent %+v", done)
+	}
+
+	got, err := repo.GetByIntentID(ctx, "0xabcdef"+txRef)
+	if err != nil {
+		t.Fatalf("get by intent id: %v", err)
+	}
+	if got.TxRef != txRef || got.Status != StatusExecuted || got.ExecuteTxHash != "0xe" || got.ClientID != "acme" {
+		t.Fatalf("unexpected stored intent %+v", got)
+	}
+	if _, err := repo.Transition(ctx, txRef, StatusRefunded, Update{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("executed is terminal, got %v", err)
+	}
+	if _, err := repo.Get(ctx, "missing-"+txRef); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
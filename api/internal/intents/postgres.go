@@ -0,0 +1,95 @@
+package intents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectIntentSQL = `
+SELECT tx_ref, COALESCE(intent_id, ''), client_id, idempotency_key, user_address, amount, country_code,
+       status, submit_tx_hash, execute_tx_hash, last_error, created_at, updated_at, executed_at
+FROM intents
+`
+
+// PostgresRepository stores intents in the intents table.
+type PostgresRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresRepository expects the schema from package migrate.
+func NewPostgresRepository(pool *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{pool: pool}
+}
+
+func (p *PostgresRepository) Create(ctx context.Context, in Intent) (Intent, error) {
+	in.Status = StatusReceived
+	err := p.pool.QueryRow(ctx, `
+INSERT INTO intents (tx_ref, intent_id, client_id, idempotency_key, user_address, amount, country_code, status)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8)
+ON CONFLICT (tx_ref) DO NOTHING
+RETURNING created_at, updated_at
+`, in.TxRef, in.IntentID, in.ClientID, in.IdempotencyKey, in.UserAddress, in.Amount, in.CountryCode, in.Status).
+		Scan(&in.CreatedAt, &in.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Intent{}, ErrDuplicate
+	}
+	if err != nil {
+		return Intent{}, err
+	}
+	return in, nil
+}
+
+func (p *PostgresRepository) Get(ctx context.Context, txRef string) (Intent, error) {
+	return scanIntent(p.pool.QueryRow(ctx, selectIntentSQL+"WHERE tx_ref = $1", txRef))
+}
+
+func (p *PostgresRepository) GetByIntentID(ctx context.Context, intentID string) (Intent, error) {
+	return scanIntent(p.pool.QueryRow(ctx, selectIntentSQL+"WHERE lower(intent_id) = $1", strings.ToLower(intentID)))
+}
+
+// Transition locks the row, checks the move in Go and writes it back, so two
+// concurrent transitions of one intent are applied one after the other.
+func (p *PostgresRepository) Transition(ctx context.Context, txRef string, to Status, u Update) (Intent, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return Intent{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	cur, err := scanIntent(tx.QueryRow(ctx, selectIntentSQL+"WHERE tx_ref = $1 FOR UPDATE", txRef))
+	if err != nil {
+		return Intent{}, err
+	}
+	next := cur
+	if err := next.apply(to, u, time.Now().UTC()); err != nil {
+		return cur, err
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE intents
+SET status = $2, intent_id = NULLIF($3, ''), submit_tx_hash = $4, execute_tx_hash = $5,
+    last_error = $6, updated_at = $7, executed_at = $8
+WHERE tx_ref = $1
+`, txRef, next.Status, next.IntentID, next.SubmitTxHash, next.ExecuteTxHash, next.LastError, next.UpdatedAt, next.ExecutedAt); err != nil {
+		return cur, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return cur, err
+	}
+	return next, nil
+}
+
+func scanIntent(row pgx.Row) (Intent, error) {
+	var in Intent
+	err := row.Scan(&in.TxRef, &in.IntentID, &in.ClientID, &in.IdempotencyKey, &in.UserAddress, &in.Amount,
+		&in.CountryCode, &in.Status, &in.SubmitTxHash, &in.ExecuteTxHash, &in.LastError,
+		&in.CreatedAt, &in.UpdatedAt, &in.ExecutedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Intent{}, ErrNotFound
+	}
+	return in, err
+}
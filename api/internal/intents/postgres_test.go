@@ -0,0 +1,38 @@
+package intents
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"fiatrails/internal/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestPostgresRepositoryLifecycle(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+
+	m, err := migrate.New(pool)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	testRepositoryLifecycle(t, NewPostgresRepository(pool), "tx-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+}
@@ -0,0 +1,95 @@
+// Package logging configures the service's structured logger and carries
+// request-scoped loggers through context.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Field names shared across packages so log queries stay consistent.
+const (
+	KeyRequestID = "request_id"
+	KeyIntentID  = "intent_id"
+	KeyTxRef     = "tx_ref"
+	KeyClientID  = "client_id"
+	KeyTxHash    = "tx_hash"
+	KeyTraceID   = "trace_id"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched as substrings of lowercased attribute keys.
+var sensitiveKeys = []string{"secret", "signature", "private_key", "privatekey", "password", "salt", "api_key", "authorization"}
+
+// New builds a logger writing JSON (or text when format is "text") at the given level.
+func New(w io.Writer, format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redact,
+	}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(h)
+}
+
+// NewFromEnv reads LOG_FORMAT and LOG_LEVEL.
+func NewFromEnv() *slog.Logger {
+	return New(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request logger, or slog.Default when none is attached.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With attaches fields to the logger in ctx for everything logged further down the call chain.
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// NewRequestID returns 128 random bits, hex encoded.
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
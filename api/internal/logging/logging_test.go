@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRedactsSecretAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "json", "info")
+
+	logger.Info("loaded", "hmac_secret", "s3cr3t", "X-Request-Signature", "abcd", "PrivateKey", "0xdead", KeyTxRef, "ref-1")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line: %v", err)
+	}
+	for _, k := range []string{"hmac_secret", "X-Request-Signature", "PrivateKey"} {
+		if line[k] != redacted {
+			t.Fatalf("%s = %v, want redacted", k, line[k])
+		}
+	}
+	if line[KeyTxRef] != "ref-1" {
+		t.Fatalf("tx_ref = %v, want ref-1", line[KeyTxRef])
+	}
+}
+
+func TestWithCarriesFieldsThroughContext(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext(context.Background(), New(&buf, "json", "debug"))
+	ctx = With(ctx, KeyRequestID, "req-1")
+	ctx = With(ctx, KeyIntentID, "0xabc")
+
+	FromContext(ctx).Debug("executing")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line: %v", err)
+	}
+	if line[KeyRequestID] != "req-1" || line[KeyIntentID] != "0xabc" {
+		t.Fatalf("missing context fields: %v", line)
+	}
+}
+
+func TestNewRequestIDIsRandomHex(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 || a == b {
+		t.Fatalf("unexpected request ids %q %q", a, b)
+	}
+}
@@ -0,0 +1,331 @@
+// Package migrate applies the versioned SQL migrations embedded in the binary
+// and records them in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"fiatrails/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockID keys the advisory lock held while migrating, so replicas starting
+// together apply each migration once.
+const lockID int64 = 0x66696174726169 // "fiatrai"
+
+const createMigrationsTableSQL = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`
+
+// Migration is one NNNN_name.up.sql / NNNN_name.down.sql pair.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is a migration and when it was applied. Unknown marks a version
+// recorded in the database that this binary does not ship, e.g. after a
+// rollback to an older release.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+func (s State) Applied() bool { return s.AppliedAt != nil }
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads migrations from the top level of fsys, ordered by version.
+// Every version needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: versions start at 1", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs a non-empty up and down file", mig.Version, mig.Name)
+		}
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Embedded returns the migrations compiled into the binary.
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Migrator applies migrations to one database.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// New returns a Migrator for the embedded migrations.
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	ms, err := Embedded()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: ms}, nil
+}
+
+// Status lists every known migration and any unknown applied version, in
+// version order. It does not take the lock or create schema_migrations.
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	applied, err := m.applied(ctx, m.pool)
+	if err != nil {
+		return nil, err
+	}
+	var out []State
+	for _, mig := range m.migrations {
+		st := State{Migration: mig}
+		if at, ok := applied[mig.Version]; ok {
+			st.AppliedAt = &at.appliedAt
+			delete(applied, mig.Version)
+		}
+		out = append(out, st)
+	}
+	for version, at := range applied {
+		out = append(out, State{Migration: Migration{Version: version, Name: at.name}, AppliedAt: &at.appliedAt, Unknown: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Pending returns the migrations Up would apply.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	states, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, st := range states {
+		if !st.Applied() {
+			out = append(out, st.Migration)
+		}
+	}
+	return out, nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, mig, mig.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`); err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("migration applied", "version", mig.Version, "name", mig.Name)
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns the ones it reverted. Unknown versions cannot be reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, v := range versions {
+			if len(done) == steps {
+				break
+			}
+			mig, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("migration %d (%s) is not known to this binary and cannot be reverted", v, applied[v].name)
+			}
+			if err := apply(ctx, conn, mig, mig.Down, `DELETE FROM schema_migrations WHERE version = $1 AND name = $2`); err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("migration reverted", "version", mig.Version, "name", mig.Name)
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn on one connection holding the migration advisory lock.
+// Session locks belong to a connection, so fn must not use the pool.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock even if ctx is done. Closing the connection also drops the lock.
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createMigrationsTableSQL); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply runs body and the bookkeeping statement in one transaction.
+func apply(ctx context.Context, conn *pgxpool.Conn, mig Migration, body, record string) error {
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, body); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, mig.Version, mig.Name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	name      string
+	appliedAt time.Time
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// applied reads schema_migrations; a database without the table has
+// nothing applied.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	out := make(map[int]appliedMigration)
+	if !exists {
+		return out, nil
+	}
+
+	rows, err := q.Query(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[int(version)] = a
+	}
+	return out, rows.Err()
+}
+
+// ErrPending is returned by Check when the database is behind the binary.
+var ErrPending = errors.New("schema migrations pending")
+
+// Check returns ErrPending, wrapped with the versions, if any migration has
+// not been applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	names := make([]string, len(pending))
+	for i, mig := range pending {
+		names[i] = fmt.Sprintf("%04d_%s", mig.Version, mig.Name)
+	}
+	return fmt.Errorf("%w: %v", ErrPending, names)
+}
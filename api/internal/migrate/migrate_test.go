@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ms, err := Embedded()
+	if err != nil {
+		t.Fatalf("load embedded: %v", err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d has version %d; versions must be contiguous from 1", i, m.Version)
+		}
+	}
+	// Tables the API used to create at boot must come from the first migration
+	// so existing databases are adopted rather than recreated.
+	for _, table := range []string{"idempotency_records", "replay_claims", "api_clients", "rate_limit_buckets"} {
+		if !strings.Contains(ms[0].Up, "CREATE TABLE IF NOT EXISTS "+table) {
+			t.Errorf("initial migration does not adopt %s", table)
+		}
+	}
+}
+
+func TestLoadRejectsBadMigrations(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	cases := map[string]struct {
+		fs   fstest.MapFS
+		want string
+	}{
+		"bad name": {
+			fs:   fstest.MapFS{"0001-init.up.sql": file("SELECT 1")},
+			want: "name must look like",
+		},
+		"missing down": {
+			fs:   fstest.MapFS{"0001_init.up.sql": file("SELECT 1")},
+			want: "needs a non-empty up and down",
+		},
+		"two names": {
+			fs:   fstest.MapFS{"0001_init.up.sql": file("SELECT 1"), "0001_other.down.sql": file("SELECT 1")},
+			want: "has two names",
+		},
+		"version zero": {
+			fs:   fstest.MapFS{"0000_init.up.sql": file("SELECT 1")},
+			want: "versions start at 1",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(tc.fs); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	ms, err := Load(fstest.MapFS{
+		"0010_b.up.sql":   {Data: []byte("SELECT 10")},
+		"0010_b.down.sql": {Data: []byte("SELECT -10")},
+		"0002_a.up.sql":   {Data: []byte("SELECT 2")},
+		"0002_a.down.sql": {Data: []byte("SELECT -2")},
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(ms) != 2 || ms[0].Version != 2 || ms[1].Version != 10 || ms[1].Down != "SELECT -10" {
+		t.Fatalf("unexpected migrations %+v", ms)
+	}
+}
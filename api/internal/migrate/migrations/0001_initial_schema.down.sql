@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS api_clients;
+DROP TABLE IF EXISTS replay_claims;
+DROP TABLE IF EXISTS idempotency_records;
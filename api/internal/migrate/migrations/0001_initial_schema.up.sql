@@ -0,0 +1,32 @@
+-- Tables the API created at boot before migrations existed. IF NOT EXISTS
+-- lets this migration adopt a database that already has them.
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    key TEXT PRIMARY KEY,
+    status_code INT NOT NULL,
+    response BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS replay_claims (
+    key TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_clients (
+    id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    allowed_endpoints TEXT[] NOT NULL DEFAULT '{}',
+    allowed_countries TEXT[] NOT NULL DEFAULT '{}',
+    rate_limit_per_minute INT NOT NULL DEFAULT 0,
+    rate_limit_burst INT NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS intents (
+    tx_ref TEXT PRIMARY KEY,
+    intent_id TEXT,
+    client_id TEXT NOT NULL DEFAULT '',
+    idempotency_key TEXT NOT NULL DEFAULT '',
+    user_address TEXT NOT NULL,
+    amount TEXT NOT NULL,
+    country_code TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('received', 'submitted', 'confirmed', 'executed', 'refunded', 'failed')),
+    submit_tx_hash TEXT NOT NULL DEFAULT '',
+    execute_tx_hash TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    executed_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS intents_intent_id_idx ON intents (lower(intent_id)) WHERE intent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS intents_status_updated_idx ON intents (status, updated_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS replay_claims_expires_at_idx;
+DROP INDEX IF EXISTS idempotency_records_expires_at_idx;
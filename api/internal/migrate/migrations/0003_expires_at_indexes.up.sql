@@ -0,0 +1,3 @@
+-- Expired rows are deleted by expires_at; without an index that is a full scan.
+CREATE INDEX idempotency_records_expires_at_idx ON idempotency_records (expires_at);
+CREATE INDEX replay_claims_expires_at_idx ON replay_claims (expires_at);
@@ -0,0 +1,5 @@
+-- full_at is when a bucket will have refilled; buckets past it are idle and
+-- are deleted by the sweeper. Existing rows count as full, which at most
+-- grants one extra burst per key after the upgrade.
+ALTER TABLE rate_limit_buckets ADD COLUMN full_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestMigratorUpDown(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+
+	m, err := New(pool)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+
+	// Replicas starting together: the lock lets exactly one apply each migration.
+	var wg sync.WaitGroup
+	applied := make([][]Migration, 3)
+	errs := make([]error, 3)
+	for i := range applied {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied[i], errs[i] = m.Up(ctx)
+		}()
+	}
+	wg.Wait()
+	seen := make(map[int]int)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("up %d: %v", i, err)
+		}
+		for _, mig := range applied[i] {
+			seen[mig.Version]++
+		}
+	}
+	for v, n := range seen {
+		if n > 1 {
+			t.Fatalf("migration %d applied %d times", v, n)
+		}
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+
+	last := m.migrations[len(m.migrations)-1]
+	reverted, err := m.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != last.Version {
+		t.Fatalf("down 1: %v %+v", err, reverted)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Version != last.Version {
+		t.Fatalf("pending after down: %v %+v", err, pending)
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != 1 {
+		t.Fatalf("re-apply: %v %+v", err, again)
+	}
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket refilled at PerMinute tokens per minute holding at most Burst tokens.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.PerMinute)
+}
+
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Decision is the outcome of taking one token.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes tokens from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Sweeper is implemented by limiters that keep buckets outside the process.
+// Sweep deletes at most limit buckets that had refilled by now; a missing
+// bucket behaves like a full one.
+type Sweeper interface {
+	Sweep(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// decide refills a bucket holding tokens last updated elapsed ago and tries to take one.
+func decide(tokens float64, elapsed time.Duration, limit Limit) (float64, Decision) {
+	burst := limit.burst()
+	rate := limit.ratePerSecond()
+	tokens = math.Min(burst, tokens+elapsed.Seconds()*rate)
+
+	d := Decision{Limit: int(burst)}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	d.Remaining = int(math.Floor(tokens))
+	d.Reset = secondsToDuration((burst - tokens) / rate)
+	return tokens, d
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+const memorySweepEvery = 4096
+
+// MemoryLimiter keeps buckets in process memory; limits are per replica.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
+	if !limit.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%memorySweepEvery == 0 {
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.burst(), updated: now}
+		m.buckets[key] = b
+	}
+
+	tokens, d := decide(b.tokens, now.Sub(b.updated), limit)
+	b.tokens = tokens
+	b.updated = now
+	b.full = now.Add(d.Reset)
+	return d, nil
+}
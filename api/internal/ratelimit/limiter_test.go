@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterBurstAndRefill(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		d, _ := l.Allow(ctx, "k", limit)
+		if !d.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+
+	d, _ := l.Allow(ctx, "k", limit)
+	if d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expected third request to be throttled, got %+v", d)
+	}
+	if d.RetryAfter != time.Second {
+		t.Fatalf("expected retry after 1s at 1 token/s, got %v", d.RetryAfter)
+	}
+
+	if other, _ := l.Allow(ctx, "other", limit); !other.Allowed {
+		t.Fatalf("buckets must be independent per key")
+	}
+
+	now = now.Add(time.Second)
+	if d, _ := l.Allow(ctx, "k", limit); !d.Allowed {
+		t.Fatalf("expected a token after refill, got %+v", d)
+	}
+}
+
+func TestDisabledLimitAlwaysAllows(t *testing.T) {
+	l := NewMemoryLimiter()
+	for i := 0; i < 10; i++ {
+		if d, _ := l.Allow(context.Background(), "k", Limit{}); !d.Allowed {
+			t.Fatalf("disabled limit should allow")
+		}
+	}
+}
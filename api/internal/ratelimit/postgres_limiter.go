@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresLimiter keeps buckets in the rate_limit_buckets table so limits hold
+// across replicas. Each decision is a single upsert; the row lock it takes is
+// held only for that statement.
+type PostgresLimiter struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresLimiter expects the schema from package migrate.
+func NewPostgresLimiter(pool *pgxpool.Pool) *PostgresLimiter {
+	return &PostgresLimiter{pool: pool}
+}
+
+// allowSQL refills the bucket and takes a token in one upsert. The update
+// only applies when a token is available, so a denied request leaves the row
+// alone and its state is read back from the statement's snapshot instead.
+// full_at is when the bucket will have refilled, after which Sweep may
+// delete it: a missing bucket behaves exactly like a full one.
+const allowSQL = `
+WITH taken AS (
+    INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at, full_at)
+    VALUES ($1, $2::float8 - 1, now(), now() + make_interval(secs => 1 / $3::float8))
+    ON CONFLICT (key) DO UPDATE SET
+        tokens = LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM now() - b.updated_at)::float8, 0) * $3::float8) - 1,
+        updated_at = now(),
+        full_at = now() + make_interval(secs =>
+            ($2::float8 - LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM now() - b.updated_at)::float8, 0) * $3::float8) + 1) / $3::float8)
+    WHERE LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM now() - b.updated_at)::float8, 0) * $3::float8) >= 1
+    RETURNING b.tokens
+)
+SELECT true, tokens, 0::float8 FROM taken
+UNION ALL
+SELECT false, tokens, GREATEST(EXTRACT(EPOCH FROM now() - updated_at)::float8, 0)
+FROM rate_limit_buckets
+WHERE key = $1 AND NOT EXISTS (SELECT 1 FROM taken)
+`
+
+func (p *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if !limit.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	rows, err := p.pool.Query(ctx, allowSQL, key, limit.burst(), limit.ratePerSecond())
+	if err != nil {
+		return Decision{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Decision{}, err
+		}
+		// Denied by a bucket created after this statement's snapshot was
+		// taken, so it is not visible here; it cannot have refilled yet.
+		_, d := decide(0, 0, limit)
+		return d, nil
+	}
+	var allowed bool
+	var tokens, elapsed float64
+	if err := rows.Scan(&allowed, &tokens, &elapsed); err != nil {
+		return Decision{}, err
+	}
+	if allowed {
+		// tokens is what is left after the take; replaying the take on the
+		// refilled count yields the same decision.
+		_, d := decide(tokens+1, 0, limit)
+		return d, rows.Err()
+	}
+	_, d := decide(tokens, time.Duration(elapsed*float64(time.Second)), limit)
+	return d, rows.Err()
+}
+
+// Sweep deletes at most limit buckets that had refilled by now.
+func (p *PostgresLimiter) Sweep(ctx context.Context, now time.Time, limit int) (int, error) {
+	tag, err := p.pool.Exec(ctx, `
+DELETE FROM rate_limit_buckets
+WHERE key IN (
+    SELECT key FROM rate_limit_buckets
+    WHERE full_at < $1
+    ORDER BY full_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+`, now, limit)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
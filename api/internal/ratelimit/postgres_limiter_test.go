@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"fiatrails/internal/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestPostgresLimiterBurstAndSweep(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+
+	m, err := migrate.New(pool)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	l := NewPostgresLimiter(pool)
+	key := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	limit := Limit{PerMinute: 60, Burst: 2}
+
+	for i, want := range []bool{true, true, false} {
+		d, err := l.Allow(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("allow %d: %v", i, err)
+		}
+		if d.Allowed != want {
+			t.Fatalf("request %d allowed=%v, want %v (%+v)", i, d.Allowed, want, d)
+		}
+		if !d.Allowed && (d.Remaining != 0 || d.RetryAfter <= 0 || d.RetryAfter > time.Second) {
+			t.Fatalf("unexpected denial %+v", d)
+		}
+	}
+
+	// The bucket is not full for another two seconds, so it stays.
+	if _, err := l.Sweep(ctx, time.Now(), 100); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	var n int
+	_ = pool.QueryRow(ctx, `SELECT count(*) FROM rate_limit_buckets WHERE key = $1`, key).Scan(&n)
+	if n != 1 {
+		t.Fatalf("a draining bucket was swept")
+	}
+	if _, err := l.Sweep(ctx, time.Now().Add(time.Minute), 100); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	_ = pool.QueryRow(ctx, `SELECT count(*) FROM rate_limit_buckets WHERE key = $1`, key).Scan(&n)
+	if n != 0 {
+		t.Fatalf("an idle bucket was kept")
+	}
+}
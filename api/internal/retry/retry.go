@@ -0,0 +1,333 @@
+// Package retry runs operations with jittered exponential backoff, per-error
+// class policies and a shared retry budget.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jitter selects how a computed backoff is randomised.
+type Jitter int
+
+const (
+	// JitterNone sleeps exactly the exponential backoff.
+	JitterNone Jitter = iota
+	// JitterFull sleeps a uniform random duration in [0, backoff].
+	JitterFull
+	// JitterDecorrelated sleeps a random duration in [initial, previous*3],
+	// capped at the maximum backoff.
+	JitterDecorrelated
+)
+
+// ParseJitter maps "none", "full" or "decorrelated" to a Jitter mode. An
+// empty string selects full jitter.
+func ParseJitter(s string) (Jitter, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "full":
+		return JitterFull, nil
+	case "none":
+		return JitterNone, nil
+	case "decorrelated":
+		return JitterDecorrelated, nil
+	}
+	return JitterNone, fmt.Errorf("unknown jitter mode %q", s)
+}
+
+func (j Jitter) String() string {
+	switch j {
+	case JitterFull:
+		return "full"
+	case JitterDecorrelated:
+		return "decorrelated"
+	default:
+		return "none"
+	}
+}
+
+// Policy describes how often and how far apart attempts are made.
+type Policy struct {
+	// MaxAttempts counts the first call; values below 1 mean one attempt.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier grows the backoff after each attempt; values below 1 keep it flat.
+	Multiplier float64
+	Jitter     Jitter
+}
+
+// Class groups errors that share a retry policy.
+type Class string
+
+const (
+	// Permanent errors are returned immediately.
+	Permanent Class = "permanent"
+	// Transient errors are retried under the default policy.
+	Transient Class = "transient"
+	// RateLimited errors are retried no sooner than the delay the server asked for.
+	RateLimited Class = "rate_limited"
+)
+
+// Outcome labels how a retried call finished, for metrics.
+type Outcome string
+
+const (
+	Succeeded       Outcome = "success"
+	Failed          Outcome = "failed"
+	BudgetExhausted Outcome = "budget_exhausted"
+	Cancelled       Outcome = "cancelled"
+)
+
+// ErrBudgetExhausted wraps the last error when the shared budget refused a retry.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// Retrier runs an operation until it succeeds, fails permanently or runs out
+// of attempts. The zero value makes a single attempt.
+type Retrier struct {
+	Policy Policy
+	// Policies overrides Policy for specific error classes.
+	Policies map[Class]Policy
+	// Classify sorts errors; nil treats every error as Transient, except
+	// those carrying a RetryAfter hint, which are RateLimited.
+	Classify func(error) Class
+	// Hint extracts a server-requested delay; nil uses RetryAfter.
+	Hint func(error) (time.Duration, bool)
+	// Budget, when set, caps retries across every caller sharing it.
+	Budget *Budget
+	// Sleep waits between attempts; nil uses a timer honouring ctx.
+	Sleep func(ctx context.Context, d time.Duration) error
+	// OnRetry is called before each backoff.
+	OnRetry func(attempt int, class Class, delay time.Duration, err error)
+	// OnDone is called once with how the call finished.
+	OnDone func(outcome Outcome, attempts int)
+
+	// Rand returns values in [0, 1); nil uses math/rand.
+	Rand func() float64
+}
+
+// Do calls fn, passing the 1-based attempt number, until it succeeds or the
+// policy for the error's class says to stop.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context, attempt int) error) error {
+	r.Budget.Request()
+
+	var prev time.Duration
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx, attempt)
+		if err == nil {
+			r.done(Succeeded, attempt)
+			return nil
+		}
+		if ctx.Err() != nil {
+			r.done(Cancelled, attempt)
+			return err
+		}
+
+		class := r.classify(err)
+		policy := r.policyFor(class)
+		if class == Permanent || attempt >= policy.MaxAttempts {
+			r.done(Failed, attempt)
+			return err
+		}
+		if !r.Budget.Withdraw() {
+			r.done(BudgetExhausted, attempt)
+			return fmt.Errorf("%w: %w", ErrBudgetExhausted, err)
+		}
+
+		delay := r.delay(policy, attempt, prev)
+		prev = delay
+		if hint, ok := r.hint(err); ok && hint > delay {
+			delay = hint
+		}
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, class, delay, err)
+		}
+		if err := r.sleep(ctx, delay); err != nil {
+			r.done(Cancelled, attempt)
+			return err
+		}
+	}
+}
+
+func (r *Retrier) done(outcome Outcome, attempts int) {
+	if r.OnDone != nil {
+		r.OnDone(outcome, attempts)
+	}
+}
+
+func (r *Retrier) classify(err error) Class {
+	if r.Classify != nil {
+		return r.Classify(err)
+	}
+	if _, ok := r.hint(err); ok {
+		return RateLimited
+	}
+	return Transient
+}
+
+func (r *Retrier) policyFor(class Class) Policy {
+	p := r.Policy
+	if override, ok := r.Policies[class]; ok {
+		p = override
+	}
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	return p
+}
+
+func (r *Retrier) hint(err error) (time.Duration, bool) {
+	if r.Hint != nil {
+		return r.Hint(err)
+	}
+	return RetryAfter(err)
+}
+
+func (r *Retrier) random() float64 {
+	if r.Rand != nil {
+		return r.Rand()
+	}
+	return rand.Float64()
+}
+
+// delay computes the wait after the given attempt. prev is the previous
+// delay, used by decorrelated jitter.
+func (r *Retrier) delay(p Policy, attempt int, prev time.Duration) time.Duration {
+	base := p.InitialBackoff
+	if base <= 0 {
+		return 0
+	}
+	ceiling := float64(p.MaxBackoff)
+	if p.MaxBackoff <= 0 {
+		ceiling = math.MaxInt64
+	}
+
+	if p.Jitter == JitterDecorrelated {
+		if prev < base {
+			prev = base
+		}
+		hi := math.Min(float64(prev)*3, ceiling)
+		lo := float64(base)
+		if hi <= lo {
+			return time.Duration(math.Min(lo, ceiling))
+		}
+		return time.Duration(lo + r.random()*(hi-lo))
+	}
+
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	backoff := math.Min(float64(base)*math.Pow(mult, float64(attempt-1)), ceiling)
+	if p.Jitter == JitterFull {
+		backoff = r.random() * backoff
+	}
+	return time.Duration(backoff)
+}
+
+func (r *Retrier) sleep(ctx context.Context, d time.Duration) error {
+	if r.Sleep != nil {
+		return r.Sleep(ctx, d)
+	}
+	return Sleep(ctx, d)
+}
+
+// Sleep waits for d or until ctx is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryAfter reports the delay requested by any error in err's chain that
+// implements RetryAfter() time.Duration.
+func RetryAfter(err error) (time.Duration, bool) {
+	var h interface{ RetryAfter() time.Duration }
+	if errors.As(err, &h) {
+		return h.RetryAfter(), true
+	}
+	return 0, false
+}
+
+// Budget limits retries to a ratio of requests so a failing dependency sees
+// at most (1+Ratio) times normal load instead of MaxAttempts times. It is a
+// token bucket: each request deposits Ratio tokens, each retry spends one.
+// A nil Budget allows every retry.
+type Budget struct {
+	mu     sync.Mutex
+	ratio  float64
+	max    float64
+	tokens float64
+}
+
+// NewBudget allows ratio retries per request with a burst of up to max
+// retries. The bucket starts full.
+func NewBudget(ratio float64, max int) *Budget {
+	if max < 1 {
+		max = 1
+	}
+	return &Budget{ratio: ratio, max: float64(max), tokens: float64(max)}
+}
+
+// Configure changes the ratio and burst, keeping the tokens already earned up
+// to the new burst.
+func (b *Budget) Configure(ratio float64, max int) {
+	if b == nil {
+		return
+	}
+	if max < 1 {
+		max = 1
+	}
+	b.mu.Lock()
+	b.ratio = ratio
+	b.max = float64(max)
+	b.tokens = math.Min(b.tokens, b.max)
+	b.mu.Unlock()
+}
+
+// Request records a first attempt.
+func (b *Budget) Request() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens = math.Min(b.tokens+b.ratio, b.max)
+	b.mu.Unlock()
+}
+
+// Withdraw reports whether a retry may proceed, spending a token if so.
+func (b *Budget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Available returns the number of retries that may currently proceed.
+func (b *Budget) Available() float64 {
+	if b == nil {
+		return math.Inf(1)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
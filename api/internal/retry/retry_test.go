@@ -0,0 +1,176 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type hintErr time.Duration
+
+func (h hintErr) Error() string             { return "slow down" }
+func (h hintErr) RetryAfter() time.Duration { return time.Duration(h) }
+
+func recordSleeps(r *Retrier) *[]time.Duration {
+	var sleeps []time.Duration
+	r.Sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return &sleeps
+}
+
+func TestFloatMultiplierWithoutJitter(t *testing.T) {
+	r := &Retrier{Policy: Policy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     1.5,
+		Jitter:         JitterNone,
+	}}
+	sleeps := recordSleeps(r)
+
+	calls := 0
+	err := r.Do(context.Background(), func(context.Context, int) error {
+		calls++
+		return errors.New("boom")
+	})
+	if err == nil || calls != 5 {
+		t.Fatalf("expected 5 failed calls, got %d (err %v)", calls, err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 225 * time.Millisecond, 300 * time.Millisecond}
+	if len(*sleeps) != len(want) {
+		t.Fatalf("sleeps = %v, want %v", *sleeps, want)
+	}
+	for i := range want {
+		if (*sleeps)[i] != want[i] {
+			t.Fatalf("sleeps = %v, want %v", *sleeps, want)
+		}
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	p := Policy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2}
+
+	p.Jitter = JitterFull
+	r := &Retrier{Rand: func() float64 { return 0.5 }}
+	if got := r.delay(p, 3, 0); got != 2*time.Second {
+		t.Fatalf("full jitter: got %v, want half of 4s", got)
+	}
+
+	p.Jitter = JitterDecorrelated
+	r.Rand = func() float64 { return 0.999999 }
+	prev := time.Duration(0)
+	for i := 1; i <= 10; i++ {
+		d := r.delay(p, i, prev)
+		if d < time.Second || d > 10*time.Second {
+			t.Fatalf("decorrelated delay %v outside [1s, 10s]", d)
+		}
+		prev = d
+	}
+	if prev < 9*time.Second {
+		t.Fatalf("decorrelated delay should grow towards the cap, got %v", prev)
+	}
+}
+
+func TestPermanentErrorsStopImmediately(t *testing.T) {
+	permanent := errors.New("reverted")
+	r := &Retrier{
+		Policy: Policy{MaxAttempts: 5},
+		Classify: func(err error) Class {
+			if errors.Is(err, permanent) {
+				return Permanent
+			}
+			return Transient
+		},
+	}
+	recordSleeps(r)
+
+	var outcome Outcome
+	r.OnDone = func(o Outcome, _ int) { outcome = o }
+	calls := 0
+	err := r.Do(context.Background(), func(context.Context, int) error {
+		calls++
+		return permanent
+	})
+	if !errors.Is(err, permanent) || calls != 1 || outcome != Failed {
+		t.Fatalf("calls=%d outcome=%s err=%v", calls, outcome, err)
+	}
+}
+
+func TestRateLimitHintAndClassPolicy(t *testing.T) {
+	r := &Retrier{
+		Policy: Policy{MaxAttempts: 1, InitialBackoff: 10 * time.Millisecond, Jitter: JitterNone},
+		Policies: map[Class]Policy{
+			RateLimited: {MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+		},
+	}
+	sleeps := recordSleeps(r)
+
+	err := r.Do(context.Background(), func(_ context.Context, attempt int) error {
+		if attempt < 3 {
+			return hintErr(2 * time.Second)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success on third attempt: %v", err)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] != 2*time.Second || (*sleeps)[1] != 2*time.Second {
+		t.Fatalf("expected two 2s waits honouring the hint, got %v", *sleeps)
+	}
+}
+
+func TestBudgetCapsRetries(t *testing.T) {
+	b := NewBudget(0.5, 2)
+	r := &Retrier{Policy: Policy{MaxAttempts: 10}, Budget: b}
+	recordSleeps(r)
+
+	calls := 0
+	err := r.Do(context.Background(), func(context.Context, int) error {
+		calls++
+		return errors.New("down")
+	})
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("expected budget exhaustion, got %v", err)
+	}
+	// The bucket starts full at 2 and the request deposit is capped, so two retries run.
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	// Two more requests deposit one retry's worth of tokens.
+	b.Request()
+	b.Request()
+	if !b.Withdraw() || b.Withdraw() {
+		t.Fatalf("expected exactly one retry after two requests, available %v", b.Available())
+	}
+}
+
+func TestCancelledContextStopsRetrying(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Retrier{Policy: Policy{MaxAttempts: 5, InitialBackoff: time.Hour}}
+
+	calls := 0
+	err := r.Do(ctx, func(context.Context, int) error {
+		calls++
+		cancel()
+		return errors.New("down")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("calls=%d err=%v", calls, err)
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	for in, want := range map[string]Jitter{"": JitterFull, "FULL": JitterFull, "none": JitterNone, "decorrelated": JitterDecorrelated} {
+		got, err := ParseJitter(in)
+		if err != nil || got != want {
+			t.Errorf("ParseJitter(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseJitter("random"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+// Backends names the implementations the server runs on. They are logged at
+// startup and exported as fiatrails_backend_info so a fake or file-backed
+// deploy is visible on the dashboard, not just in the code path taken.
+type Backends struct {
+	Mode        string
+	Chain       string
+	Idempotency string
+	RateLimit   string
+	Clients     string
+}
+
+// WithBackends records the active backends.
+func WithBackends(b Backends) Option {
+	return func(s *Server) {
+		s.backends = &b
+	}
+}
+
+// logBanner writes one startup line listing the mode, backends and the chain
+// the server is pointed at.
+func (s *Server) logBanner() {
+	cfg := s.cfg()
+	attrs := []any{
+		"addr", s.httpServer.Addr,
+		"chain_id", cfg.Deployment.ChainID,
+		"mint_escrow", cfg.Deployment.Contracts.MintEscrow,
+	}
+	if b := s.backends; b != nil {
+		attrs = append(attrs,
+			"mode", b.Mode,
+			"chain_backend", b.Chain,
+			"idempotency_backend", b.Idempotency,
+			"ratelimit_backend", b.RateLimit,
+			"clients_backend", b.Clients,
+		)
+	}
+	s.logger.Info("starting FiatRails API", attrs...)
+}
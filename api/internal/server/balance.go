@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"fiatrails/internal/config"
+	"fiatrails/internal/escrow"
+)
+
+const defaultBalanceCheckInterval = time.Minute
+
+type accountBalanceInfo struct {
+	Address      string `json:"address"`
+	BalanceWei   string `json:"balance_wei"`
+	RemainingTxs int64  `json:"remaining_txs"`
+	Low          bool   `json:"low"`
+}
+
+type balanceSnapshot struct {
+	Accounts  []accountBalanceInfo `json:"accounts"`
+	CheckedAt time.Time            `json:"checked_at"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// balanceMonitor polls signing account balances so the service can warn
+// before the executor runs out of gas.
+type balanceMonitor struct {
+	checker  escrow.BalanceChecker
+	metrics  *metricsRegistry
+	logger   *slog.Logger
+	interval time.Duration
+	minWei   *big.Int
+	gasPerTx uint64
+
+	mu   sync.RWMutex
+	last *balanceSnapshot
+}
+
+func newBalanceMonitor(cfg config.BalanceConfig, checker escrow.BalanceChecker, metrics *metricsRegistry, logger *slog.Logger) *balanceMonitor {
+	interval := cfg.CheckInterval
+	if interval <= 0 {
+		interval = defaultBalanceCheckInterval
+	}
+	return &balanceMonitor{
+		checker:  checker,
+		metrics:  metrics,
+		logger:   logger,
+		interval: interval,
+		minWei:   cfg.MinBalanceWei,
+		gasPerTx: cfg.GasPerTx,
+	}
+}
+
+func (b *balanceMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		b.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *balanceMonitor) check(ctx context.Context) balanceSnapshot {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	snap := balanceSnapshot{CheckedAt: time.Now().UTC()}
+	balances, err := b.checker.Balances(ctx)
+	if err != nil {
+		b.logger.Error("executor balance check failed", "error", err)
+		snap.Error = err.Error()
+		// Keep the last known balances so a transient RPC error does not hide a low balance.
+		if prev := b.snapshot(); prev != nil {
+			snap.Accounts = prev.Accounts
+		}
+	}
+
+	for _, acct := range balances {
+		info := accountBalanceInfo{
+			Address:      acct.Address,
+			BalanceWei:   acct.Balance.String(),
+			RemainingTxs: b.remainingTxs(acct),
+			Low:          b.minWei != nil && acct.Balance.Cmp(b.minWei) < 0,
+		}
+		snap.Accounts = append(snap.Accounts, info)
+		if b.metrics != nil {
+			b.metrics.setExecutorBalance(acct.Address, acct.Balance, info.RemainingTxs)
+		}
+		if info.Low {
+			b.logger.Warn("executor balance below threshold",
+				"account", acct.Address,
+				"balance_wei", info.BalanceWei,
+				"min_balance_wei", b.minWei.String(),
+				"remaining_txs", info.RemainingTxs,
+			)
+		}
+	}
+
+	b.mu.Lock()
+	b.last = &snap
+	b.mu.Unlock()
+	return snap
+}
+
+// remainingTxs estimates how many transactions the balance covers at the current gas price.
+// It returns -1 when no estimate is possible.
+func (b *balanceMonitor) remainingTxs(acct escrow.AccountBalance) int64 {
+	if acct.Balance == nil || acct.GasPrice == nil || acct.GasPrice.Sign() <= 0 || b.gasPerTx == 0 {
+		return -1
+	}
+	costPerTx := new(big.Int).Mul(acct.GasPrice, new(big.Int).SetUint64(b.gasPerTx))
+	remaining := new(big.Int).Quo(acct.Balance, costPerTx)
+	if !remaining.IsInt64() {
+		return -1
+	}
+	return remaining.Int64()
+}
+
+func (b *balanceMonitor) snapshot() *balanceSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.last
+}
+
+// current returns the latest snapshot, checking synchronously if none exists yet.
+func (b *balanceMonitor) current(ctx context.Context) balanceSnapshot {
+	if snap := b.snapshot(); snap != nil {
+		return *snap
+	}
+	return b.check(ctx)
+}
+
+func (s balanceSnapshot) low() bool {
+	for _, acct := range s.Accounts {
+		if acct.Low {
+			return true
+		}
+	}
+	return false
+}
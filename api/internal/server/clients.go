@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fiatrails/internal/apiclients"
+	"fiatrails/internal/hmacauth"
+	"fiatrails/internal/logging"
+)
+
+// Option customises a Server built by NewServer.
+type Option func(*Server)
+
+// WithClientRegistry enables per-client credentials: requests signed with a
+// client's key ID are attributed to that client and scoped by its permissions.
+func WithClientRegistry(reg apiclients.Registry) Option {
+	return func(s *Server) {
+		s.clients = reg
+	}
+}
+
+// registryResolver exposes API client secrets to the HMAC verifier.
+type registryResolver struct {
+	reg apiclients.Registry
+}
+
+func (r registryResolver) ResolveKey(ctx context.Context, id string) (hmacauth.Key, error) {
+	c, err := r.reg.Get(ctx, id)
+	if errors.Is(err, apiclients.ErrNotFound) {
+		return hmacauth.Key{}, hmacauth.ErrUnknownKey
+	}
+	if err != nil {
+		return hmacauth.Key{}, err
+	}
+	if c.Disabled {
+		return hmacauth.Key{}, hmacauth.ErrInactiveKey
+	}
+	return hmacauth.Key{ID: c.ID, Secret: c.Secret}, nil
+}
+
+// withAPIClient attaches the registered client that signed the request and
+// enforces its endpoint scope. Only keys the registry supplied count: a
+// keyring or shared-secret signature is never attributed to a client that
+// happens to share its key ID. Such requests pass through without a client
+// unless client credentials are required.
+func (s *Server) withAPIClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.clients == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var client *apiclients.Client
+		if id, ok := hmacauth.ResolvedKeyIDFromContext(r.Context()); ok {
+			c, err := s.clients.Get(r.Context(), id)
+			switch {
+			case err == nil:
+				client = c
+			case !errors.Is(err, apiclients.ErrNotFound):
+				http.Error(w, "client lookup failed", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		if client == nil {
+			if s.cfg().Service.RequireClientCredentials {
+				http.Error(w, "client credentials required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Paths with IDs in them are granted by their route pattern.
+		if !client.AllowsEndpoint(r.URL.Path) && (r.Pattern == "" || !client.AllowsEndpoint(patternPath(r.Pattern))) {
+			http.Error(w, "endpoint not allowed for client", http.StatusForbidden)
+			return
+		}
+		ctx := logging.With(apiclients.NewContext(r.Context(), client), logging.KeyClientID, client.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// checkClientIDs refuses registry clients whose ID is also a signing key
+// ID: the verifier would pick the keyring or shared secret for them, so the
+// client's own secret could never be used.
+func (s *Server) checkClientIDs(ctx context.Context, keyrings ...*hmacauth.Keyring) error {
+	ids := []string{hmacauth.LegacyKeyID}
+	for _, keys := range keyrings {
+		ids = append(ids, keys.IDs()...)
+	}
+	var errs []error
+	for _, id := range ids {
+		if _, err := s.clients.Get(ctx, id); err == nil {
+			errs = append(errs, fmt.Errorf("api client %q shares its ID with a signing key", id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scopedKey namespaces an idempotency key by the calling client so two
+// partners using the same key never see each other's responses.
+func scopedKey(ctx context.Context, key string) string {
+	if c, ok := apiclients.FromContext(ctx); ok {
+		return "client:" + c.ID + ":" + key
+	}
+	return key
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"fiatrails/internal/apiclients"
+	"fiatrails/internal/intents"
+	"fiatrails/internal/logging"
+)
+
+// WithIntentRepository records every mint intent and its status changes, and
+// serves them from GET /api/v1/mint-intents/{intentId}.
+func WithIntentRepository(repo intents.Repository) Option {
+	return func(s *Server) {
+		s.intentRepo = repo
+	}
+}
+
+// intentStatusResponse is the MintIntentStatus schema in openapi.yaml.
+type intentStatusResponse struct {
+	IntentID     string     `json:"intentId"`
+	UserAddress  string     `json:"userAddress"`
+	Amount       string     `json:"amount"`
+	CountryCode  string     `json:"countryCode"`
+	TxRef        string     `json:"txRef"`
+	Status       string     `json:"status"`
+	SubmitTxHash string     `json:"submitTxHash,omitempty"`
+	TxHash       string     `json:"txHash,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	ExecutedAt   *time.Time `json:"executedAt,omitempty"`
+}
+
+// receiveIntent records a mint request before it reaches the chain. A txRef
+// already on record is refused with intents.ErrDuplicate, unless its earlier
+// submission failed before reaching the chain and this request repeats it.
+func (s *Server) receiveIntent(ctx context.Context, idempotencyKey string, req mintIntentRequest) error {
+	if s.intentRepo == nil {
+		return nil
+	}
+	in := intents.Intent{
+		TxRef:          req.TxRef,
+		IdempotencyKey: idempotencyKey,
+		UserAddress:    req.UserAddress,
+		Amount:         req.Amount,
+		CountryCode:    req.CountryCode,
+	}
+	if c, ok := apiclients.FromContext(ctx); ok {
+		in.ClientID = c.ID
+	}
+	_, err := s.intentRepo.Create(ctx, in)
+	if !errors.Is(err, intents.ErrDuplicate) {
+		return err
+	}
+
+	prev, gerr := s.intentRepo.Get(ctx, req.TxRef)
+	if gerr != nil {
+		return gerr
+	}
+	same := prev.ClientID == in.ClientID && prev.UserAddress == in.UserAddress &&
+		prev.Amount == in.Amount && prev.CountryCode == in.CountryCode
+	if !same || !prev.Allowed(intents.StatusReceived) {
+		return err
+	}
+	_, err = s.intentRepo.Transition(ctx, req.TxRef, intents.StatusReceived, intents.Update{})
+	return err
+}
+
+// markIntent moves the intent for txRef along. Failures are logged, not
+// returned: by now the chain has acted and the caller must get its answer.
+func (s *Server) markIntent(ctx context.Context, txRef string, to intents.Status, u intents.Update) {
+	if s.intentRepo == nil {
+		return
+	}
+	if _, err := s.intentRepo.Transition(ctx, txRef, to, u); err != nil {
+		logging.FromContext(ctx).Warn("intent status not recorded", "to", string(to), "error", err)
+	}
+}
+
+// markIntentByID is markIntent for callbacks, which name the intent by ID.
+// Intents created before the repository existed are skipped quietly.
+func (s *Server) markIntentByID(ctx context.Context, intentID string, to intents.Status, u intents.Update) {
+	if s.intentRepo == nil {
+		return
+	}
+	in, err := s.intentRepo.GetByIntentID(ctx, intentID)
+	if errors.Is(err, intents.ErrNotFound) {
+		logging.FromContext(ctx).Debug("callback for an intent with no record", "to", string(to))
+		return
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("intent lookup failed", "error", err)
+		return
+	}
+	// A resent callback finds its intent already confirmed.
+	if in.Status == to {
+		return
+	}
+	s.markIntent(ctx, in.TxRef, to, u)
+}
+
+func (s *Server) handleGetMintIntent(w http.ResponseWriter, r *http.Request) {
+	if s.intentRepo == nil {
+		http.Error(w, "intent status requires a database", http.StatusNotImplemented)
+		return
+	}
+	ctx := r.Context()
+	in, err := s.intentRepo.GetByIntentID(ctx, r.PathValue("intentId"))
+	if err != nil && !errors.Is(err, intents.ErrNotFound) {
+		logging.FromContext(ctx).Error("intent lookup failed", "error", err)
+		http.Error(w, "intent lookup failed", http.StatusServiceUnavailable)
+		return
+	}
+	// Partners only see their own intents, and shared-secret callers only
+	// intents no partner created.
+	var clientID string
+	if c, ok := apiclients.FromContext(ctx); ok {
+		clientID = c.ID
+	}
+	if err == nil && in.ClientID != clientID {
+		err = intents.ErrNotFound
+	}
+	if err != nil {
+		http.Error(w, "intent not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := json.Marshal(intentStatusResponse{
+		IntentID:     in.IntentID,
+		UserAddress:  in.UserAddress,
+		Amount:       in.Amount,
+		CountryCode:  in.CountryCode,
+		TxRef:        in.TxRef,
+		Status:       string(in.Status),
+		SubmitTxHash: in.SubmitTxHash,
+		TxHash:       in.ExecuteTxHash,
+		Error:        in.LastError,
+		CreatedAt:    in.CreatedAt,
+		UpdatedAt:    in.UpdatedAt,
+		ExecutedAt:   in.ExecutedAt,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
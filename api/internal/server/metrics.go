@@ -1,7 +1,14 @@
 package server
 
 import (
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fiatrails/internal/escrow"
+	"fiatrails/internal/retry"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -13,6 +20,19 @@ type metricsRegistry struct {
 	callbacksTotal     *prometheus.CounterVec
 	retryAttemptsTotal *prometheus.CounterVec
 	dlqDepth           prometheus.Gauge
+	executorBalance    *prometheus.GaugeVec
+	executorTxsLeft    *prometheus.GaugeVec
+	hmacKeyVerified    *prometheus.CounterVec
+	rateLimited        *prometheus.CounterVec
+	httpRequests       *prometheus.CounterVec
+	httpDuration       *prometheus.HistogramVec
+	retryQueueDepth    prometheus.Gauge
+	timeouts           *prometheus.CounterVec
+	configReloads      *prometheus.CounterVec
+	backendInfo        *prometheus.GaugeVec
+	idempotencySize    prometheus.Gauge
+	sweptTotal         prometheus.Counter
+	sweepDuration      *prometheus.HistogramVec
 }
 
 func newMetricsRegistry() *metricsRegistry {
@@ -28,16 +48,84 @@ func newMetricsRegistry() *metricsRegistry {
 
 	retries := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "fiatrails_retry_attempts_total",
-		Help: "Retry attempts for callback execution",
-	}, []string{"result"})
+		Help: "Chain calls by operation and outcome; result=retry counts each backoff",
+	}, []string{"operation", "result"})
 
 	dlq := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "fiatrails_dlq_depth",
 		Help: "Number of items in the DLQ",
 	})
 
+	balance := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fiatrails_executor_balance_wei",
+		Help: "Native balance of each signing account in wei",
+	}, []string{"account"})
+
+	txsLeft := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fiatrails_executor_remaining_txs",
+		Help: "Estimated transactions the signing account can still pay for at the current gas price",
+	}, []string{"account"})
+
+	hmacKeys := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fiatrails_hmac_key_verifications_total",
+		Help: "Requests verified per HMAC key ID and signature version",
+	}, []string{"verifier", "key_id", "version"})
+
+	throttled := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fiatrails_rate_limited_total",
+		Help: "Requests rejected with 429 by rate limit scope",
+	}, []string{"scope", "route"})
+
+	httpRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fiatrails_http_requests_total",
+		Help: "HTTP requests served by route, method and status code",
+	}, []string{"route", "method", "status"})
+
+	httpDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fiatrails_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status code",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"route", "method", "status"})
+
+	retryQueue := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fiatrails_retry_queue_depth",
+		Help: "Callbacks parked while the chain circuit is open",
+	})
+
+	timeouts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fiatrails_timeouts_total",
+		Help: "Requests cut short by a deadline, by kind: webhook processing or request_read",
+	}, []string{"kind"})
+
+	reloads := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fiatrails_config_reloads_total",
+		Help: "Configuration reloads by result: applied or rejected",
+	}, []string{"result"})
+
+	backends := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fiatrails_backend_info",
+		Help: "Always 1; labels name the run mode and the chain, idempotency, rate limit and API client backends in use",
+	}, []string{"mode", "chain", "idempotency", "ratelimit", "clients"})
+
+	idemSize := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fiatrails_idempotency_records",
+		Help: "Records in the idempotency store as of the last sweep, including expired ones not yet deleted",
+	})
+
+	swept := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fiatrails_idempotency_swept_total",
+		Help: "Expired idempotency records and replay claims deleted by the sweeper",
+	})
+
+	sweepDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fiatrails_idempotency_sweep_duration_seconds",
+		Help:    "Time taken by one sweep of expired idempotency records, by result: ok or error",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"result"})
+
 	r := prometheus.NewRegistry()
-	r.MustRegister(mint, callbacks, retries, dlq)
+	r.MustRegister(mint, callbacks, retries, dlq, balance, txsLeft, hmacKeys, throttled, httpRequests, httpDuration, retryQueue, timeouts, reloads, backends,
+		idemSize, swept, sweepDuration)
 
 	return &metricsRegistry{
 		registry:           r,
@@ -45,6 +133,19 @@ func newMetricsRegistry() *metricsRegistry {
 		callbacksTotal:     callbacks,
 		retryAttemptsTotal: retries,
 		dlqDepth:           dlq,
+		executorBalance:    balance,
+		executorTxsLeft:    txsLeft,
+		hmacKeyVerified:    hmacKeys,
+		rateLimited:        throttled,
+		httpRequests:       httpRequests,
+		httpDuration:       httpDuration,
+		retryQueueDepth:    retryQueue,
+		timeouts:           timeouts,
+		configReloads:      reloads,
+		backendInfo:        backends,
+		idempotencySize:    idemSize,
+		sweptTotal:         swept,
+		sweepDuration:      sweepDuration,
 	}
 }
 
@@ -60,10 +161,115 @@ func (m *metricsRegistry) incCallback(status string) {
 	m.callbacksTotal.WithLabelValues(status).Inc()
 }
 
-func (m *metricsRegistry) incRetry(result string) {
-	m.retryAttemptsTotal.WithLabelValues(result).Inc()
+func (m *metricsRegistry) incRetry(operation, result string) {
+	m.retryAttemptsTotal.WithLabelValues(operation, result).Inc()
+}
+
+func (m *metricsRegistry) incHMACKey(verifier, keyID, version string) {
+	m.hmacKeyVerified.WithLabelValues(verifier, keyID, version).Inc()
+}
+
+func (m *metricsRegistry) incRateLimited(scope, route string) {
+	m.rateLimited.WithLabelValues(scope, route).Inc()
+}
+
+func (m *metricsRegistry) incTimeout(kind string) {
+	m.timeouts.WithLabelValues(kind).Inc()
+}
+
+func (m *metricsRegistry) incConfigReload(result string) {
+	m.configReloads.WithLabelValues(result).Inc()
+}
+
+func (m *metricsRegistry) setBackends(b Backends) {
+	m.backendInfo.WithLabelValues(b.Mode, b.Chain, b.Idempotency, b.RateLimit, b.Clients).Set(1)
+}
+
+func (m *metricsRegistry) observeHTTP(route, method string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(route, method, code).Inc()
+	m.httpDuration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+func (m *metricsRegistry) setRetryQueueDepth(depth int) {
+	m.retryQueueDepth.Set(float64(depth))
+}
+
+func (m *metricsRegistry) observeSweep(result string, deleted int, elapsed time.Duration) {
+	m.sweptTotal.Add(float64(deleted))
+	m.sweepDuration.WithLabelValues(result).Observe(elapsed.Seconds())
+}
+
+func (m *metricsRegistry) setIdempotencySize(n int) {
+	m.idempotencySize.Set(float64(n))
+}
+
+// trackBreaker exports the circuit state, read at scrape time so the lazy
+// open to half-open transition is visible too.
+func (m *metricsRegistry) trackBreaker(b *escrow.Breaker) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "fiatrails_circuit_state",
+		Help: "Chain client circuit breaker state: 0 closed, 1 half-open, 2 open",
+	}, func() float64 {
+		return float64(b.State())
+	}))
+}
+
+// trackRetryBudget exports the retries the shared budget would still allow.
+func (m *metricsRegistry) trackRetryBudget(b *retry.Budget) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "fiatrails_retry_budget_available",
+		Help: "Retries the shared chain retry budget currently allows",
+	}, b.Available))
 }
 
 func (m *metricsRegistry) setDLQDepth(depth int) {
 	m.dlqDepth.Set(float64(depth))
 }
+
+func (m *metricsRegistry) setExecutorBalance(account string, wei *big.Int, remainingTxs int64) {
+	f, _ := new(big.Float).SetInt(wei).Float64()
+	m.executorBalance.WithLabelValues(account).Set(f)
+	m.executorTxsLeft.WithLabelValues(account).Set(float64(remainingTxs))
+}
+
+// routeOf returns the mux pattern that serves r, or "unmatched", so labels
+// and span names stay bounded however many distinct paths clients send.
+func routeOf(mux *http.ServeMux, r *http.Request) string {
+	if _, route := mux.Handler(r); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// patternPath drops the method from a mux pattern such as
+// "GET /api/v1/mint-intents/{intentId}".
+func patternPath(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
+
+// instrumentHTTP records request counts and latency labelled by the mux
+// pattern that served the request, so unknown paths collapse into one series.
+func (s *Server) instrumentHTTP(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeOf(mux, r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		mux.ServeHTTP(rec, r)
+		s.metrics.observeHTTP(route, r.Method, rec.status, time.Since(start))
+		if rec.status == http.StatusRequestTimeout {
+			s.metrics.incTimeout("request_read")
+		}
+	})
+}
+
+// WithCollectors registers extra collectors, such as escrow RPC metrics,
+// on the server's /metrics registry.
+func WithCollectors(cs ...prometheus.Collector) Option {
+	return func(s *Server) {
+		s.metrics.registry.MustRegister(cs...)
+	}
+}
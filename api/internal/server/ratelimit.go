@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fiatrails/internal/apiclients"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/ratelimit"
+)
+
+// WithRateLimiter replaces the in-memory limiter, e.g. with a Postgres-backed
+// one shared across replicas.
+func WithRateLimiter(l ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+type limitCheck struct {
+	scope string
+	key   string
+	limit ratelimit.Limit
+}
+
+// rateLimitIP runs before signature verification so unauthenticated floods
+// are rejected cheaply.
+func (s *Server) rateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl := s.cfg().RateLimit
+		limit := ratelimit.Limit{PerMinute: rl.IPPerMinute, Burst: rl.IPBurst}
+		check := limitCheck{scope: "ip", key: "ip:" + s.clientIP(r), limit: limit}
+		if !s.enforceLimits(w, r, check) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitCaller limits by registered API client and by the userAddress in
+// the body. It must run after HMAC verification and client resolution.
+func (s *Server) rateLimitCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var checks []limitCheck
+		rl := s.cfg().RateLimit
+
+		if c, ok := apiclients.FromContext(r.Context()); ok {
+			limit := ratelimit.Limit{PerMinute: rl.ClientPerMinute, Burst: rl.ClientBurst}
+			if c.RateLimit.RequestsPerMinute > 0 {
+				limit = ratelimit.Limit{PerMinute: c.RateLimit.RequestsPerMinute, Burst: c.RateLimit.Burst}
+			}
+			checks = append(checks, limitCheck{scope: "client", key: "client:" + c.ID, limit: limit})
+		}
+
+		if user := peekUserAddress(r); user != "" {
+			userLimit := ratelimit.Limit{PerMinute: rl.UserPerMinute, Burst: rl.UserBurst}
+			checks = append(checks, limitCheck{scope: "user", key: "user:" + user, limit: userLimit})
+		}
+
+		if !s.enforceLimits(w, r, checks...) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// enforceLimits takes a token from every bucket, sets RateLimit-* headers
+// from the most restrictive one and writes a 429 if any bucket is empty.
+// Limiter errors fail open so a database blip does not take the API down.
+func (s *Server) enforceLimits(w http.ResponseWriter, r *http.Request, checks ...limitCheck) bool {
+	var tightest *ratelimit.Decision
+	var deniedScope string
+
+	for _, check := range checks {
+		if !check.limit.Enabled() {
+			continue
+		}
+		d, err := s.limiter.Allow(r.Context(), check.key, check.limit)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("rate limiter failed, allowing request", "scope", check.scope, "error", err)
+			continue
+		}
+		if !d.Allowed && deniedScope == "" {
+			deniedScope = check.scope
+		}
+		if tightest == nil || (!d.Allowed && tightest.Allowed) || (d.Allowed == tightest.Allowed && d.Remaining < tightest.Remaining) {
+			d := d
+			tightest = &d
+		}
+	}
+
+	if tightest == nil {
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.Reset.Seconds())))
+
+	if deniedScope == "" {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(tightest.RetryAfter.Seconds())))
+	route := r.Pattern
+	if route == "" {
+		route = "unmatched"
+	}
+	s.metrics.incRateLimited(deniedScope, route)
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// clientIP returns the address IP limits key on. Entries left of those added
+// by the trusted proxies are whatever the client sent, so only the entry the
+// outermost trusted proxy appended is used. A header with fewer entries than
+// trusted proxies did not come through all of them and is ignored.
+func (s *Server) clientIP(r *http.Request) string {
+	if hops := s.cfg().RateLimit.TrustedProxies; hops > 0 {
+		var entries []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(v, ",")...)
+		}
+		if len(entries) >= hops {
+			if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-hops])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// peekUserAddress reads userAddress from a JSON body and restores the body for the handler.
+func peekUserAddress(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var payload struct {
+		UserAddress string `json:"userAddress"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.UserAddress))
+}
+
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
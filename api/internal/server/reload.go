@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fiatrails/internal/config"
+	"fiatrails/internal/hmacauth"
+)
+
+// cfg returns the current configuration. Handlers read it once per use so a
+// reload never mixes old and new values within one setting.
+func (s *Server) cfg() *config.AppConfig {
+	return s.config.Load()
+}
+
+// ApplyConfig swaps in the reloadable settings of next (mint limits, retry
+// policy, secrets and rate limits) and returns the changed sections that need
+// a restart. next must already be validated. Keyrings are re-read too; if one
+// cannot be loaded the reload is rejected and nothing changes.
+func (s *Server) ApplyConfig(next *config.AppConfig) ([]string, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	for _, v := range []*hmacauth.Verifier{s.hmac, s.mpesaHMAC} {
+		if !v.Required {
+			continue
+		}
+		if v.Keys == nil {
+			err := fmt.Errorf("keyring was never loaded; restart required")
+			s.RejectConfig(err)
+			return nil, err
+		}
+		if err := v.Keys.Reload(); err != nil {
+			s.RejectConfig(err)
+			return nil, err
+		}
+	}
+
+	merged, restart := s.cfg().ApplyReloadable(next)
+	s.config.Store(merged)
+	s.retryBudget.Configure(merged.Retry.BudgetRatio, merged.Retry.BudgetBurst)
+	s.metrics.incConfigReload("applied")
+	if len(restart) > 0 {
+		s.logger.Warn("config reloaded; some changes need a restart", "restart_required", restart)
+	} else {
+		s.logger.Info("config reloaded")
+	}
+	return restart, nil
+}
+
+// RejectConfig records a reload that failed to load or validate; the running
+// config stays in place.
+func (s *Server) RejectConfig(err error) {
+	s.metrics.incConfigReload("rejected")
+	s.logger.Error("config reload rejected, keeping current config", "error", err)
+}
+
+// handleAdminConfig shows the effective configuration with secrets masked,
+// along with the files and environment variables it came from.
+func (s *Server) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := s.cfg()
+	redacted := cfg.Redacted()
+	source := redacted["Source"]
+	delete(redacted, "Source")
+
+	body, _ := json.Marshal(map[string]any{
+		"source": source,
+		"config": redacted,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// requireAdmin checks the ADMIN_TOKEN bearer token. Admin endpoints answer
+// 404 when no token is configured so they are not discoverable.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := s.cfg().Service.AdminToken
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fiatrails/internal/escrow"
+	"fiatrails/internal/logging"
+)
+
+const defaultRetryQueueInterval = 15 * time.Second
+
+// retryEntry is a callback parked while the chain circuit was open.
+type retryEntry struct {
+	Timestamp time.Time            `json:"timestamp"`
+	Payload   mpesaCallbackRequest `json:"payload"`
+	Attempts  int                  `json:"attempts"`
+	LastError string               `json:"last_error"`
+}
+
+// retryQueueFile names entries after the txRef so a resent callback replaces
+// its queued copy instead of minting twice.
+func (s *Server) retryQueueFile(txRef string) string {
+	sum := sha256.Sum256([]byte(txRef))
+	return filepath.Join(s.cfg().Service.RetryQueuePath, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (s *Server) enqueueRetry(ctx context.Context, payload mpesaCallbackRequest, execErr error) error {
+	entry := retryEntry{
+		Timestamp: time.Now().UTC(),
+		Payload:   payload,
+		LastError: execErr.Error(),
+	}
+	if err := s.writeRetryEntry(entry); err != nil {
+		logging.FromContext(ctx).Error("retry queue write failed", "error", err)
+		return err
+	}
+	s.updateRetryQueueDepth()
+	return nil
+}
+
+func (s *Server) writeRetryEntry(entry retryEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.cfg().Service.RetryQueuePath, 0o755); err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated entry behind.
+	path := s.retryQueueFile(entry.Payload.TxRef)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *Server) runRetryQueue(ctx context.Context) {
+	interval := s.cfg().Service.RetryQueueInterval
+	if interval <= 0 {
+		interval = defaultRetryQueueInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.drainRetryQueue(ctx)
+		}
+	}
+}
+
+// drainRetryQueue makes one attempt per parked callback, oldest first. It stops
+// as soon as the circuit refuses a call so an outage costs one probe per pass.
+func (s *Server) drainRetryQueue(ctx context.Context) {
+	s.retryMu.Lock()
+	defer s.retryMu.Unlock()
+	defer s.updateRetryQueueDepth()
+
+	for _, q := range s.loadRetryQueue(ctx) {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.breaker != nil && s.breaker.State() == escrow.BreakerOpen {
+			return
+		}
+		if !s.processRetryEntry(ctx, q.path, q.entry) {
+			return
+		}
+	}
+}
+
+type queuedRetry struct {
+	path  string
+	entry retryEntry
+}
+
+// loadRetryQueue reads every parked callback sorted by the time it was
+// queued. File names are hashes of the txRef, so directory order says
+// nothing about age.
+func (s *Server) loadRetryQueue(ctx context.Context) []queuedRetry {
+	dir := s.cfg().Service.RetryQueuePath
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logging.FromContext(ctx).Error("retry queue read failed", "error", err)
+		}
+		return nil
+	}
+
+	var queue []queuedRetry
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			logging.FromContext(ctx).Error("retry queue entry read failed", "path", path, "error", err)
+			continue
+		}
+		var entry retryEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			logging.FromContext(ctx).Error("retry queue entry corrupt", "path", path, "error", err)
+			continue
+		}
+		queue = append(queue, queuedRetry{path: path, entry: entry})
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].entry.Timestamp.Before(queue[j].entry.Timestamp)
+	})
+	return queue
+}
+
+// processRetryEntry processes one parked callback and reports whether draining should continue.
+func (s *Server) processRetryEntry(ctx context.Context, path string, entry retryEntry) bool {
+	ctx = logging.With(ctx, logging.KeyIntentID, entry.Payload.IntentID, logging.KeyTxRef, entry.Payload.TxRef)
+	logger := logging.FromContext(ctx)
+
+	// The callback may have been resent and processed since it was parked.
+	if existing, _ := s.getRecord(ctx, mpesaKeyPrefix+entry.Payload.TxRef); existing != nil {
+		_ = os.Remove(path)
+		return true
+	}
+
+	// A resent callback may be in flight right now; leave the entry for the
+	// next drain, which finds its record.
+	release, reserved, err := s.reserve(ctx, mpesaKeyPrefix+entry.Payload.TxRef)
+	if err != nil {
+		logger.Error("idempotency reserve failed, stopping retry queue drain", "error", err)
+		return false
+	}
+	if !reserved {
+		return true
+	}
+	defer release()
+
+	// The attempt that parked it may have minted without a reply.
+	minted, err := s.mintedOnChain(ctx, entry.Payload.IntentID)
+	var resp escrow.ExecuteMintResponse
+	if err == nil && !minted {
+		resp, err = s.escrow.ExecuteMint(ctx, entry.Payload.IntentID)
+	}
+	switch {
+	case err == nil:
+		s.completeCallback(ctx, entry.Payload, resp.TxHash)
+		_ = os.Remove(path)
+		return true
+	case errors.Is(err, escrow.ErrCircuitOpen):
+		return false
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	if !isRetryable(err) || (s.cfg().Service.RetryQueueMaxAttempts > 0 && entry.Attempts >= s.cfg().Service.RetryQueueMaxAttempts) {
+		logger.Error("queued callback failed, writing to DLQ", "attempts", entry.Attempts, "error", err)
+		s.metrics.incCallback("failed")
+		s.writeDLQ(ctx, entry.Payload, err)
+		_ = os.Remove(path)
+		return true
+	}
+	logger.Warn("queued callback retry failed", "attempts", entry.Attempts, "error", err)
+	if werr := s.writeRetryEntry(entry); werr != nil {
+		logger.Error("retry queue write failed", "error", werr)
+	}
+	return true
+}
+
+func (s *Server) updateRetryQueueDepth() int {
+	depth := 0
+	if s.cfg().Service.RetryQueuePath != "" {
+		entries, err := os.ReadDir(s.cfg().Service.RetryQueuePath)
+		if err == nil {
+			for _, e := range entries {
+				if strings.HasSuffix(e.Name(), ".json") {
+					depth++
+				}
+			}
+		}
+	}
+	s.metrics.setRetryQueueDepth(depth)
+	return depth
+}
@@ -5,22 +5,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"fiatrails/internal/apiclients"
 	"fiatrails/internal/config"
 	"fiatrails/internal/escrow"
 	"fiatrails/internal/hmacauth"
 	"fiatrails/internal/idempotency"
+	"fiatrails/internal/intents"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/ratelimit"
+	"fiatrails/internal/retry"
+	"fiatrails/internal/tracing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
-	cfg         *config.AppConfig
+	config      atomic.Pointer[config.AppConfig]
 	escrow      escrow.Client
 	store       idempotency.Store
 	hmac        *hmacauth.Verifier
@@ -29,31 +42,79 @@ type Server struct {
 	metrics     *metricsRegistry
 	dbHealthFn  func(context.Context) error
 	rpcHealthFn func(context.Context) error
+	balances    *balanceMonitor
+	endpoints   escrow.EndpointMonitor
+	// intentFinder reconciles a SubmitIntent whose reply was lost.
+	intentFinder escrow.IntentFinder
+	breaker      *escrow.Breaker
+	retryBudget  *retry.Budget
+	retryMu      sync.Mutex
+	reloadMu     sync.Mutex
+	clients      apiclients.Registry
+	intentRepo   intents.Repository
+	limiter      ratelimit.Limiter
+	backends     *Backends
+	logger       *slog.Logger
+	bgCtx        context.Context
+	bgCancel     context.CancelFunc
+	startErr     error
 }
 
-func NewServer(cfg *config.AppConfig, esc escrow.Client, store idempotency.Store) *Server {
+func NewServer(cfg *config.AppConfig, esc escrow.Client, store idempotency.Store, opts ...Option) *Server {
+	metrics := newMetricsRegistry()
+
+	s := &Server{
+		escrow:  esc,
+		store:   store,
+		metrics: metrics,
+		limiter: ratelimit.NewMemoryLimiter(),
+		logger:  slog.Default(),
+	}
+	s.config.Store(cfg)
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.backends != nil {
+		metrics.setBackends(*s.backends)
+	}
+
+	// Seed secrets are read per request so a config reload rotates them.
 	hmacVerifier := &hmacauth.Verifier{
-		Secret:  cfg.Seed.Secrets.HMACSalt,
-		MaxSkew: cfg.Service.HMACClockSkew,
+		SecretFunc: func() string { return s.cfg().Seed.Secrets.HMACSalt },
+		MaxSkew:    cfg.Service.HMACClockSkew,
+		DisableV1:  cfg.Service.HMACDisableV1,
+		OnVerified: func(keyID, version string) {
+			metrics.incHMACKey("mint", keyID, version)
+		},
+	}
+	keyringErr := s.useKeyring(hmacVerifier, cfg.Service.HMACKeyringPath)
+	if s.clients != nil {
+		hmacVerifier.Resolver = registryResolver{reg: s.clients}
 	}
 
 	mpesaVerifier := &hmacauth.Verifier{
-		Secret:          cfg.Seed.Secrets.MpesaWebhookSecret,
+		SecretFunc:      func() string { return s.cfg().Seed.Secrets.MpesaWebhookSecret },
 		MaxSkew:         cfg.Service.HMACClockSkew,
 		SignatureHeader: "X-Mpesa-Signature",
 		TimestampHeader: "X-Request-Timestamp",
+		OnVerified: func(keyID, version string) {
+			metrics.incHMACKey("mpesa", keyID, version)
+		},
+	}
+	s.startErr = errors.Join(keyringErr, s.useKeyring(mpesaVerifier, cfg.Service.MpesaKeyringPath))
+	if s.clients != nil {
+		s.startErr = errors.Join(s.startErr, s.checkClientIDs(context.Background(), hmacVerifier.Keys))
 	}
 
-	metrics := newMetricsRegistry()
-
-	s := &Server{
-		cfg:       cfg,
-		escrow:    esc,
-		store:     store,
-		hmac:      hmacVerifier,
-		mpesaHMAC: mpesaVerifier,
-		metrics:   metrics,
+	// Share replay claims across replicas when the store supports it.
+	var replay hmacauth.ReplayCache = hmacauth.NewMemoryReplayCache()
+	if shared, ok := store.(hmacauth.ReplayCache); ok {
+		replay = shared
 	}
+	replay = tracedReplayCache{replay}
+	hmacVerifier.Replay = replay
+	s.hmac = hmacVerifier
+	s.mpesaHMAC = mpesaVerifier
 
 	if checker, ok := store.(interface{ Ping(context.Context) error }); ok {
 		s.dbHealthFn = checker.Ping
@@ -61,30 +122,130 @@ func NewServer(cfg *config.AppConfig, esc escrow.Client, store idempotency.Store
 	if checker, ok := esc.(escrow.HealthChecker); ok {
 		s.rpcHealthFn = checker.Ping
 	}
+	if checker, ok := esc.(escrow.BalanceChecker); ok {
+		s.balances = newBalanceMonitor(cfg.Chain.Balance, checker, metrics, s.logger)
+	}
+	if monitor, ok := esc.(escrow.EndpointMonitor); ok {
+		s.endpoints = monitor
+	}
+	if finder, ok := esc.(escrow.IntentFinder); ok {
+		s.intentFinder = finder
+	}
+	if cfg.Breaker.FailureThreshold > 0 {
+		s.breaker = escrow.NewBreaker(esc, escrow.BreakerConfig{
+			FailureThreshold: cfg.Breaker.FailureThreshold,
+			OpenTimeout:      cfg.Breaker.OpenTimeout,
+			HalfOpenProbes:   cfg.Breaker.HalfOpenProbes,
+		})
+		s.breaker.OnStateChange = func(from, to escrow.BreakerState) {
+			s.logger.Warn("escrow circuit state changed", "from", from.String(), "to", to.String())
+		}
+		s.escrow = s.breaker
+		metrics.trackBreaker(s.breaker)
+	}
+	// The budget always exists so a reload can enable it; retrier only
+	// consults it while the ratio is positive.
+	s.retryBudget = retry.NewBudget(cfg.Retry.BudgetRatio, cfg.Retry.BudgetBurst)
+	metrics.trackRetryBudget(s.retryBudget)
+	s.bgCtx, s.bgCancel = context.WithCancel(context.Background())
 
 	mux := http.NewServeMux()
-	mux.Handle("/api/v1/mint-intents", s.hmac.Middleware(http.HandlerFunc(s.handleMintIntents)))
-	mux.Handle("/api/v1/callbacks/mpesa", s.mpesaHMAC.Middleware(http.HandlerFunc(s.handleMpesaCallback)))
+	mux.Handle("/api/v1/mint-intents", s.rateLimitIP(s.hmac.Middleware(s.withAPIClient(s.rateLimitCaller(http.HandlerFunc(s.handleMintIntents))))))
+	mux.Handle("GET /api/v1/mint-intents/{intentId}", s.rateLimitIP(s.hmac.Middleware(s.withAPIClient(s.rateLimitCaller(http.HandlerFunc(s.handleGetMintIntent))))))
+	// Callbacks settle payments users already made, so a user who minted
+	// several times in a row must not have settlements refused; only the
+	// pre-auth IP limit applies.
+	mux.Handle("/api/v1/callbacks/mpesa", s.rateLimitIP(s.mpesaHMAC.Middleware(http.HandlerFunc(s.handleMpesaCallback))))
 	mux.Handle("/api/v1/metrics", metrics.handler())
 	mux.HandleFunc("/api/v1/health", s.handleHealth)
+	mux.Handle("/api/v1/admin/config", s.requireAdmin(http.HandlerFunc(s.handleAdminConfig)))
 
 	s.httpServer = &http.Server{
 		Addr:              ":" + strconv.Itoa(cfg.Service.HTTPPort),
-		Handler:           requestIDMiddleware(mux),
+		Handler:           s.traceRequests(mux, s.requestLogging(s.instrumentHTTP(mux))),
 		ReadHeaderTimeout: 15 * time.Second,
+		ReadTimeout:       cfg.Service.HTTPReadTimeout,
+		WriteTimeout:      cfg.Service.HTTPWriteTimeout,
+		IdleTimeout:       cfg.Service.HTTPIdleTimeout,
 	}
 	return s
 }
 
+// Start serves HTTP until Shutdown. It fails straight away if NewServer hit
+// a problem the server must not run with, such as an unreadable keyring.
 func (s *Server) Start() error {
-	log.Printf("API listening on %s", s.httpServer.Addr)
+	if s.startErr != nil {
+		return s.startErr
+	}
+	if s.balances != nil {
+		go s.balances.run(s.bgCtx)
+	}
+	if s.endpoints != nil {
+		go s.endpoints.MonitorEndpoints(logging.NewContext(s.bgCtx, s.logger))
+	}
+	if s.cfg().Service.RetryQueuePath != "" {
+		go s.runRetryQueue(logging.NewContext(s.bgCtx, s.logger))
+	}
+	sw, _ := s.store.(idempotency.Sweeper)
+	buckets, _ := s.limiter.(ratelimit.Sweeper)
+	if sw != nil || buckets != nil {
+		go s.runSweeper(logging.NewContext(s.bgCtx, s.logger), sw, buckets)
+	}
+	for _, v := range []*hmacauth.Verifier{s.hmac, s.mpesaHMAC} {
+		if v.Keys != nil {
+			go v.Keys.Watch(s.bgCtx, s.keyringReloadInterval(), func(err error) {
+				s.logger.Error("keyring reload failed", "error", err)
+			})
+		}
+	}
+	s.logBanner()
 	return s.httpServer.ListenAndServe()
 }
 
+// Handler returns the root HTTP handler, for embedding the API in tests or other servers.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.bgCancel()
 	return s.httpServer.Shutdown(ctx)
 }
 
+// WithLogger sets the base logger; request loggers derive from it.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// useKeyring switches v to the keyring at path. The keyring replaces the seed
+// secret so rotated-out secrets stop verifying. Once a keyring is configured v
+// never lets unsigned requests through: if it cannot be loaded every request
+// is refused and the error is returned for Start to report.
+func (s *Server) useKeyring(v *hmacauth.Verifier, path string) error {
+	if path == "" {
+		return nil
+	}
+	v.Required = true
+	v.Secret = ""
+	v.SecretFunc = nil
+	keys, err := hmacauth.LoadKeyring(path)
+	if err != nil {
+		s.logger.Error("keyring not loaded, refusing signed routes", "path", path, "error", err)
+		return fmt.Errorf("keyring %s: %w", path, err)
+	}
+	v.Keys = keys
+	return nil
+}
+
+func (s *Server) keyringReloadInterval() time.Duration {
+	if s.cfg().Service.KeyringReloadInterval > 0 {
+		return s.cfg().Service.KeyringReloadInterval
+	}
+	return 30 * time.Second
+}
+
 type mintIntentRequest struct {
 	UserAddress string `json:"userAddress"`
 	Amount      string `json:"amount"`
@@ -113,27 +274,37 @@ type mpesaCallbackResponse struct {
 
 const mpesaKeyPrefix = "mpesa:"
 
+// defaultReservationTTL bounds an idempotency reservation when no HTTP write
+// timeout is configured.
+const defaultReservationTTL = time.Minute
+
 func (s *Server) handleMintIntents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	key := strings.TrimSpace(r.Header.Get("X-Idempotency-Key"))
-	if key == "" {
+	rawKey := strings.TrimSpace(r.Header.Get("X-Idempotency-Key"))
+	if rawKey == "" {
 		http.Error(w, "missing X-Idempotency-Key header", http.StatusBadRequest)
 		return
 	}
 
 	ctx := r.Context()
+	key := scopedKey(ctx, rawKey)
 
-	if existing, _ := s.store.Get(ctx, key); existing != nil {
+	if existing, _ := s.getRecord(ctx, key); existing != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(existing.StatusCode)
 		_, _ = w.Write(existing.Response)
 		s.metrics.incMint("cached")
 		return
 	}
+	release, ok := s.reserveKey(ctx, w, key)
+	if !ok {
+		return
+	}
+	defer release()
 
 	var payload mintIntentRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -144,8 +315,27 @@ func (s *Server) handleMintIntents(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if amount, ok := new(big.Int).SetString(payload.Amount, 10); ok && !s.cfg().Limits.Allows(amount) {
+		http.Error(w, "amount outside mint limits", http.StatusBadRequest)
+		return
+	}
+	ctx = logging.With(ctx, logging.KeyTxRef, payload.TxRef)
+	if client, ok := apiclients.FromContext(ctx); ok && !client.AllowsCountry(payload.CountryCode) {
+		http.Error(w, "country code not allowed for client", http.StatusForbidden)
+		return
+	}
+	if err := s.receiveIntent(ctx, rawKey, payload); err != nil {
+		if errors.Is(err, intents.ErrDuplicate) {
+			s.metrics.incMint("duplicate_txref")
+			http.Error(w, "txRef already used by another intent", http.StatusConflict)
+			return
+		}
+		logging.FromContext(ctx).Error("intent record failed", "error", err)
+		http.Error(w, "intent store unavailable", http.StatusServiceUnavailable)
+		return
+	}
 
-	result, err := s.escrow.SubmitIntent(ctx, escrow.SubmitIntentRequest{
+	result, err := s.submitIntentWithRetry(ctx, escrow.SubmitIntentRequest{
 		UserAddress: payload.UserAddress,
 		Amount:      payload.Amount,
 		CountryCode: payload.CountryCode,
@@ -153,10 +343,18 @@ func (s *Server) handleMintIntents(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		s.metrics.incMint("failed")
+		s.markIntent(ctx, payload.TxRef, intents.StatusFailed, intents.Update{Error: err.Error()})
+		logging.FromContext(ctx).Error("submit intent failed", "error", err)
 		http.Error(w, "failed to submit intent: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
+	logging.FromContext(ctx).Info("intent submitted",
+		logging.KeyIntentID, result.IntentID,
+		logging.KeyTxHash, result.TxHash,
+	)
+	s.markIntent(ctx, payload.TxRef, intents.StatusSubmitted, intents.Update{IntentID: result.IntentID, SubmitTxHash: result.TxHash})
+
 	respBody := mintIntentResponse{
 		IntentID: result.IntentID,
 		Status:   "submitted",
@@ -168,9 +366,11 @@ func (s *Server) handleMintIntents(w http.ResponseWriter, r *http.Request) {
 		StatusCode: http.StatusCreated,
 		Response:   b,
 		CreatedAt:  time.Now(),
-		ExpiresAt:  time.Now().Add(s.cfg.Service.IdempotencyWindow),
+		ExpiresAt:  time.Now().Add(s.cfg().Service.IdempotencyWindow),
+	}
+	if err := s.saveRecord(ctx, key, record); err != nil {
+		logging.FromContext(ctx).Error("idempotency save failed", "error", err)
 	}
-	_ = s.store.Save(ctx, key, record)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -178,13 +378,72 @@ func (s *Server) handleMintIntents(w http.ResponseWriter, r *http.Request) {
 	s.metrics.incMint("created")
 }
 
+// reserveKey claims key in a store shared across replicas so a concurrent
+// request with the same idempotency key is refused rather than submitted
+// twice. It writes the error response itself when the key cannot be claimed.
+// The returned release is a no-op once the response has been saved.
+func (s *Server) reserveKey(ctx context.Context, w http.ResponseWriter, key string) (release func(), ok bool) {
+	release, reserved, err := s.reserve(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Error("idempotency reserve failed", "error", err)
+		http.Error(w, "idempotency store unavailable", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if !reserved {
+		// The other request may have finished since the lookup.
+		if existing, _ := s.getRecord(ctx, key); existing != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(existing.StatusCode)
+			_, _ = w.Write(existing.Response)
+			s.metrics.incMint("cached")
+			return nil, false
+		}
+		s.metrics.incMint("in_progress")
+		http.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+		return nil, false
+	}
+	return release, true
+}
+
+// reserve claims key in a store shared across replicas. reserved is false
+// when someone else holds the key or has saved a record under it. Stores
+// that are not shared always report reserved. The returned release is a
+// no-op once a record has been saved under key.
+func (s *Server) reserve(ctx context.Context, key string) (release func(), reserved bool, err error) {
+	reserver, shared := s.store.(idempotency.Reserver)
+	if !shared {
+		return func() {}, true, nil
+	}
+	ttl := s.cfg().Service.HTTPWriteTimeout
+	if ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+	reserved, err = reserver.Reserve(ctx, key, ttl)
+	if err != nil || !reserved {
+		return nil, false, err
+	}
+	return func() {
+		// The request context may already be cancelled.
+		if err := reserver.Release(context.WithoutCancel(ctx), key); err != nil {
+			logging.FromContext(ctx).Warn("idempotency release failed", "error", err)
+		}
+	}, true, nil
+}
+
 func (s *Server) handleMpesaCallback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Answer before the provider gives up so it retries instead of marking
+	// the payment as failed.
 	ctx := r.Context()
+	if deadline := webhookDeadline(s.cfg().Service.WebhookTimeout); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
 
 	var payload mpesaCallbackRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -196,23 +455,82 @@ func (s *Server) handleMpesaCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx = logging.With(ctx, logging.KeyIntentID, payload.IntentID, logging.KeyTxRef, payload.TxRef)
+
 	key := mpesaKeyPrefix + payload.TxRef
-	if existing, _ := s.store.Get(ctx, key); existing != nil {
+	if existing, _ := s.getRecord(ctx, key); existing != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(existing.StatusCode)
 		_, _ = w.Write(existing.Response)
 		s.metrics.incCallback("cached")
 		return
 	}
+	// The same txRef may be in flight on another replica or in the retry
+	// queue drain; the provider retries once that has finished.
+	release, reserved, err := s.reserve(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Error("idempotency reserve failed", "error", err)
+		http.Error(w, "idempotency store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !reserved {
+		if existing, _ := s.getRecord(ctx, key); existing != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(existing.StatusCode)
+			_, _ = w.Write(existing.Response)
+			s.metrics.incCallback("cached")
+			return
+		}
+		s.metrics.incCallback("in_progress")
+		w.Header().Set("Retry-After", strconv.Itoa(webhookRetryAfterSeconds))
+		http.Error(w, "a callback for this txRef is in progress, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
 
+	s.markIntentByID(ctx, payload.IntentID, intents.StatusConfirmed, intents.Update{})
 	txHash, err := s.executeMintWithRetry(ctx, payload.IntentID)
+	if errors.Is(err, escrow.ErrCircuitOpen) && s.cfg().Service.RetryQueuePath != "" {
+		if qerr := s.enqueueRetry(ctx, payload, err); qerr == nil {
+			s.metrics.incCallback("queued")
+			logging.FromContext(ctx).Warn("chain circuit open, callback queued for retry")
+			body, _ := json.Marshal(mpesaCallbackResponse{Status: "queued", IntentID: payload.IntentID})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write(body)
+			return
+		}
+	}
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil {
+		s.metrics.incTimeout("webhook")
+		s.metrics.incCallback("timeout")
+		logging.FromContext(ctx).Warn("callback processing hit the webhook deadline, asking provider to retry", "error", err)
+		w.Header().Set("Retry-After", strconv.Itoa(webhookRetryAfterSeconds))
+		http.Error(w, "callback processing timed out, retry later", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		s.metrics.incCallback("failed")
-		s.writeDLQ(payload, err)
+		logging.FromContext(ctx).Error("execute mint failed, writing to DLQ", "error", err)
+		s.writeDLQ(ctx, payload, err)
 		http.Error(w, "failed to execute mint: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	body := s.completeCallback(ctx, payload, txHash)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	s.updateDLQDepth()
+}
+
+// completeCallback records a processed callback under its idempotency key and
+// returns the response body.
+func (s *Server) completeCallback(ctx context.Context, payload mpesaCallbackRequest, txHash string) []byte {
+	logging.FromContext(ctx).Info("mint executed", logging.KeyTxHash, txHash)
+	s.markIntentByID(ctx, payload.IntentID, intents.StatusExecuted, intents.Update{ExecuteTxHash: txHash})
+
 	resp := mpesaCallbackResponse{
 		Status:   "processed",
 		IntentID: payload.IntentID,
@@ -224,15 +542,29 @@ func (s *Server) handleMpesaCallback(w http.ResponseWriter, r *http.Request) {
 		StatusCode: http.StatusOK,
 		Response:   body,
 		CreatedAt:  time.Now(),
-		ExpiresAt:  time.Now().Add(s.cfg.Service.IdempotencyWindow),
+		ExpiresAt:  time.Now().Add(s.cfg().Service.IdempotencyWindow),
+	}
+	if err := s.saveRecord(ctx, mpesaKeyPrefix+payload.TxRef, record); err != nil {
+		logging.FromContext(ctx).Error("idempotency save failed", "error", err)
 	}
-	_ = s.store.Save(ctx, key, record)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(body)
 	s.metrics.incCallback("processed")
-	s.updateDLQDepth()
+	return body
+}
+
+// webhookRetryAfterSeconds is the Retry-After sent with a timed-out callback.
+const webhookRetryAfterSeconds = 5
+
+// webhookDeadline leaves a fifth of the provider's timeout, at most one second,
+// to write the response and cover network time.
+func webhookDeadline(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return 0
+	}
+	margin := timeout / 5
+	if margin > time.Second {
+		margin = time.Second
+	}
+	return timeout - margin
 }
 
 func validateMintIntentRequest(req mintIntentRequest) error {
@@ -267,63 +599,186 @@ func validateMpesaRequest(req mpesaCallbackRequest) error {
 	return nil
 }
 
+// executeMintWithRetry sends ExecuteMint. Resending after a lost reply
+// reverts with IntentAlreadyExecuted although the first call minted, so each
+// retry checks the intent's on-chain status first, and so does a revert, which
+// a provider's redelivery meets when our earlier attempt minted. The hash of a
+// mint found that way is unknown and left empty.
 func (s *Server) executeMintWithRetry(ctx context.Context, intentID string) (string, error) {
-	attempts := s.cfg.Retry.MaxAttempts
-	if attempts <= 0 {
-		attempts = 1
-	}
-
-	backoff := s.cfg.Retry.InitialBackoff
-	if backoff <= 0 {
-		backoff = 500 * time.Millisecond
-	}
-
-	for i := 1; i <= attempts; i++ {
-		resp, err := s.escrow.ExecuteMint(ctx, intentID)
-		if err == nil {
-			s.metrics.incRetry("success")
-			return resp.TxHash, nil
+	var txHash string
+	err := s.retrier(ctx, "executeMint", s.cfg().Retry.MaxAttempts).Do(ctx, func(ctx context.Context, attempt int) error {
+		attemptCtx, span := tracer.Start(ctx, "executeMint.attempt", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt),
+			attribute.String("intent.id", intentID),
+		))
+		if attempt > 1 {
+			minted, err := s.mintedOnChain(attemptCtx, intentID)
+			if err != nil || minted {
+				span.SetAttributes(attribute.Bool("intent.reconciled", minted))
+				tracing.End(span, err)
+				txHash = ""
+				return err
+			}
 		}
-		if !isRetryable(err) || i == attempts {
-			s.metrics.incRetry("failed")
-			return "", err
+		resp, err := s.escrow.ExecuteMint(attemptCtx, intentID)
+		if isRevert(err) {
+			if minted, _ := s.mintedOnChain(attemptCtx, intentID); minted {
+				span.SetAttributes(attribute.Bool("intent.reconciled", true))
+				resp, err = escrow.ExecuteMintResponse{}, nil
+			}
 		}
+		tracing.End(span, err)
+		txHash = resp.TxHash
+		return err
+	})
+	return txHash, err
+}
 
-		s.metrics.incRetry("retry")
-		sleep := backoff
-		if s.cfg.Retry.MaxBackoff > 0 && sleep > s.cfg.Retry.MaxBackoff {
-			sleep = s.cfg.Retry.MaxBackoff
-		}
-		select {
-		case <-time.After(sleep):
-		case <-ctx.Done():
-			return "", ctx.Err()
-		}
+// mintedOnChain reports whether intentID has already been executed. Without
+// an IntentFinder it cannot tell and reports false.
+func (s *Server) mintedOnChain(ctx context.Context, intentID string) (bool, error) {
+	if s.intentFinder == nil {
+		return false, nil
+	}
+	minted, err := s.intentFinder.IntentExecuted(ctx, intentID)
+	if minted {
+		logging.FromContext(ctx).Warn("executeMint reply was lost but the intent is minted", logging.KeyIntentID, intentID)
+	}
+	return minted, err
+}
 
-		if s.cfg.Retry.BackoffMultiplier > 1 {
-			backoff = backoff * time.Duration(s.cfg.Retry.BackoffMultiplier)
+// submitIntentWithRetry sends SubmitIntent, which the contract does not
+// deduplicate: resending after a lost reply reverts with TxRefAlreadyConsumed
+// even though the first call created the intent. Each retry therefore looks
+// the intent up first and returns it if an earlier attempt landed. A client
+// that cannot look intents up gets a single attempt.
+func (s *Server) submitIntentWithRetry(ctx context.Context, req escrow.SubmitIntentRequest) (escrow.SubmitIntentResponse, error) {
+	maxAttempts := s.cfg().Retry.SubmitMaxAttempts
+	if s.intentFinder == nil {
+		maxAttempts = 1
+	}
+	var result escrow.SubmitIntentResponse
+	err := s.retrier(ctx, "submitIntent", maxAttempts).Do(ctx, func(ctx context.Context, attempt int) error {
+		attemptCtx, span := tracer.Start(ctx, "submitIntent.attempt", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt),
+			attribute.String("tx.ref", req.TxRef),
+		))
+		if attempt > 1 {
+			intentID, found, err := s.intentFinder.FindIntent(attemptCtx, req)
+			if err != nil {
+				tracing.End(span, err)
+				return err
+			}
+			if found {
+				span.SetAttributes(attribute.Bool("intent.reconciled", true))
+				tracing.End(span, nil)
+				logging.FromContext(ctx).Warn("submitIntent reply was lost but the intent is on chain", logging.KeyIntentID, intentID)
+				result = escrow.SubmitIntentResponse{IntentID: intentID}
+				return nil
+			}
 		}
+		var err error
+		result, err = s.escrow.SubmitIntent(attemptCtx, req)
+		tracing.End(span, err)
+		return err
+	})
+	return result, err
+}
+
+// retrier builds the chain retry policy for one operation. Every operation
+// draws on the same budget so an outage cannot multiply chain load by
+// MaxAttempts, and rate-limited calls wait at least as long as the node asked.
+func (s *Server) retrier(ctx context.Context, op string, maxAttempts int) *retry.Retrier {
+	cfg := s.cfg().Retry
+	jitter, _ := retry.ParseJitter(cfg.Jitter)
+	policy := retry.Policy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Multiplier:     cfg.BackoffMultiplier,
+		Jitter:         jitter,
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 500 * time.Millisecond
+	}
+	// Throttling clears by waiting rather than by luck, so allow one extra
+	// attempt and never jitter below the node's hint.
+	rateLimited := policy
+	rateLimited.MaxAttempts++
+	rateLimited.Jitter = retry.JitterNone
+
+	var budget *retry.Budget
+	if cfg.BudgetRatio > 0 {
+		budget = s.retryBudget
+	}
+
+	return &retry.Retrier{
+		Policy:   policy,
+		Policies: map[retry.Class]retry.Policy{retry.RateLimited: rateLimited},
+		Classify: classifyError,
+		Hint:     escrow.RetryAfterHint,
+		Budget:   budget,
+		Sleep:    s.backoff,
+		OnRetry: func(attempt int, class retry.Class, delay time.Duration, err error) {
+			s.metrics.incRetry(op, "retry")
+			logging.FromContext(ctx).Warn(op+" attempt failed",
+				"attempt", attempt,
+				"class", string(class),
+				"backoff_ms", delay.Milliseconds(),
+				"error", err,
+			)
+		},
+		OnDone: func(outcome retry.Outcome, attempts int) {
+			s.metrics.incRetry(op, string(outcome))
+		},
 	}
+}
 
-	return "", fmt.Errorf("exhausted retries")
+// backoff sleeps between attempts under its own span so retry delay is
+// visible next to the RPC time in a trace.
+func (s *Server) backoff(ctx context.Context, d time.Duration) error {
+	_, span := tracer.Start(ctx, "retry.backoff", trace.WithAttributes(attribute.Int64("retry.backoff_ms", d.Milliseconds())))
+	defer span.End()
+	return retry.Sleep(ctx, d)
 }
 
-func isRetryable(err error) bool {
-	if err == nil {
-		return false
+// revertErrorCode is the JSON-RPC error code nodes use for a contract revert.
+const revertErrorCode = 3
+
+// classifyError sorts chain errors for the retry policy.
+func classifyError(err error) retry.Class {
+	// An open circuit will not close within one request's backoff; the caller
+	// parks the work instead.
+	if errors.Is(err, escrow.ErrCircuitOpen) || errors.Is(err, escrow.ErrInvalidRequest) {
+		return retry.Permanent
 	}
-	msg := err.Error()
-	if strings.Contains(msg, "UserNotCompliant") {
-		return false
+	if _, ok := escrow.RetryAfterHint(err); ok {
+		return retry.RateLimited
 	}
-	if strings.Contains(strings.ToLower(msg), "invalid") {
+	// A revert is the contract's answer; sending the call again gets the same one.
+	if isRevert(err) {
+		return retry.Permanent
+	}
+	return retry.Transient
+}
+
+func isRevert(err error) bool {
+	if err == nil {
 		return false
 	}
-	return true
+	var rpcErr rpc.Error
+	return (errors.As(err, &rpcErr) && rpcErr.ErrorCode() == revertErrorCode) || strings.Contains(err.Error(), "execution reverted")
+}
+
+func isRetryable(err error) bool {
+	return err != nil && classifyError(err) != retry.Permanent
 }
 
-func (s *Server) writeDLQ(payload mpesaCallbackRequest, execErr error) {
-	if s.cfg.Service.DLQPath == "" {
+// writeDLQ records a callback that failed for good: the intent is marked
+// failed and the payload is kept for an operator to replay.
+func (s *Server) writeDLQ(ctx context.Context, payload mpesaCallbackRequest, execErr error) {
+	s.markIntentByID(ctx, payload.IntentID, intents.StatusFailed, intents.Update{Error: execErr.Error()})
+	if s.cfg().Service.DLQPath == "" {
 		return
 	}
 
@@ -339,19 +794,19 @@ func (s *Server) writeDLQ(payload mpesaCallbackRequest, execErr error) {
 
 	data, err := json.MarshalIndent(entry, "", "  ")
 	if err != nil {
-		log.Printf("dlq marshal error: %v", err)
+		logging.FromContext(ctx).Error("dlq marshal failed", "error", err)
 		return
 	}
 
-	if err := os.MkdirAll(s.cfg.Service.DLQPath, 0o755); err != nil {
-		log.Printf("dlq mkdir error: %v", err)
+	if err := os.MkdirAll(s.cfg().Service.DLQPath, 0o755); err != nil {
+		logging.FromContext(ctx).Error("dlq mkdir failed", "error", err)
 		return
 	}
 
 	filename := fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), payload.TxRef)
-	path := filepath.Join(s.cfg.Service.DLQPath, filename)
+	path := filepath.Join(s.cfg().Service.DLQPath, filename)
 	if err := os.WriteFile(path, data, 0o600); err != nil {
-		log.Printf("dlq write error: %v", err)
+		logging.FromContext(ctx).Error("dlq write failed", "path", path, "error", err)
 	}
 
 	s.updateDLQDepth()
@@ -366,12 +821,12 @@ func (s *Server) updateDLQDepth() int {
 }
 
 func (s *Server) currentDLQDepth() int {
-	if s.cfg.Service.DLQPath == "" {
+	if s.cfg().Service.DLQPath == "" {
 		return 0
 	}
-	entries, err := os.ReadDir(s.cfg.Service.DLQPath)
+	entries, err := os.ReadDir(s.cfg().Service.DLQPath)
 	if err != nil {
-		log.Printf("dlq read error: %v", err)
+		s.logger.Error("dlq read failed", "error", err)
 		return 0
 	}
 	return len(entries)
@@ -382,9 +837,10 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	overallHealthy := true
 
 	rpcInfo := struct {
-		Connected bool    `json:"connected"`
-		LatencyMs float64 `json:"latency_ms"`
-		Error     string  `json:"error,omitempty"`
+		Connected bool                    `json:"connected"`
+		LatencyMs float64                 `json:"latency_ms"`
+		Error     string                  `json:"error,omitempty"`
+		Endpoints []escrow.EndpointStatus `json:"endpoints,omitempty"`
 	}{}
 
 	if s.rpcHealthFn != nil {
@@ -404,6 +860,17 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		rpcInfo.LatencyMs = 0
 	}
 
+	// Losing a fallback node degrades the service; losing all of them fails the ping above.
+	endpointDown := false
+	if s.endpoints != nil {
+		rpcInfo.Endpoints = s.endpoints.Endpoints()
+		for _, ep := range rpcInfo.Endpoints {
+			if !ep.Healthy {
+				endpointDown = true
+			}
+		}
+	}
+
 	dbInfo := struct {
 		Connected bool   `json:"connected"`
 		Error     string `json:"error,omitempty"`
@@ -421,21 +888,47 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 	queueDepth := s.updateDLQDepth()
 
+	// A low executor balance degrades the service but it can still accept
+	// traffic, so it does not flip the response to 503.
+	var executorInfo *balanceSnapshot
+	lowBalance := false
+	if s.balances != nil {
+		snap := s.balances.current(ctx)
+		executorInfo = &snap
+		lowBalance = snap.low()
+	}
+
+	// An open circuit parks callbacks in the retry queue rather than failing
+	// them, so it degrades the service without failing the health check.
+	var circuit *escrow.BreakerStatus
+	circuitOpen := false
+	if s.breaker != nil {
+		st := s.breaker.Status()
+		circuit = &st
+		circuitOpen = st.State != escrow.BreakerClosed.String()
+	}
+
 	status := "healthy"
-	if !overallHealthy {
+	if !overallHealthy || lowBalance || endpointDown || circuitOpen {
 		status = "degraded"
 	}
 
 	resp := struct {
-		Status     string      `json:"status"`
-		RPC        interface{} `json:"rpc"`
-		Database   interface{} `json:"database"`
-		QueueDepth int         `json:"queue_depth"`
+		Status     string                `json:"status"`
+		RPC        interface{}           `json:"rpc"`
+		Database   interface{}           `json:"database"`
+		Executor   *balanceSnapshot      `json:"executor,omitempty"`
+		Circuit    *escrow.BreakerStatus `json:"circuit,omitempty"`
+		QueueDepth int                   `json:"queue_depth"`
+		RetryQueue int                   `json:"retry_queue_depth"`
 	}{
 		Status:     status,
 		RPC:        rpcInfo,
 		Database:   dbInfo,
+		Executor:   executorInfo,
+		Circuit:    circuit,
 		QueueDepth: queueDepth,
+		RetryQueue: s.updateRetryQueueDepth(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -445,11 +938,57 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-func requestIDMiddleware(next http.Handler) http.Handler {
+// requestLogging assigns every request an ID, echoes it in X-Request-Id and
+// attaches a logger carrying it to the request context.
+func (s *Server) requestLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("X-Request-Id") == "" {
-			r.Header.Set("X-Request-Id", fmt.Sprintf("%d", time.Now().UnixNano()))
+		reqID := r.Header.Get(requestIDHeader)
+		if !validRequestID(reqID) {
+			reqID = logging.NewRequestID()
+			r.Header.Set(requestIDHeader, reqID)
+		}
+		w.Header().Set(requestIDHeader, reqID)
+
+		logger := s.logger.With(logging.KeyRequestID, reqID)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With(logging.KeyTraceID, sc.TraceID().String())
 		}
-		next.ServeHTTP(w, r)
+		ctx := logging.NewContext(r.Context(), logger)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		logging.FromContext(ctx).Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000.0,
+		)
 	})
 }
+
+const requestIDHeader = "X-Request-Id"
+
+// validRequestID accepts caller-supplied IDs that are safe to log verbatim.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c == '-' || c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
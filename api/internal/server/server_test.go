@@ -8,16 +8,32 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"fiatrails/internal/apiclients"
 	"fiatrails/internal/config"
 	"fiatrails/internal/escrow"
+	"fiatrails/internal/hmacauth"
 	"fiatrails/internal/idempotency"
+	"fiatrails/internal/intents"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/retry"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestMintIntentIdempotency(t *testing.T) {
@@ -94,6 +110,69 @@ func TestMintIntentIdempotency(t *testing.T) {
 	}
 }
 
+func TestMintIntentReservedAcrossReplicas(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.HMACSalt = "shared-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1, SubmitMaxAttempts: 1}
+
+	mr := miniredis.RunT(t)
+	newStore := func() *idempotency.RedisStore {
+		store, err := idempotency.NewRedisStore(context.Background(), "redis://"+mr.Addr(), "test:")
+		if err != nil {
+			t.Fatalf("redis store: %v", err)
+		}
+		t.Cleanup(store.Close)
+		return store
+	}
+	faults := escrow.NewFaultClient(1)
+	handler := NewServer(cfg, faults, newStore()).Handler()
+	other := newStore()
+
+	post := func(key string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(mintIntentRequest{UserAddress: "0x00000000000000000000000000000000000000a1", Amount: "100", CountryCode: "KES", TxRef: "tx-" + key})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", bytes.NewReader(body))
+		req.Header.Set("X-Idempotency-Key", key)
+		if err := (&hmacauth.Signer{Secret: "shared-secret"}).Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Another replica is still processing key-1.
+	if ok, err := other.Reserve(context.Background(), "key-1", time.Minute); err != nil || !ok {
+		t.Fatalf("reserve: %v %v", ok, err)
+	}
+	if rec := post("key-1"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while the key is reserved, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := faults.CallCount(escrow.MethodSubmitIntent); n != 0 {
+		t.Fatalf("a reserved key reached the chain: %d submissions", n)
+	}
+
+	// Once the other replica saves its response, this one replays it.
+	done := idempotency.Record{StatusCode: http.StatusCreated, Response: []byte(`{"intentId":"0xother"}`), CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Minute)}
+	if err := other.Save(context.Background(), "key-1", done); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if rec := post("key-1"); rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "0xother") {
+		t.Fatalf("expected the saved response, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A failed submission releases the key so the client can retry it.
+	faults.Script(escrow.MethodSubmitIntent, escrow.FaultNotCompliant)
+	if rec := post("key-2"); rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+	if ok, _ := other.Reserve(context.Background(), "key-2", time.Minute); !ok {
+		t.Fatal("a failed request left its reservation behind")
+	}
+}
+
 func TestMpesaCallbackIdempotency(t *testing.T) {
 	cfg := &config.AppConfig{
 		Seed: config.SeedConfig{
@@ -171,6 +250,62 @@ func TestMpesaCallbackIdempotency(t *testing.T) {
 	}
 }
 
+func TestMpesaCallbackReplayReturnsStoredResult(t *testing.T) {
+	cfg := &config.AppConfig{
+		Seed: config.SeedConfig{
+			Secrets: struct {
+				HMACSalt           string `json:"hmacSalt"`
+				IdempotencyKeySalt string `json:"idempotencyKeySalt"`
+				MpesaWebhookSecret string `json:"mpesaWebhookSecret"`
+			}{
+				HMACSalt:           "mint-secret",
+				MpesaWebhookSecret: "mpesa-secret",
+			},
+		},
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+			DLQPath:           t.TempDir(),
+		},
+	}
+
+	esc := &stubEscrow{}
+	srv := NewServer(cfg, esc, idempotency.NewMemoryStore())
+
+	body, _ := json.Marshal(mpesaCallbackRequest{
+		IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+		TxRef:       "mpesa-replay",
+		UserAddress: "0xabc",
+		Amount:      "1",
+	})
+	// Even a nonce signature is not claimed on the callback route: the
+	// provider resends it verbatim and txRef idempotency answers.
+	signed := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+	signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+	if err := signer.Sign(signed, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	var bodies [][]byte
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		req.Header = signed.Header.Clone()
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("attempt %d: expected 200, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+		bodies = append(bodies, rec.Body.Bytes())
+	}
+
+	if !bytes.Equal(bodies[0], bodies[1]) {
+		t.Fatalf("expected the stored result, got %s then %s", bodies[0], bodies[1])
+	}
+	if esc.executeCalls != 1 {
+		t.Fatalf("expected execute mint called once, got %d", esc.executeCalls)
+	}
+}
+
 func TestMpesaCallbackDLQOnFailure(t *testing.T) {
 	dlqDir := t.TempDir()
 	cfg := &config.AppConfig{
@@ -291,6 +426,266 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthReportsLowExecutorBalance(t *testing.T) {
+	cfg := &config.AppConfig{
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+			DLQPath:           t.TempDir(),
+		},
+		Chain: config.ChainConfig{
+			Balance: config.BalanceConfig{
+				MinBalanceWei: big.NewInt(1_000_000),
+				GasPerTx:      100,
+			},
+		},
+	}
+
+	esc := &stubBalanceEscrow{balance: big.NewInt(500_000), gasPrice: big.NewInt(10)}
+	srv := NewServer(cfg, esc, &stubStore{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	srv.handleHealth(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		Status   string          `json:"status"`
+		Executor balanceSnapshot `json:"executor"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal health: %v", err)
+	}
+	if resp.Status != "degraded" {
+		t.Fatalf("expected status degraded, got %s", resp.Status)
+	}
+	if len(resp.Executor.Accounts) != 1 {
+		t.Fatalf("expected one executor account, got %d", len(resp.Executor.Accounts))
+	}
+	acct := resp.Executor.Accounts[0]
+	if !acct.Low || acct.BalanceWei != "500000" || acct.RemainingTxs != 500 {
+		t.Fatalf("unexpected executor info: %+v", acct)
+	}
+}
+
+func TestHealthReportsRPCEndpoints(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Service.DLQPath = t.TempDir()
+
+	esc := &stubEndpointEscrow{statuses: []escrow.EndpointStatus{
+		{Name: "http://primary:8545", Priority: 0, Healthy: false, LastError: "12 blocks behind"},
+		{Name: "http://backup:8545", Priority: 1, Healthy: true, Active: true},
+	}}
+	srv := NewServer(cfg, esc, &stubStore{})
+	srv.rpcHealthFn = func(context.Context) error { return nil }
+
+	rec := httptest.NewRecorder()
+	srv.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 while a fallback node serves traffic, got %d", rec.Code)
+	}
+	var resp struct {
+		Status string `json:"status"`
+		RPC    struct {
+			Endpoints []escrow.EndpointStatus `json:"endpoints"`
+		} `json:"rpc"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal health: %v", err)
+	}
+	if resp.Status != "degraded" {
+		t.Fatalf("expected status degraded, got %s", resp.Status)
+	}
+	if len(resp.RPC.Endpoints) != 2 || !resp.RPC.Endpoints[1].Active || resp.RPC.Endpoints[0].LastError == "" {
+		t.Fatalf("unexpected endpoints: %+v", resp.RPC.Endpoints)
+	}
+}
+
+func TestMintIntentsScopedPerClient(t *testing.T) {
+	cfg := &config.AppConfig{
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+			DLQPath:           t.TempDir(),
+		},
+	}
+	cfg.Seed.Secrets.HMACSalt = "shared-secret"
+
+	registry := apiclients.NewMemoryRegistry(
+		apiclients.Client{ID: "partner-a", Secret: "secret-a", AllowedCountries: []string{"KES"}},
+		apiclients.Client{ID: "partner-b", Secret: "secret-b"},
+		apiclients.Client{ID: "partner-c", Secret: "secret-c", AllowedEndpoints: []string{"/api/v1/other"}},
+	)
+	srv := NewServer(cfg, escrow.FakeClient{}, idempotency.NewMemoryStore(), WithClientRegistry(registry))
+	handler := srv.Handler()
+
+	send := func(keyID, secret, idemKey, txRef, country string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(mintIntentRequest{
+			UserAddress: "0x00000000000000000000000000000000000000a1",
+			Amount:      "1",
+			CountryCode: country,
+			TxRef:       txRef,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", bytes.NewReader(body))
+		req.Header.Set("X-Idempotency-Key", idemKey)
+		signer := &hmacauth.Signer{KeyID: keyID, Secret: secret}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	recA := send("partner-a", "secret-a", "shared-key", "tx-a", "KES")
+	recB := send("partner-b", "secret-b", "shared-key", "tx-b", "KES")
+	if recA.Code != http.StatusCreated || recB.Code != http.StatusCreated {
+		t.Fatalf("expected 201s, got %d and %d", recA.Code, recB.Code)
+	}
+	if bytes.Equal(recA.Body.Bytes(), recB.Body.Bytes()) {
+		t.Fatalf("clients sharing an idempotency key must not share responses")
+	}
+
+	if rec := send("partner-a", "secret-a", "key-a2", "tx-a2", "UGX"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed country, got %d", rec.Code)
+	}
+	if rec := send("partner-c", "secret-c", "key-c", "tx-c", "KES"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed endpoint, got %d", rec.Code)
+	}
+	if rec := send("partner-b", "secret-a", "key-b2", "tx-b2", "KES"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 when signing with another client's secret, got %d", rec.Code)
+	}
+}
+
+func TestSharedKeyIDsNotAttributedToClients(t *testing.T) {
+	keyring := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(keyring, []byte(`{"keys":[{"id":"partner-k","secret":"ring-secret"}]}`), 0o600); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+	// Both clients may only mint UGX, so a request attributed to them is refused.
+	registry := apiclients.NewMemoryRegistry(
+		apiclients.Client{ID: "partner-k", Secret: "client-secret", AllowedCountries: []string{"UGX"}},
+		apiclients.Client{ID: hmacauth.LegacyKeyID, Secret: "client-secret", AllowedCountries: []string{"UGX"}},
+	)
+
+	for _, tc := range []struct {
+		keyring, keyID, secret string
+	}{
+		{keyring, "partner-k", "ring-secret"},
+		{"", hmacauth.LegacyKeyID, "shared-secret"},
+	} {
+		cfg := &config.AppConfig{}
+		cfg.Seed.Secrets.HMACSalt = "shared-secret"
+		cfg.Service.HMACClockSkew = time.Minute
+		cfg.Service.IdempotencyWindow = time.Minute
+		cfg.Service.HMACKeyringPath = tc.keyring
+		srv := NewServer(cfg, escrow.FakeClient{}, idempotency.NewMemoryStore(), WithClientRegistry(registry))
+		if err := srv.Start(); err == nil || !strings.Contains(err.Error(), "api client "+strconv.Quote(tc.keyID)+" shares its ID") {
+			t.Fatalf("%s: expected the colliding client to be refused at start, got %v", tc.keyID, err)
+		}
+
+		body, _ := json.Marshal(mintIntentRequest{UserAddress: "0x00000000000000000000000000000000000000a1", Amount: "1", CountryCode: "KES", TxRef: "tx-" + tc.keyID})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", bytes.NewReader(body))
+		req.Header.Set("X-Idempotency-Key", "key-"+tc.keyID)
+		if err := (&hmacauth.Signer{KeyID: tc.keyID, Secret: tc.secret}).Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: a shared-key request took the client's scope: %d %s", tc.keyID, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestMpesaCallbackExemptFromUserLimit(t *testing.T) {
+	cfg := &config.AppConfig{
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+			DLQPath:           t.TempDir(),
+		},
+		RateLimit: config.RateLimitConfig{UserPerMinute: 60, UserBurst: 1},
+	}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Seed.Secrets.HMACSalt = "mint-secret"
+	srv := NewServer(cfg, &stubEscrow{}, idempotency.NewMemoryStore())
+	handler := srv.Handler()
+
+	send := func(txRef string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(mpesaCallbackRequest{
+			IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+			TxRef:       txRef,
+			UserAddress: "0xAbC",
+			Amount:      "1",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		signer := &hmacauth.Signer{Secret: "mpesa-secret", SignatureHeader: "X-Mpesa-Signature"}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Settlements for one user beyond the user burst all go through.
+	for _, txRef := range []string{"rl-1", "rl-2", "rl-3"} {
+		if rec := send(txRef); rec.Code != http.StatusOK {
+			t.Fatalf("callback %s: expected 200, got %d", txRef, rec.Code)
+		}
+	}
+
+	// New mints for the same user are still limited.
+	mint := func(txRef string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(mintIntentRequest{UserAddress: "0xAbC", Amount: "1", CountryCode: "KES", TxRef: txRef})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/mint-intents", bytes.NewReader(body))
+		req.Header.Set("X-Idempotency-Key", txRef)
+		if err := (&hmacauth.Signer{Secret: "mint-secret"}).Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := mint("rl-4"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := mint("rl-5")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("missing rate limit headers: %v", rec.Header())
+	}
+}
+
+type stubBalanceEscrow struct {
+	stubEscrow
+	balance  *big.Int
+	gasPrice *big.Int
+}
+
+func (s *stubBalanceEscrow) Balances(context.Context) ([]escrow.AccountBalance, error) {
+	return []escrow.AccountBalance{{
+		Address:  "0x00000000000000000000000000000000000000e1",
+		Balance:  s.balance,
+		GasPrice: s.gasPrice,
+	}}, nil
+}
+
+type stubEndpointEscrow struct {
+	stubEscrow
+	statuses []escrow.EndpointStatus
+}
+
+func (s *stubEndpointEscrow) Endpoints() []escrow.EndpointStatus { return s.statuses }
+func (s *stubEndpointEscrow) MonitorEndpoints(context.Context)   {}
+
 type stubEscrow struct {
 	executeHashes []string
 	executeErrs   []error
@@ -335,3 +730,1031 @@ func sha256Sum(secret, timestamp string, body []byte) []byte {
 	h.Write(body)
 	return h.Sum(nil)
 }
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	for _, tc := range []struct {
+		hops    int
+		headers []string
+		want    string
+	}{
+		{0, []string{"203.0.113.7"}, "192.0.2.1"},
+		{1, []string{"203.0.113.7"}, "203.0.113.7"},
+		// A spoofed entry on the left is ignored.
+		{1, []string{"6.6.6.6, 203.0.113.7"}, "203.0.113.7"},
+		{2, []string{"6.6.6.6, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{2, []string{"6.6.6.6, 203.0.113.7", "10.0.0.2"}, "203.0.113.7"},
+		// Too few entries: the request bypassed a proxy.
+		{2, []string{"6.6.6.6"}, "192.0.2.1"},
+		{1, []string{"not-an-ip"}, "192.0.2.1"},
+	} {
+		cfg := &config.AppConfig{}
+		cfg.RateLimit.TrustedProxies = tc.hops
+		srv := NewServer(cfg, escrow.FakeClient{}, stubStore{})
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		for _, h := range tc.headers {
+			req.Header.Add("X-Forwarded-For", h)
+		}
+		if got := srv.clientIP(req); got != tc.want {
+			t.Errorf("hops=%d X-Forwarded-For=%q: clientIP = %s, want %s", tc.hops, tc.headers, got, tc.want)
+		}
+	}
+}
+
+func TestRequestIDEchoedAndLogged(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Service.DLQPath = t.TempDir()
+
+	var logs bytes.Buffer
+	srv := NewServer(cfg, escrow.FakeClient{}, &stubStore{}, WithLogger(logging.New(&logs, "json", "info")))
+	handler := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	generated := rec.Header().Get("X-Request-Id")
+	if len(generated) != 32 {
+		t.Fatalf("expected generated request id, got %q", generated)
+	}
+	if !strings.Contains(logs.String(), `"request_id":"`+generated+`"`) {
+		t.Fatalf("access log missing request id: %s", logs.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set("X-Request-Id", "upstream-123")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-Id"); got != "upstream-123" {
+		t.Fatalf("expected caller request id to be echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set("X-Request-Id", "bad id\n")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-Id"); got == "bad id\n" || got == "" {
+		t.Fatalf("expected unsafe request id to be replaced, got %q", got)
+	}
+}
+
+func TestMpesaCallbackTraceSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	esc := &stubEscrow{executeErrs: []error{errors.New("rpc timeout")}, executeHashes: []string{"", "0xfeed"}}
+	srv := NewServer(cfg, esc, idempotency.NewMemoryStore())
+
+	body, _ := json.Marshal(mpesaCallbackRequest{
+		IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+		TxRef:       "mpesa-trace",
+		UserAddress: "0xabc",
+		Amount:      "1",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	counts := map[string]int{}
+	for _, s := range exp.GetSpans() {
+		if s.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span %q not joined to incoming trace", s.Name)
+		}
+		counts[s.Name]++
+	}
+	want := map[string]int{
+		"POST /api/v1/callbacks/mpesa": 1,
+		"replay.Claim":                 0, // callbacks rely on txRef idempotency
+		"idempotency.Get":              1,
+		"idempotency.Save":             1,
+		"executeMint.attempt":          2,
+		"retry.backoff":                1,
+	}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Fatalf("expected %d %q spans, got %d (all: %v)", n, name, counts[name], counts)
+		}
+	}
+}
+
+func TestHTTPMetricsByRouteAndStatus(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.HMACSalt = "mint-secret"
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.RateLimit.IPPerMinute = 1
+	cfg.RateLimit.IPBurst = 2
+	rpcMetrics := escrow.NewRPCMetrics()
+	srv := NewServer(cfg, escrow.FakeClient{}, &stubStore{}, WithCollectors(rpcMetrics))
+	handler := srv.Handler()
+
+	// The last status lookup is throttled; its intent ID must not become a label.
+	for _, path := range []string{"/api/v1/health", "/api/v1/mint-intents", "/no-such-route", "/api/v1/mint-intents/0xaaa", "/api/v1/mint-intents/0xbbb"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`fiatrails_http_requests_total{method="GET",route="/api/v1/health",status="200"} 1`,
+		`fiatrails_http_requests_total{method="GET",route="/api/v1/mint-intents",status="401"} 1`,
+		`fiatrails_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`fiatrails_http_requests_total{method="GET",route="GET /api/v1/mint-intents/{intentId}",status="429"} 1`,
+		`fiatrails_rate_limited_total{route="GET /api/v1/mint-intents/{intentId}",scope="ip"} 1`,
+		`fiatrails_http_request_duration_seconds_bucket{method="GET",route="/api/v1/health",status="200",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %s\n%s", want, body)
+		}
+	}
+}
+
+func TestMpesaCallbackQueuedWhileCircuitOpen(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Service.RetryQueuePath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+	cfg.Breaker = config.BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}
+
+	down := errors.New("dial tcp: connection refused")
+	esc := &stubEscrow{executeErrs: []error{down, down}, executeHashes: []string{"", "", "0xrecovered"}}
+	store := idempotency.NewMemoryStore()
+	srv := NewServer(cfg, esc, store)
+
+	body, _ := json.Marshal(mpesaCallbackRequest{
+		IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+		TxRef:       "mpesa-outage",
+		UserAddress: "0xabc",
+		Amount:      "1",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+	signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 while circuit open, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if esc.executeCalls != 2 {
+		t.Fatalf("expected retries to stop once the circuit opened, got %d calls", esc.executeCalls)
+	}
+	if dlq := srv.currentDLQDepth(); dlq != 0 {
+		t.Fatalf("expected nothing in DLQ, got %d", dlq)
+	}
+	if depth := srv.updateRetryQueueDepth(); depth != 1 {
+		t.Fatalf("expected one queued callback, got %d", depth)
+	}
+
+	health := httptest.NewRecorder()
+	srv.handleHealth(health, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+	var hresp struct {
+		Status  string               `json:"status"`
+		Circuit escrow.BreakerStatus `json:"circuit"`
+	}
+	if err := json.Unmarshal(health.Body.Bytes(), &hresp); err != nil {
+		t.Fatalf("unmarshal health: %v", err)
+	}
+	if hresp.Status != "degraded" || hresp.Circuit.State != "open" {
+		t.Fatalf("expected degraded health with open circuit, got %+v", hresp)
+	}
+
+	// Draining while open leaves the entry alone.
+	srv.drainRetryQueue(context.Background())
+	if esc.executeCalls != 2 || srv.updateRetryQueueDepth() != 1 {
+		t.Fatalf("drain must not call the chain while the circuit is open")
+	}
+
+	// Once the circuit half-opens the queued callback is processed and cached.
+	srv.breaker = escrow.NewBreaker(esc, escrow.BreakerConfig{FailureThreshold: 2})
+	srv.escrow = srv.breaker
+	srv.drainRetryQueue(context.Background())
+	if srv.updateRetryQueueDepth() != 0 {
+		t.Fatalf("expected queue to be drained")
+	}
+	rec2, _ := store.Get(context.Background(), mpesaKeyPrefix+"mpesa-outage")
+	if rec2 == nil || !bytes.Contains(rec2.Response, []byte("0xrecovered")) {
+		t.Fatalf("expected processed callback to be stored, got %+v", rec2)
+	}
+}
+
+func TestRetryQueueDrainsOldestFirst(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Service.RetryQueuePath = t.TempDir()
+	srv := NewServer(cfg, &stubEscrow{}, idempotency.NewMemoryStore())
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Queued out of name order: the hashed file names sort differently.
+	for i, txRef := range []string{"tx-c", "tx-a", "tx-d", "tx-b"} {
+		entry := retryEntry{
+			Timestamp: base.Add(time.Duration(3-i) * time.Minute),
+			Payload:   mpesaCallbackRequest{TxRef: txRef},
+		}
+		if err := srv.writeRetryEntry(entry); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	var got []string
+	for _, q := range srv.loadRetryQueue(context.Background()) {
+		got = append(got, q.entry.Payload.TxRef)
+	}
+	want := []string{"tx-b", "tx-d", "tx-a", "tx-c"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("queue order = %v, want %v", got, want)
+	}
+}
+
+func TestRetryQueueDrainWaitsForLiveCallback(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Service.RetryQueuePath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1}
+
+	mr := miniredis.RunT(t)
+	newStore := func() *idempotency.RedisStore {
+		store, err := idempotency.NewRedisStore(context.Background(), "redis://"+mr.Addr(), "test:")
+		if err != nil {
+			t.Fatalf("redis store: %v", err)
+		}
+		t.Cleanup(store.Close)
+		return store
+	}
+	esc := &stubEscrow{executeHashes: []string{"0xdrained"}}
+	srv := NewServer(cfg, esc, newStore())
+	other := newStore()
+
+	payload := mpesaCallbackRequest{
+		IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+		TxRef:       "mpesa-race",
+		UserAddress: "0xabc",
+		Amount:      "1",
+	}
+	if err := srv.writeRetryEntry(retryEntry{Timestamp: time.Now().UTC(), Payload: payload}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	callback := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A resend is being processed on another replica.
+	key := mpesaKeyPrefix + payload.TxRef
+	if ok, err := other.Reserve(context.Background(), key, time.Minute); err != nil || !ok {
+		t.Fatalf("reserve: %v %v", ok, err)
+	}
+	srv.drainRetryQueue(context.Background())
+	if esc.executeCalls != 0 || srv.updateRetryQueueDepth() != 1 {
+		t.Fatalf("drain ran a callback held by another request: %d calls", esc.executeCalls)
+	}
+	if rec := callback(); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After while the txRef is held, got %d", rec.Code)
+	}
+	if esc.executeCalls != 0 {
+		t.Fatalf("callback ran while the txRef was held: %d calls", esc.executeCalls)
+	}
+
+	if err := other.Release(context.Background(), key); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	srv.drainRetryQueue(context.Background())
+	if esc.executeCalls != 1 || srv.updateRetryQueueDepth() != 0 {
+		t.Fatalf("expected the drain to process the entry once, got %d calls", esc.executeCalls)
+	}
+	rec := callback()
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "0xdrained") || esc.executeCalls != 1 {
+		t.Fatalf("expected the drained result for a resend, got %d %s (%d calls)", rec.Code, rec.Body.String(), esc.executeCalls)
+	}
+}
+
+func TestExecuteMintRetryHonoursRateLimitAndBudget(t *testing.T) {
+	cfg := &config.AppConfig{
+		Retry: config.RetryConfig{
+			MaxAttempts:       1,
+			InitialBackoff:    time.Millisecond,
+			MaxBackoff:        time.Millisecond,
+			BackoffMultiplier: 1.5,
+			BudgetRatio:       0.1,
+			BudgetBurst:       1,
+		},
+	}
+	esc := &stubEscrow{executeErrs: []error{&escrow.RateLimitError{Endpoint: "node", Delay: 20 * time.Millisecond}}}
+	srv := NewServer(cfg, esc, stubStore{})
+
+	// One transient attempt is configured, but a rate limit earns a second
+	// attempt that waits out the node's hint.
+	start := time.Now()
+	if _, err := srv.executeMintWithRetry(context.Background(), "0xintent"); err != nil {
+		t.Fatalf("expected success after rate limit: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("retry ignored Retry-After hint, waited %v", elapsed)
+	}
+
+	// The burst is spent, so the next rate-limited call fails without retrying.
+	esc.executeCalls = 0
+	_, err := srv.executeMintWithRetry(context.Background(), "0xintent")
+	if !errors.Is(err, retry.ErrBudgetExhausted) {
+		t.Fatalf("expected budget exhaustion, got %v", err)
+	}
+	if esc.executeCalls != 1 {
+		t.Fatalf("expected a single attempt, got %d", esc.executeCalls)
+	}
+	if got := testutil.ToFloat64(srv.metrics.retryAttemptsTotal.WithLabelValues("executeMint", "budget_exhausted")); got != 1 {
+		t.Fatalf("expected budget_exhausted metric, got %v", got)
+	}
+}
+
+func TestSubmitIntentReconcilesLostResponse(t *testing.T) {
+	cfg := &config.AppConfig{Retry: config.RetryConfig{
+		SubmitMaxAttempts: 3,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        time.Millisecond,
+		BackoffMultiplier: 1,
+	}}
+	req := escrow.SubmitIntentRequest{UserAddress: "0x00000000000000000000000000000000000000a1", Amount: "100", CountryCode: "KES", TxRef: "lost-1"}
+
+	// The intent lands but the reply is lost; the retry finds it instead of
+	// resending and tripping TxRefAlreadyConsumed.
+	faults := escrow.NewFaultClient(1)
+	faults.Script(escrow.MethodSubmitIntent, escrow.FaultLostResponse)
+	resp, err := NewServer(cfg, faults, stubStore{}).submitIntentWithRetry(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected the landed intent to be reconciled: %v", err)
+	}
+	if in, ok := faults.Intent(resp.IntentID); !ok || in.TxRef != "lost-1" {
+		t.Fatalf("reconciled intent %s not on chain: %+v", resp.IntentID, in)
+	}
+	if n := faults.CallCount(escrow.MethodSubmitIntent); n != 1 {
+		t.Fatalf("submitIntent was resent: %d calls", n)
+	}
+
+	// A client that cannot look intents up is never resent to.
+	blind := escrow.NewFaultClient(1)
+	blind.Script(escrow.MethodSubmitIntent, escrow.FaultTransient)
+	if _, err := NewServer(cfg, struct{ escrow.Client }{blind}, stubStore{}).submitIntentWithRetry(context.Background(), req); err == nil {
+		t.Fatal("expected the failed attempt to be returned")
+	}
+	if n := blind.CallCount(escrow.MethodSubmitIntent); n != 1 {
+		t.Fatalf("expected a single attempt without an IntentFinder, got %d", n)
+	}
+}
+
+func TestExecuteMintReconcilesLostResponse(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Service.RetryQueuePath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}
+
+	faults := escrow.NewFaultClient(1)
+	srv := NewServer(cfg, faults, idempotency.NewMemoryStore())
+	submit := func(txRef string) string {
+		resp, err := faults.SubmitIntent(context.Background(), escrow.SubmitIntentRequest{UserAddress: "0x00000000000000000000000000000000000000a1", Amount: "100", CountryCode: "KES", TxRef: txRef})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		return resp.IntentID
+	}
+
+	callback := func(intentID, txRef string) {
+		t.Helper()
+		body, _ := json.Marshal(mpesaCallbackRequest{IntentID: intentID, TxRef: txRef, UserAddress: "0xabc", Amount: "100"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		if err := (&hmacauth.Signer{Secret: "mpesa-secret", SignatureHeader: "X-Mpesa-Signature"}).Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"processed"`) {
+			t.Fatalf("expected the minted intent to be reported processed, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if dlq := srv.currentDLQDepth(); dlq != 0 {
+			t.Fatalf("a minted intent went to the DLQ: %d entries", dlq)
+		}
+	}
+
+	// The mint lands but the reply is lost; the retry sees it executed
+	// instead of resending and treating IntentAlreadyExecuted as a failure.
+	faults.Script(escrow.MethodExecuteMint, escrow.FaultLostResponse)
+	callback(submit("lost-exec"), "lost-exec")
+	if n := faults.CallCount(escrow.MethodExecuteMint); n != 1 {
+		t.Fatalf("executeMint was resent: %d calls", n)
+	}
+
+	// A redelivery after our deadline meets the revert on its first attempt.
+	redelivered := submit("redelivered")
+	if _, err := faults.ExecuteMint(context.Background(), redelivered); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	callback(redelivered, "redelivered")
+
+	// A callback parked after such an attempt is settled without a resend.
+	queued := submit("lost-queued")
+	if _, err := faults.ExecuteMint(context.Background(), queued); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if err := srv.writeRetryEntry(retryEntry{Timestamp: time.Now(), Payload: mpesaCallbackRequest{IntentID: queued, TxRef: "lost-queued"}}); err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	srv.drainRetryQueue(context.Background())
+	if depth := srv.updateRetryQueueDepth(); depth != 0 {
+		t.Fatalf("expected the queue to drain, got %d", depth)
+	}
+	if n := faults.CallCount(escrow.MethodExecuteMint); n != 4 {
+		t.Fatalf("queued executeMint was resent: %d calls", n)
+	}
+	if dlq := srv.currentDLQDepth(); dlq != 0 {
+		t.Fatalf("a minted intent went to the DLQ: %d entries", dlq)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want retry.Class
+	}{
+		{&escrow.RevertError{Reason: "TxRefAlreadyConsumed"}, retry.Permanent},
+		{rpcError{code: 3, msg: "execution reverted"}, retry.Permanent},
+		{errors.New("submit intent tx: execution reverted: IntentAlreadyExists"), retry.Permanent},
+		{escrow.ErrCircuitOpen, retry.Permanent},
+		{&escrow.RateLimitError{Endpoint: "node", Delay: time.Second}, retry.RateLimited},
+		{errors.New("invalid character 'x' in rpc response"), retry.Transient},
+		{rpcError{code: -32000, msg: "header not found"}, retry.Transient},
+	} {
+		if got := classifyError(tc.err); got != tc.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+type rpcError struct {
+	code int
+	msg  string
+}
+
+func (e rpcError) Error() string  { return e.msg }
+func (e rpcError) ErrorCode() int { return e.code }
+
+type hangingEscrow struct{ stubEscrow }
+
+func (h *hangingEscrow) ExecuteMint(ctx context.Context, _ string) (escrow.ExecuteMintResponse, error) {
+	<-ctx.Done()
+	return escrow.ExecuteMintResponse{}, ctx.Err()
+}
+
+func TestMpesaCallbackAnswersBeforeWebhookTimeout(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Service.WebhookTimeout = 100 * time.Millisecond
+	cfg.Retry = config.RetryConfig{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+
+	srv := NewServer(cfg, &hangingEscrow{}, idempotency.NewMemoryStore())
+
+	body, _ := json.Marshal(mpesaCallbackRequest{
+		IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+		TxRef:       "mpesa-slow",
+		UserAddress: "0xabc",
+		Amount:      "1",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+	signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	srv.Handler().ServeHTTP(rec, req)
+
+	if elapsed := time.Since(start); elapsed >= cfg.Service.WebhookTimeout {
+		t.Fatalf("answered after %v, past the provider's %v timeout", elapsed, cfg.Service.WebhookTimeout)
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected retriable 503 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	if dlq := srv.currentDLQDepth(); dlq != 0 {
+		t.Fatalf("a timeout is retried by the provider, not dead-lettered; DLQ has %d", dlq)
+	}
+	if got := testutil.ToFloat64(srv.metrics.timeouts.WithLabelValues("webhook")); got != 1 {
+		t.Fatalf("expected webhook timeout metric, got %v", got)
+	}
+}
+
+func TestSlowRequestBodyGets408(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	srv := NewServer(cfg, &stubEscrow{}, stubStore{})
+
+	ts := httptest.NewUnstartedServer(srv.Handler())
+	ts.Config.ReadTimeout = 100 * time.Millisecond
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	body := []byte(`{"intentId":"0xabc","txRef":"mpesa-stall","userAddress":"0xabc","amount":"1"}`)
+	pr, pw := io.Pipe()
+	t.Cleanup(func() { _ = pw.Close() })
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/callbacks/mpesa", pr)
+	req.ContentLength = int64(len(body))
+	signer := &hmacauth.Signer{Secret: cfg.Seed.Secrets.MpesaWebhookSecret, SignatureHeader: "X-Mpesa-Signature"}
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	req.Body = pr
+	go func() { _, _ = pw.Write(body[:10]) }()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("expected 408 for a stalled body, got %d", resp.StatusCode)
+	}
+	if got := testutil.ToFloat64(srv.metrics.timeouts.WithLabelValues("request_read")); got != 1 {
+		t.Fatalf("expected request_read timeout metric, got %v", got)
+	}
+}
+
+func TestAdminConfigEndpoint(t *testing.T) {
+	cfg := &config.AppConfig{
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+		},
+		Chain: config.ChainConfig{PrivateKey: "0x" + strings.Repeat("ab", 32)},
+	}
+	cfg.Seed.Secrets.HMACSalt = "hmac-salt-value"
+	cfg.Source.SeedPath = "/etc/fiatrails/seed.json"
+
+	get := func(srv *Server, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(NewServer(cfg, &stubEscrow{}, stubStore{}), "Bearer anything"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without ADMIN_TOKEN, got %d", rec.Code)
+	}
+
+	withToken := *cfg
+	withToken.Service.AdminToken = "admin-token"
+	srv := NewServer(&withToken, &stubEscrow{}, stubStore{})
+	if rec := get(srv, "Bearer wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong token, got %d", rec.Code)
+	}
+
+	rec := get(srv, "Bearer admin-token")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, secret := range []string{"hmac-salt-value", strings.Repeat("ab", 32), "admin-token"} {
+		if strings.Contains(body, secret) {
+			t.Fatalf("admin config leaks %q: %s", secret, body)
+		}
+	}
+	var out struct {
+		Source map[string]any `json:"source"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Source["SeedPath"] != "/etc/fiatrails/seed.json" {
+		t.Fatalf("expected source paths, got %v", out.Source)
+	}
+}
+
+func TestApplyConfigRotatesSecretsAndLimits(t *testing.T) {
+	cfg := &config.AppConfig{
+		Service: config.ServiceConfig{
+			HMACClockSkew:     time.Minute,
+			IdempotencyWindow: time.Minute,
+			DLQPath:           t.TempDir(),
+		},
+	}
+	cfg.Seed.Secrets.MpesaWebhookSecret = "old-secret"
+	srv := NewServer(cfg, &stubEscrow{}, idempotency.NewMemoryStore())
+	handler := srv.Handler()
+
+	send := func(secret, txRef string) int {
+		body, _ := json.Marshal(mpesaCallbackRequest{
+			IntentID:    "0xabc1230000000000000000000000000000000000000000000000000000000000",
+			TxRef:       txRef,
+			UserAddress: "0xAbC",
+			Amount:      "1",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		signer := &hmacauth.Signer{Secret: secret, SignatureHeader: "X-Mpesa-Signature"}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("old-secret", "rot-1"); code != http.StatusOK {
+		t.Fatalf("expected 200 before reload, got %d", code)
+	}
+
+	next := *cfg
+	next.Seed.Secrets.MpesaWebhookSecret = "new-secret"
+	// The IP limit runs before signature checks, so the rejected request
+	// below spends one of the two tokens.
+	next.RateLimit = config.RateLimitConfig{IPPerMinute: 60, IPBurst: 2}
+	next.Service.HTTPPort = 9999
+	if restart, err := srv.ApplyConfig(&next); err != nil || len(restart) != 1 || restart[0] != "service" {
+		t.Fatalf("expected service to need a restart, got %v (%v)", restart, err)
+	}
+	if srv.cfg().Service.HTTPPort != 0 {
+		t.Fatal("restart-only settings must not be applied")
+	}
+
+	if code := send("old-secret", "rot-2"); code != http.StatusUnauthorized {
+		t.Fatalf("expected old secret to be rejected, got %d", code)
+	}
+	if code := send("new-secret", "rot-3"); code != http.StatusOK {
+		t.Fatalf("expected 200 with new secret, got %d", code)
+	}
+	if code := send("new-secret", "rot-4"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected reloaded IP limit to apply, got %d", code)
+	}
+	if got := testutil.ToFloat64(srv.metrics.configReloads.WithLabelValues("applied")); got != 1 {
+		t.Fatalf("config reloads = %v, want 1", got)
+	}
+}
+
+func TestKeyringFailureFailsClosed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.AppConfig{Service: config.ServiceConfig{
+		HMACClockSkew:     time.Minute,
+		IdempotencyWindow: time.Minute,
+		DLQPath:           dir,
+		MpesaKeyringPath:  filepath.Join(dir, "missing.json"),
+	}}
+	// No seed secret either: before, this ran with signature checks off.
+	srv := NewServer(cfg, &stubEscrow{executeHashes: []string{"0x1"}}, idempotency.NewMemoryStore())
+	if err := srv.Start(); err == nil || !strings.Contains(err.Error(), "missing.json") {
+		t.Fatalf("expected Start to refuse a missing keyring, got %v", err)
+	}
+
+	body := []byte(`{"intentId":"0xabc1230000000000000000000000000000000000000000000000000000000000","txRef":"unsigned","userAddress":"0xabc","amount":"1"}`)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unsigned callback got %d, want 503", rec.Code)
+	}
+}
+
+func TestApplyConfigRejectsBrokenKeyring(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mpesa-keys.json")
+	if err := os.WriteFile(path, []byte(`{"keys":[{"id":"k1","secret":"s1"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.AppConfig{Service: config.ServiceConfig{HMACClockSkew: time.Minute, MpesaKeyringPath: path}}
+	srv := NewServer(cfg, &stubEscrow{}, idempotency.NewMemoryStore())
+	if err := os.WriteFile(path, []byte(`{"keys":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	next := *cfg
+	next.RateLimit.IPBurst = 1
+	if _, err := srv.ApplyConfig(&next); err == nil {
+		t.Fatal("expected reload with a broken keyring to be rejected")
+	}
+	if srv.cfg().RateLimit.IPBurst != 0 {
+		t.Fatal("a rejected reload must not apply any setting")
+	}
+	if got := testutil.ToFloat64(srv.metrics.configReloads.WithLabelValues("rejected")); got != 1 {
+		t.Fatalf("rejected reloads = %v, want 1", got)
+	}
+	if _, ok := srv.mpesaHMAC.Keys.Lookup("k1"); !ok {
+		t.Fatal("the last good keyring must stay in effect")
+	}
+}
+
+func TestBackendInfoMetric(t *testing.T) {
+	cfg := &config.AppConfig{Service: config.ServiceConfig{HMACClockSkew: time.Minute}}
+	srv := NewServer(cfg, escrow.FakeClient{}, stubStore{}, WithBackends(Backends{
+		Mode: "dev", Chain: "fake", Idempotency: "file", RateLimit: "memory", Clients: "none",
+	}))
+
+	got := testutil.ToFloat64(srv.metrics.backendInfo.WithLabelValues("dev", "fake", "file", "memory", "none"))
+	if got != 1 {
+		t.Fatalf("fiatrails_backend_info = %v, want 1", got)
+	}
+}
+
+func TestSweepDeletesExpiredIdempotencyRecords(t *testing.T) {
+	cfg := &config.AppConfig{Service: config.ServiceConfig{HMACClockSkew: time.Minute, IdempotencySweepBatch: 2}}
+	store := idempotency.NewMemoryStore()
+	srv := NewServer(cfg, escrow.FakeClient{}, store)
+
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = store.Save(ctx, "old-"+strconv.Itoa(i), idempotency.Record{StatusCode: 201, ExpiresAt: now.Add(-time.Minute)})
+	}
+	_ = store.Save(ctx, "live", idempotency.Record{StatusCode: 201, ExpiresAt: now.Add(time.Hour)})
+
+	// Five expired records in batches of two: the pass runs until a short batch.
+	srv.sweepExpired(ctx, store)
+
+	if n, _ := store.Size(ctx); n != 1 {
+		t.Fatalf("store holds %d records after sweep, want 1", n)
+	}
+	if rec, _ := store.Get(ctx, "live"); rec == nil {
+		t.Fatal("unexpired record was swept")
+	}
+	if got := testutil.ToFloat64(srv.metrics.sweptTotal); got != 5 {
+		t.Fatalf("fiatrails_idempotency_swept_total = %v, want 5", got)
+	}
+	if got := testutil.ToFloat64(srv.metrics.idempotencySize); got != 1 {
+		t.Fatalf("fiatrails_idempotency_records = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(srv.metrics.sweepDuration); got != 1 {
+		t.Fatalf("expected one sweep duration series, got %d", got)
+	}
+}
+
+type stubBucketSweeper struct{ batches []int }
+
+func (b *stubBucketSweeper) Sweep(_ context.Context, _ time.Time, limit int) (int, error) {
+	if len(b.batches) == 0 {
+		return 0, nil
+	}
+	n := min(b.batches[0], limit)
+	b.batches = b.batches[1:]
+	return n, nil
+}
+
+func TestSweepDeletesIdleRateLimitBuckets(t *testing.T) {
+	cfg := &config.AppConfig{Service: config.ServiceConfig{HMACClockSkew: time.Minute, IdempotencySweepBatch: 2}}
+	buckets := &stubBucketSweeper{batches: []int{2, 2, 1, 2}}
+	srv := NewServer(cfg, escrow.FakeClient{}, stubStore{})
+
+	// Full batches are followed by another; the short one ends the pass.
+	srv.sweepBuckets(context.Background(), buckets)
+	if len(buckets.batches) != 1 {
+		t.Fatalf("expected the pass to stop after the short batch, %d batches left", len(buckets.batches))
+	}
+}
+
+func TestMpesaCallbackAgainstFaultClient(t *testing.T) {
+	newServer := func(t *testing.T, faults *escrow.FaultClient, txRef string) (*Server, string) {
+		cfg := &config.AppConfig{}
+		cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+		cfg.Service.HMACClockSkew = time.Minute
+		cfg.Service.IdempotencyWindow = time.Minute
+		cfg.Service.DLQPath = t.TempDir()
+		cfg.Service.RetryQueuePath = t.TempDir()
+		cfg.Retry = config.RetryConfig{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}
+		cfg.Breaker = config.BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Hour}
+
+		sub, err := faults.SubmitIntent(context.Background(), escrow.SubmitIntentRequest{
+			UserAddress: "0x00000000000000000000000000000000000000aa",
+			Amount:      "100",
+			CountryCode: "KES",
+			TxRef:       txRef,
+		})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		return NewServer(cfg, faults, idempotency.NewMemoryStore()), sub.IntentID
+	}
+	callback := func(t *testing.T, srv *Server, intentID, txRef string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(mpesaCallbackRequest{IntentID: intentID, TxRef: txRef, UserAddress: "0xaa", Amount: "100"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/callbacks/mpesa", bytes.NewReader(body))
+		signer := &hmacauth.Signer{Secret: "mpesa-secret", SignatureHeader: "X-Mpesa-Signature"}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		faults := escrow.NewFaultClient(1)
+		srv, intentID := newServer(t, faults, "mpesa-transient")
+		faults.Script(escrow.MethodExecuteMint, escrow.FaultTransient, escrow.FaultTimeout)
+		faults.Timeout = time.Millisecond
+
+		if rec := callback(t, srv, intentID, "mpesa-transient"); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 after retries, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if n := faults.CallCount(escrow.MethodExecuteMint); n != 3 {
+			t.Fatalf("expected 3 executeMint attempts, got %d", n)
+		}
+		if in, _ := faults.Intent(intentID); in.Status != escrow.IntentExecuted {
+			t.Fatalf("intent not executed: %+v", in)
+		}
+	})
+
+	t.Run("compliance revert goes to the DLQ without retrying", func(t *testing.T) {
+		faults := escrow.NewFaultClient(1)
+		srv, intentID := newServer(t, faults, "mpesa-revert")
+		faults.Script(escrow.MethodExecuteMint, escrow.FaultNotCompliant)
+
+		if rec := callback(t, srv, intentID, "mpesa-revert"); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if n := faults.CallCount(escrow.MethodExecuteMint); n != 1 {
+			t.Fatalf("permanent error was retried: %d attempts", n)
+		}
+		if dlq := srv.currentDLQDepth(); dlq != 1 {
+			t.Fatalf("expected one DLQ entry, got %d", dlq)
+		}
+		if srv.breaker.State() != escrow.BreakerClosed {
+			t.Fatalf("a revert must not trip the breaker")
+		}
+	})
+
+	t.Run("an outage opens the circuit and parks the callback", func(t *testing.T) {
+		faults := escrow.NewFaultClient(1)
+		srv, intentID := newServer(t, faults, "mpesa-outage")
+		faults.SetRules(escrow.FaultRule{Fault: escrow.FaultTransient, Rate: 1})
+
+		if rec := callback(t, srv, intentID, "mpesa-outage"); rec.Code != http.StatusAccepted {
+			t.Fatalf("expected 202 while circuit open, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if n := faults.CallCount(escrow.MethodExecuteMint); n != 3 || srv.breaker.State() != escrow.BreakerOpen {
+			t.Fatalf("expected the circuit to open after 3 calls, got %d calls and %v", n, srv.breaker.State())
+		}
+		if depth := srv.updateRetryQueueDepth(); depth != 1 {
+			t.Fatalf("expected one queued callback, got %d", depth)
+		}
+		if in, _ := faults.Intent(intentID); in.Status != escrow.IntentPending {
+			t.Fatalf("intent should still be pending: %+v", in)
+		}
+	})
+}
+
+func TestIntentLifecycleRecorded(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Seed.Secrets.HMACSalt = "shared-secret"
+	cfg.Seed.Secrets.MpesaWebhookSecret = "mpesa-secret"
+	cfg.Service.HMACClockSkew = time.Minute
+	cfg.Service.IdempotencyWindow = time.Minute
+	cfg.Service.DLQPath = t.TempDir()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1, SubmitMaxAttempts: 1}
+
+	faults := escrow.NewFaultClient(1)
+	repo := intents.NewMemoryRepository()
+	registry := apiclients.NewMemoryRegistry(
+		apiclients.Client{ID: "partner-a", Secret: "secret-a", AllowedEndpoints: []string{"/api/v1/mint-intents", "/api/v1/mint-intents/{intentId}"}},
+		apiclients.Client{ID: "partner-b", Secret: "secret-b"},
+		apiclients.Client{ID: "partner-c", Secret: "secret-c", AllowedEndpoints: []string{"/api/v1/mint-intents"}},
+	)
+	handler := NewServer(cfg, faults, idempotency.NewMemoryStore(), WithIntentRepository(repo), WithClientRegistry(registry)).Handler()
+
+	send := func(method, path, idemKey string, signer *hmacauth.Signer, payload any) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if idemKey != "" {
+			req.Header.Set("X-Idempotency-Key", idemKey)
+		}
+		if err := signer.Sign(req, body); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	partnerA := &hmacauth.Signer{KeyID: "partner-a", Secret: "secret-a"}
+	partnerB := &hmacauth.Signer{KeyID: "partner-b", Secret: "secret-b"}
+	partnerC := &hmacauth.Signer{KeyID: "partner-c", Secret: "secret-c"}
+	shared := &hmacauth.Signer{Secret: "shared-secret"}
+	mpesa := &hmacauth.Signer{Secret: "mpesa-secret", SignatureHeader: "X-Mpesa-Signature"}
+	mint := func(txRef string) mintIntentRequest {
+		return mintIntentRequest{UserAddress: "0x00000000000000000000000000000000000000a1", Amount: "100", CountryCode: "KES", TxRef: txRef}
+	}
+	status := func(intentID string, signer *hmacauth.Signer) (int, intentStatusResponse) {
+		rec := send(http.MethodGet, "/api/v1/mint-intents/"+intentID, "", signer, nil)
+		var out intentStatusResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	rec := send(http.MethodPost, "/api/v1/mint-intents", "key-1", partnerA, mint("tx-1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created mintIntentResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	// A new idempotency key cannot submit the same txRef again.
+	if rec := send(http.MethodPost, "/api/v1/mint-intents", "key-2", partnerA, mint("tx-1")); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for reused txRef, got %d", rec.Code)
+	}
+	if n := faults.CallCount(escrow.MethodSubmitIntent); n != 1 {
+		t.Fatalf("reused txRef reached the chain: %d submissions", n)
+	}
+
+	code, got := status(created.IntentID, partnerA)
+	if code != http.StatusOK || got.Status != "submitted" || got.TxRef != "tx-1" || got.SubmitTxHash != created.TxHash {
+		t.Fatalf("unexpected status %d %+v", code, got)
+	}
+	if code, _ := status(created.IntentID, partnerB); code != http.StatusNotFound {
+		t.Fatalf("another client must not see the intent, got %d", code)
+	}
+	if code, _ := status(created.IntentID, partnerC); code != http.StatusForbidden {
+		t.Fatalf("the status route must be granted explicitly, got %d", code)
+	}
+	if code, _ := status(created.IntentID, shared); code != http.StatusNotFound {
+		t.Fatalf("the shared secret must not see a partner's intent, got %d", code)
+	}
+	rec = send(http.MethodPost, "/api/v1/mint-intents", "key-shared", shared, mint("tx-shared"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var sharedCreated mintIntentResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &sharedCreated)
+	if code, _ := status(sharedCreated.IntentID, shared); code != http.StatusOK {
+		t.Fatalf("the shared secret must see its own intent, got %d", code)
+	}
+	if code, _ := status(sharedCreated.IntentID, partnerA); code != http.StatusNotFound {
+		t.Fatalf("a partner must not see a shared-secret intent, got %d", code)
+	}
+
+	cb := mpesaCallbackRequest{IntentID: created.IntentID, TxRef: "tx-1", UserAddress: "0xa1", Amount: "100"}
+	if rec := send(http.MethodPost, "/api/v1/callbacks/mpesa", "", mpesa, cb); rec.Code != http.StatusOK {
+		t.Fatalf("callback: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, got = status(created.IntentID, partnerA); got.Status != "executed" || got.TxHash == "" || got.ExecutedAt == nil {
+		t.Fatalf("expected executed intent, got %+v", got)
+	}
+
+	// A submission that fails before reaching the chain can be repeated.
+	faults.Script(escrow.MethodSubmitIntent, escrow.FaultNotCompliant)
+	if rec := send(http.MethodPost, "/api/v1/mint-intents", "key-3", partnerA, mint("tx-2")); rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+	if in, _ := repo.Get(context.Background(), "tx-2"); in.Status != intents.StatusFailed || !strings.Contains(in.LastError, "UserNotCompliant") {
+		t.Fatalf("expected failed intent, got %+v", in)
+	}
+	if rec := send(http.MethodPost, "/api/v1/mint-intents", "key-4", partnerA, mint("tx-2")); rec.Code != http.StatusCreated {
+		t.Fatalf("expected retry to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// An execution failure is recorded alongside the DLQ entry.
+	var second mintIntentResponse
+	_ = json.Unmarshal(send(http.MethodPost, "/api/v1/mint-intents", "key-5", partnerA, mint("tx-3")).Body.Bytes(), &second)
+	faults.Script(escrow.MethodExecuteMint, escrow.FaultNotCompliant)
+	cb = mpesaCallbackRequest{IntentID: second.IntentID, TxRef: "tx-3", UserAddress: "0xa1", Amount: "100"}
+	if rec := send(http.MethodPost, "/api/v1/callbacks/mpesa", "", mpesa, cb); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if _, got = status(second.IntentID, partnerA); got.Status != "failed" || !strings.Contains(got.Error, "UserNotCompliant") {
+		t.Fatalf("expected failed intent, got %+v", got)
+	}
+}
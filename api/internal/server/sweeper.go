@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"fiatrails/internal/idempotency"
+	"fiatrails/internal/logging"
+	"fiatrails/internal/ratelimit"
+)
+
+const (
+	defaultSweepInterval = time.Minute
+	defaultSweepBatch    = 1000
+)
+
+// runSweeper deletes expired idempotency records and idle rate limit
+// buckets once at startup and then every IdempotencySweepInterval. Either
+// sweeper may be nil.
+func (s *Server) runSweeper(ctx context.Context, sw idempotency.Sweeper, buckets ratelimit.Sweeper) {
+	interval := s.cfg().Service.IdempotencySweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if sw != nil {
+			s.sweepExpired(ctx, sw)
+		}
+		if buckets != nil {
+			s.sweepBuckets(ctx, buckets)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepExpired deletes expired records a batch at a time until a batch comes
+// back short, so a backlog is cleared in one pass without one huge delete.
+func (s *Server) sweepExpired(ctx context.Context, sw idempotency.Sweeper) {
+	batch := s.cfg().Service.IdempotencySweepBatch
+	if batch <= 0 {
+		batch = defaultSweepBatch
+	}
+
+	start := time.Now()
+	deleted, result := 0, "ok"
+	for ctx.Err() == nil {
+		n, err := sw.Sweep(ctx, time.Now(), batch)
+		deleted += n
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.FromContext(ctx).Error("idempotency sweep failed", "deleted", deleted, "error", err)
+				result = "error"
+			}
+			break
+		}
+		if n < batch {
+			break
+		}
+	}
+	s.metrics.observeSweep(result, deleted, time.Since(start))
+	if deleted > 0 {
+		logging.FromContext(ctx).Debug("expired idempotency records deleted", "deleted", deleted, "elapsed", time.Since(start).String())
+	}
+
+	size, err := sw.Size(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.FromContext(ctx).Warn("idempotency store size unavailable", "error", err)
+		}
+		return
+	}
+	s.metrics.setIdempotencySize(size)
+}
+
+// sweepBuckets deletes idle rate limit buckets in batches, like sweepExpired.
+func (s *Server) sweepBuckets(ctx context.Context, sw ratelimit.Sweeper) {
+	batch := s.cfg().Service.IdempotencySweepBatch
+	if batch <= 0 {
+		batch = defaultSweepBatch
+	}
+	deleted := 0
+	for ctx.Err() == nil {
+		n, err := sw.Sweep(ctx, time.Now(), batch)
+		deleted += n
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.FromContext(ctx).Error("rate limit bucket sweep failed", "deleted", deleted, "error", err)
+			}
+			return
+		}
+		if n < batch {
+			break
+		}
+	}
+	if deleted > 0 {
+		logging.FromContext(ctx).Debug("idle rate limit buckets deleted", "deleted", deleted)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"fiatrails/internal/hmacauth"
+	"fiatrails/internal/idempotency"
+	"fiatrails/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("fiatrails/internal/server")
+
+// traceRequests starts a server span per request, continuing any W3C trace
+// context sent by the caller. The span is named after the mux route rather
+// than the raw path so IDs in URLs do not make every name unique.
+func (s *Server) traceRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := patternPath(routeOf(mux, r))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+func (s *Server) getRecord(ctx context.Context, key string) (*idempotency.Record, error) {
+	ctx, span := tracer.Start(ctx, "idempotency.Get", trace.WithAttributes(s.storeAttr()))
+	rec, err := s.store.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("idempotency.hit", rec != nil))
+	tracing.End(span, err)
+	return rec, err
+}
+
+func (s *Server) saveRecord(ctx context.Context, key string, rec idempotency.Record) error {
+	ctx, span := tracer.Start(ctx, "idempotency.Save", trace.WithAttributes(s.storeAttr()))
+	err := s.store.Save(ctx, key, rec)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *Server) storeAttr() attribute.KeyValue {
+	return attribute.String("idempotency.store", fmt.Sprintf("%T", s.store))
+}
+
+// tracedReplayCache adds a span around replay claims, which hit the
+// database when the idempotency store backs them.
+type tracedReplayCache struct {
+	hmacauth.ReplayCache
+}
+
+func (c tracedReplayCache) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, span := tracer.Start(ctx, "replay.Claim")
+	ok, err := c.ReplayCache.Claim(ctx, key, ttl)
+	span.SetAttributes(attribute.Bool("replay.claimed", ok))
+	tracing.End(span, err)
+	return ok, err
+}
+
+func (c tracedReplayCache) Unclaim(ctx context.Context, key string) error {
+	ctx, span := tracer.Start(ctx, "replay.Unclaim")
+	err := c.ReplayCache.Unclaim(ctx, key)
+	tracing.End(span, err)
+	return err
+}
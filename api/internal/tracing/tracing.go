@@ -0,0 +1,77 @@
+// Package tracing configures OpenTelemetry trace export and W3C context
+// propagation for the service.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName is reported when OTEL_SERVICE_NAME is unset.
+const DefaultServiceName = "fiatrails-api"
+
+// Setup installs the W3C trace context propagator and, when an OTLP endpoint
+// is configured through the standard OTEL_EXPORTER_OTLP_* variables, a tracer
+// provider exporting over OTLP/HTTP. Without an endpoint spans are dropped.
+// The returned function flushes pending spans.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !exporterConfigured() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.Default()
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		res, err = resource.Merge(res, resource.NewSchemaless(attribute.String("service.name", DefaultServiceName)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func exporterConfigured() bool {
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Tracer returns a tracer from the global provider, so spans follow whatever
+// provider Setup (or a test) installed.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}